@@ -0,0 +1,460 @@
+// Package acp implements the agent side of the Agent Client Protocol, for
+// the editors speaking it to drive the app over stdio.
+package acp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The options of the permission requests sent to the client.
+const (
+	optionAllow        = "allow"
+	optionAllowSession = "allow_session"
+	optionDeny         = "deny"
+)
+
+// Agent answers the requests of an ACP client with the app.
+type Agent struct {
+	app *app.App
+
+	mu   sync.Mutex
+	caps ClientCapabilities
+	// sessions are the sessions the client created or loaded, by ID.
+	sessions *csync.Map[string, *clientSession]
+}
+
+func New(app *app.App) *Agent {
+	return &Agent{
+		app:      app,
+		sessions: csync.NewMap[string, *clientSession](),
+	}
+}
+
+// Serve speaks the protocol with the client on rw, newline-delimited
+// JSON-RPC messages, until the client goes away or ctx is done.
+func (a *Agent) Serve(ctx context.Context, rw io.ReadWriteCloser) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the requests are handled concurrently, a prompt runs while its
+	// permission requests go back and forth
+	handler := jsonrpc2.HandlerWithError(a.handle).SuppressErrClosed()
+	conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewPlainObjectStream(rw), jsonrpc2.AsyncHandler(handler))
+	defer conn.Close()
+
+	go a.forward(ctx, conn)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-conn.DisconnectNotify():
+		if a.app.AgentCoordinator != nil {
+			a.app.AgentCoordinator.CancelAll()
+		}
+		return nil
+	}
+}
+
+func (a *Agent) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	switch req.Method {
+	case MethodInitialize:
+		var params InitializeParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		return a.initialize(params), nil
+	case MethodAuthenticate:
+		// the providers are set up in the configuration
+		return nil, nil
+	case MethodSessionNew:
+		var params NewSessionParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		return a.newSession(ctx, params)
+	case MethodSessionLoad:
+		var params LoadSessionParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, a.loadSession(ctx, conn, params)
+	case MethodSessionPrompt:
+		var params PromptParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		return a.prompt(ctx, conn, params)
+	case MethodSessionCancel:
+		var params CancelParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		a.cancel(params.SessionID)
+		return nil, nil
+	default:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+	}
+}
+
+func decode(req *jsonrpc2.Request, v any) error {
+	if req.Params == nil {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "missing params"}
+	}
+	if err := json.Unmarshal(*req.Params, v); err != nil {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (a *Agent) initialize(params InitializeParams) InitializeResult {
+	a.mu.Lock()
+	a.caps = params.ClientCapabilities
+	a.mu.Unlock()
+	return InitializeResult{
+		ProtocolVersion: ProtocolVersion,
+		AgentCapabilities: AgentCapabilities{
+			LoadSession: true,
+			PromptCapabilities: PromptCapabilities{
+				Image:           true,
+				EmbeddedContext: true,
+			},
+		},
+		AuthMethods: []AuthMethod{},
+	}
+}
+
+func (a *Agent) newSession(ctx context.Context, params NewSessionParams) (NewSessionResult, error) {
+	if len(params.MCPServers) > 0 {
+		slog.Warn("Ignoring the MCP servers of the client, only the configured ones are used", "count", len(params.MCPServers))
+	}
+	sess, err := a.app.Sessions.Create(ctx, "New Session")
+	if err != nil {
+		return NewSessionResult{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	a.sessions.Set(sess.ID, newClientSession(sess.ID, params.CWD))
+	return NewSessionResult{SessionID: sess.ID}, nil
+}
+
+// loadSession replays the messages of the session for the client to show
+// them, before it sends prompts to it.
+func (a *Agent) loadSession(ctx context.Context, conn *jsonrpc2.Conn, params LoadSessionParams) error {
+	sess, err := a.app.Sessions.Get(ctx, params.SessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("session %s not found", params.SessionID)}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if a.app.Sessions.IsAgentToolSession(sess.ID) {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("session %s belongs to an agent tool call", sess.ID)}
+	}
+	msgs, err := a.app.Messages.List(ctx, sess.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	s := newClientSession(sess.ID, params.CWD)
+	a.sessions.Set(sess.ID, s)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, msg := range msgs {
+		a.send(ctx, conn, s, s.updates(msg, true))
+	}
+	return nil
+}
+
+// prompt runs the agent on the prompt and returns once it's done, the client
+// follows the run with the updates of the session.
+func (a *Agent) prompt(ctx context.Context, conn *jsonrpc2.Conn, params PromptParams) (PromptResult, error) {
+	s, ok := a.sessions.Get(params.SessionID)
+	if !ok {
+		return PromptResult{}, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("session %s not found, create or load it first", params.SessionID)}
+	}
+	if a.app.AgentCoordinator == nil {
+		return PromptResult{}, errors.New("no providers configured, run crush to set one up")
+	}
+	if a.app.AgentCoordinator.IsSessionBusy(s.id) {
+		return PromptResult{}, fmt.Errorf("session %s is busy", s.id)
+	}
+	prompt, attachments, err := promptContent(params.Prompt)
+	if err != nil {
+		return PromptResult{}, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: err.Error()}
+	}
+
+	s.mu.Lock()
+	s.cancelled = false
+	s.mu.Unlock()
+
+	a.mu.Lock()
+	fs := &clientFileSystem{conn: conn, sessionID: s.id, caps: a.caps.FS}
+	a.mu.Unlock()
+	runCtx := context.WithValue(ctx, tools.FileSystemContextKey, tools.FileSystem(fs))
+	_, runErr := a.app.AgentCoordinator.Run(runCtx, s.id, prompt, attachments...)
+
+	// the events may have been dropped, send what is missing
+	msgs, err := a.app.Messages.List(ctx, s.id)
+	if err != nil {
+		return PromptResult{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, msg := range msgs {
+		a.send(ctx, conn, s, s.updates(msg, false))
+	}
+
+	if s.cancelled || errors.Is(runErr, context.Canceled) {
+		return PromptResult{StopReason: StopReasonCancelled}, nil
+	}
+	if runErr != nil {
+		return PromptResult{}, runErr
+	}
+	return PromptResult{StopReason: stopReason(msgs)}, nil
+}
+
+// promptContent returns the text of the prompt and its attachments. The
+// text of the embedded resources is added to the text, the model gets the
+// files as they are in the editor.
+func promptContent(blocks []ContentBlock) (string, []message.Attachment, error) {
+	var (
+		texts       []string
+		attachments []message.Attachment
+	)
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "resource_link":
+			texts = append(texts, uriPath(block.URI))
+		case "resource":
+			if block.Resource == nil {
+				continue
+			}
+			if block.Resource.Text == "" {
+				texts = append(texts, uriPath(block.Resource.URI))
+				continue
+			}
+			texts = append(texts, fmt.Sprintf("<file path=\"%s\">\n%s\n</file>", uriPath(block.Resource.URI), block.Resource.Text))
+		case "image":
+			data, err := base64.StdEncoding.DecodeString(block.Data)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid image data: %w", err)
+			}
+			name := "image"
+			if block.URI != "" {
+				name = filepath.Base(uriPath(block.URI))
+			}
+			attachments = append(attachments, message.Attachment{
+				FilePath: uriPath(block.URI),
+				FileName: name,
+				MimeType: block.MimeType,
+				Content:  data,
+			})
+		default:
+			return "", nil, fmt.Errorf("unsupported content type: %s", block.Type)
+		}
+	}
+	prompt := strings.Join(texts, "\n")
+	if strings.TrimSpace(prompt) == "" {
+		return "", nil, errors.New("prompt is empty")
+	}
+	return prompt, attachments, nil
+}
+
+// uriPath returns the path of file URIs, and the other URIs as they are.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+// stopReason returns why the agent stopped, from the last message of the
+// session.
+func stopReason(msgs []message.Message) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role != message.Assistant {
+			continue
+		}
+		switch msgs[i].FinishReason() {
+		case message.FinishReasonCanceled:
+			return StopReasonCancelled
+		case message.FinishReasonMaxTokens:
+			return StopReasonMaxTokens
+		case message.FinishReasonMaxTurns:
+			return StopReasonMaxTurns
+		}
+		return StopReasonEndTurn
+	}
+	return StopReasonEndTurn
+}
+
+func (a *Agent) cancel(sessionID string) {
+	s, ok := a.sessions.Get(sessionID)
+	if !ok || a.app.AgentCoordinator == nil {
+		return
+	}
+	s.mu.Lock()
+	s.cancelled = true
+	s.mu.Unlock()
+	a.app.AgentCoordinator.Cancel(sessionID)
+}
+
+// forward sends the updates of the sessions of the client as the messages
+// change, and routes the permission requests to the client.
+func (a *Agent) forward(ctx context.Context, conn *jsonrpc2.Conn) {
+	messages := a.app.Messages.Subscribe(ctx)
+	requests := a.app.Permissions.Subscribe(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-messages:
+			if !ok {
+				return
+			}
+			if event.Type == pubsub.DeletedEvent {
+				continue
+			}
+			s, ok := a.sessions.Get(event.Payload.SessionID)
+			if !ok {
+				continue
+			}
+			s.mu.Lock()
+			a.send(ctx, conn, s, s.updates(event.Payload, false))
+			s.mu.Unlock()
+		case event, ok := <-requests:
+			if !ok {
+				return
+			}
+			go a.requestPermission(ctx, conn, event.Payload)
+		}
+	}
+}
+
+// send sends the updates of the session, s.mu must be held.
+func (a *Agent) send(ctx context.Context, conn *jsonrpc2.Conn, s *clientSession, updates []SessionUpdate) {
+	for _, update := range updates {
+		err := conn.Notify(ctx, MethodSessionUpdate, SessionNotification{
+			SessionID: s.id,
+			Update:    update,
+		})
+		if err != nil {
+			slog.Error("Failed to send session update", "session_id", s.id, "error", err)
+			return
+		}
+	}
+}
+
+// requestPermission asks the client for the permission, on behalf of the
+// session of the client the request comes from. The requests of the agent
+// tool calls come from their own sessions.
+func (a *Agent) requestPermission(ctx context.Context, conn *jsonrpc2.Conn, req permission.PermissionRequest) {
+	s, ok := a.clientSession(ctx, req.SessionID)
+	if !ok {
+		slog.Warn("Denying permission request of a session unknown to the client", "session_id", req.SessionID, "tool", req.ToolName)
+		a.app.Permissions.Deny(req)
+		return
+	}
+
+	toolCall := SessionUpdate{
+		ToolCallID: req.ToolCallID,
+		Title:      req.Description,
+		Kind:       toolKind(req.ToolName),
+		Status:     ToolCallStatusPending,
+	}
+	if input, err := json.Marshal(req.Params); err == nil {
+		toolCall.RawInput = input
+	}
+	if req.Path != "" {
+		toolCall.Locations = []ToolCallLocation{{Path: s.absPath(req.Path)}}
+	}
+	if diff, ok := permissionDiff(req.Params); ok {
+		toolCall.ToolContent = []ToolCallContent{diff}
+		toolCall.Locations = []ToolCallLocation{{Path: diff.Path}}
+	}
+
+	var result RequestPermissionResult
+	err := conn.Call(ctx, MethodRequestPermission, RequestPermissionParams{
+		SessionID: s.id,
+		ToolCall:  toolCall,
+		Options: []PermissionOption{
+			{OptionID: optionAllow, Name: "Allow", Kind: PermissionAllowOnce},
+			{OptionID: optionAllowSession, Name: "Allow for this session", Kind: PermissionAllowAlways},
+			{OptionID: optionDeny, Name: "Deny", Kind: PermissionRejectOnce},
+		},
+	}, &result)
+	if err != nil {
+		slog.Error("Failed to request permission", "session_id", s.id, "tool", req.ToolName, "error", err)
+		a.app.Permissions.Deny(req)
+		return
+	}
+	switch {
+	case result.Outcome.Outcome != OutcomeSelected:
+		a.app.Permissions.Deny(req)
+	case result.Outcome.OptionID == optionAllow:
+		a.app.Permissions.Grant(req)
+	case result.Outcome.OptionID == optionAllowSession:
+		a.app.Permissions.GrantPersistent(req)
+	default:
+		a.app.Permissions.Deny(req)
+	}
+}
+
+// clientSession returns the session of the client the session belongs to,
+// going up the parents of the sessions of the agent tool calls. A fork is a
+// session of its own, its updates never go to the session it was forked from.
+func (a *Agent) clientSession(ctx context.Context, sessionID string) (*clientSession, bool) {
+	for sessionID != "" {
+		if s, ok := a.sessions.Get(sessionID); ok {
+			return s, true
+		}
+		sess, err := a.app.Sessions.Get(ctx, sessionID)
+		if err != nil || sess.IsFork() {
+			return nil, false
+		}
+		sessionID = sess.ParentSessionID
+	}
+	return nil, false
+}
+
+// permissionDiff returns the diff of the files the tools asking for
+// permission change, for the client to show it.
+func permissionDiff(params any) (ToolCallContent, bool) {
+	var path, oldContent, newContent string
+	switch p := params.(type) {
+	case tools.EditPermissionsParams:
+		path, oldContent, newContent = p.FilePath, p.OldContent, p.NewContent
+	case tools.MultiEditPermissionsParams:
+		path, oldContent, newContent = p.FilePath, p.OldContent, p.NewContent
+	case tools.WritePermissionsParams:
+		path, oldContent, newContent = p.FilePath, p.OldContent, p.NewContent
+	default:
+		return ToolCallContent{}, false
+	}
+	return ToolCallContent{
+		Type:    "diff",
+		Path:    path,
+		OldText: oldContent,
+		NewText: newContent,
+	}, true
+}
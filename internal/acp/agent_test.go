@@ -0,0 +1,262 @@
+package acp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedCoordinator runs a scripted turn instead of the agent: it reads
+// main.go, asks for the permission to write it and writes it.
+type scriptedCoordinator struct {
+	agent.Coordinator
+	app *app.App
+}
+
+func (c *scriptedCoordinator) Run(ctx context.Context, sessionID, prompt string, attachments ...message.Attachment) (*fantasy.AgentResult, error) {
+	messages := c.app.Messages
+	if _, err := messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: prompt}},
+	}); err != nil {
+		return nil, err
+	}
+
+	fs := tools.GetFileSystemFromContext(ctx)
+	content, err := fs.ReadFile(ctx, "/project/main.go")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: "Renaming the package"},
+			message.ToolCall{ID: "call", Name: tools.WriteToolName, Input: `{"file_path":"main.go","content":"package app\n"}`, Finished: true},
+			message.Finish{Reason: message.FinishReasonToolUse},
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	granted := c.app.Permissions.Request(permission.CreatePermissionRequest{
+		SessionID:   sessionID,
+		ToolCallID:  "call",
+		ToolName:    tools.WriteToolName,
+		Description: "Create file /project/main.go",
+		Action:      "write",
+		Path:        "/project",
+		Params: tools.WritePermissionsParams{
+			FilePath:   "/project/main.go",
+			OldContent: string(content),
+			NewContent: "package app\n",
+		},
+	})
+	result := message.ToolResult{ToolCallID: "call", Name: tools.WriteToolName, Content: "File written"}
+	if granted {
+		if err := fs.WriteFile(ctx, "/project/main.go", []byte("package app\n")); err != nil {
+			return nil, err
+		}
+	} else {
+		result = message.ToolResult{ToolCallID: "call", Name: tools.WriteToolName, Content: "Permission denied", IsError: true}
+	}
+	if _, err := messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role:  message.Tool,
+		Parts: []message.ContentPart{result},
+	}); err != nil {
+		return nil, err
+	}
+
+	_, err = messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: "Done"},
+			message.Finish{Reason: message.FinishReasonEndTurn},
+		},
+	})
+	return nil, err
+}
+
+func (c *scriptedCoordinator) IsSessionBusy(sessionID string) bool { return false }
+
+func (c *scriptedCoordinator) Cancel(sessionID string) {}
+
+func (c *scriptedCoordinator) CancelAll() {}
+
+// scriptedClient is an editor with main.go open, allowing what the agent
+// asks for.
+type scriptedClient struct {
+	mu          sync.Mutex
+	updates     []map[string]any
+	permissions []RequestPermissionParams
+	buffers     map[string]string
+}
+
+func (c *scriptedClient) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch req.Method {
+	case MethodSessionUpdate:
+		var params struct {
+			Update map[string]any `json:"update"`
+		}
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		c.updates = append(c.updates, params.Update)
+		return nil, nil
+	case MethodRequestPermission:
+		var params RequestPermissionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		c.permissions = append(c.permissions, params)
+		return RequestPermissionResult{Outcome: PermissionOutcome{Outcome: OutcomeSelected, OptionID: optionAllow}}, nil
+	case MethodFSReadTextFile:
+		var params ReadTextFileParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return ReadTextFileResult{Content: c.buffers[params.Path]}, nil
+	case MethodFSWriteTextFile:
+		var params WriteTextFileParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		c.buffers[params.Path] = params.Content
+		return nil, nil
+	default:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound}
+	}
+}
+
+type pipe struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (p pipe) Close() error {
+	p.ReadCloser.Close()
+	return p.WriteCloser.Close()
+}
+
+func TestAgent(t *testing.T) {
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	a := &app.App{
+		Sessions:    session.NewService(q),
+		Messages:    message.NewService(q),
+		Permissions: permission.NewPermissionService(t.TempDir(), false, nil),
+	}
+	a.AgentCoordinator = &scriptedCoordinator{app: a}
+
+	agentReader, clientWriter := io.Pipe()
+	clientReader, agentWriter := io.Pipe()
+	acpAgent := New(a)
+	go acpAgent.Serve(t.Context(), pipe{agentReader, agentWriter})
+
+	client := &scriptedClient{buffers: map[string]string{"/project/main.go": "package main\n"}}
+	rpc := jsonrpc2.NewConn(t.Context(), jsonrpc2.NewPlainObjectStream(pipe{clientReader, clientWriter}), jsonrpc2.HandlerWithError(client.handle))
+	t.Cleanup(func() { rpc.Close() })
+
+	var initialized InitializeResult
+	require.NoError(t, rpc.Call(t.Context(), MethodInitialize, InitializeParams{
+		ProtocolVersion:    ProtocolVersion,
+		ClientCapabilities: ClientCapabilities{FS: FSCapabilities{ReadTextFile: true, WriteTextFile: true}},
+	}, &initialized))
+	require.Equal(t, ProtocolVersion, initialized.ProtocolVersion)
+	require.True(t, initialized.AgentCapabilities.LoadSession)
+
+	var created NewSessionResult
+	require.NoError(t, rpc.Call(t.Context(), MethodSessionNew, NewSessionParams{CWD: "/project"}, &created))
+
+	var prompted PromptResult
+	require.NoError(t, rpc.Call(t.Context(), MethodSessionPrompt, PromptParams{
+		SessionID: created.SessionID,
+		Prompt: []ContentBlock{
+			TextBlock("Rename the package of"),
+			{Type: "resource_link", URI: "file:///project/main.go", Name: "main.go"},
+		},
+	}, &prompted))
+	require.Equal(t, StopReasonEndTurn, prompted.StopReason)
+
+	client.mu.Lock()
+	require.Equal(t, "package app\n", client.buffers["/project/main.go"])
+	require.Len(t, client.permissions, 1)
+	require.Equal(t, "call", client.permissions[0].ToolCall.ToolCallID)
+	require.Equal(t, "edit", client.permissions[0].ToolCall.Kind)
+	require.Equal(t, []ToolCallContent{{Type: "diff", Path: "/project/main.go", OldText: "package main\n", NewText: "package app\n"}}, client.permissions[0].ToolCall.ToolContent)
+
+	var kinds []string
+	for _, update := range client.updates {
+		kinds = append(kinds, update["sessionUpdate"].(string))
+	}
+	require.Equal(t, []string{UpdateAgentMessageChunk, UpdateToolCall, UpdateToolCallUpdate, UpdateAgentMessageChunk}, kinds)
+	require.Equal(t, "write main.go", client.updates[1]["title"])
+	require.Equal(t, []any{map[string]any{"path": "/project/main.go"}}, client.updates[1]["locations"])
+	require.Equal(t, ToolCallStatusCompleted, client.updates[2]["status"])
+	require.Equal(t, "Done", client.updates[3]["content"].(map[string]any)["text"])
+	client.updates = nil
+	client.mu.Unlock()
+
+	msgs, err := a.Messages.List(t.Context(), created.SessionID)
+	require.NoError(t, err)
+	require.Equal(t, "Rename the package of\n/project/main.go", msgs[0].Content().Text)
+
+	// loading the session replays it
+	require.NoError(t, rpc.Call(t.Context(), MethodSessionLoad, LoadSessionParams{SessionID: created.SessionID, CWD: "/project"}, nil))
+	client.mu.Lock()
+	require.Len(t, client.updates, 5)
+	require.Equal(t, UpdateUserMessageChunk, client.updates[0]["sessionUpdate"])
+	client.updates = nil
+	client.mu.Unlock()
+
+	// a fork is a session of its own, which can be loaded
+	fork, err := a.Sessions.Fork(t.Context(), created.SessionID, msgs[1].ID)
+	require.NoError(t, err)
+	_, ok := acpAgent.clientSession(t.Context(), fork.ID)
+	require.False(t, ok)
+	require.NoError(t, rpc.Call(t.Context(), MethodSessionLoad, LoadSessionParams{SessionID: fork.ID, CWD: "/project"}, nil))
+	client.mu.Lock()
+	require.Len(t, client.updates, 4)
+	client.mu.Unlock()
+	s, ok := acpAgent.clientSession(t.Context(), fork.ID)
+	require.True(t, ok)
+	require.Equal(t, fork.ID, s.id)
+
+	err = rpc.Call(t.Context(), MethodSessionPrompt, PromptParams{SessionID: "unknown", Prompt: []ContentBlock{TextBlock("Hi")}}, nil)
+	require.ErrorContains(t, err, "session unknown not found")
+}
+
+func TestPromptContent(t *testing.T) {
+	t.Parallel()
+
+	prompt, attachments, err := promptContent([]ContentBlock{
+		TextBlock("Explain"),
+		{Type: "resource", Resource: &EmbeddedResource{URI: "file:///project/main.go", Text: "package main"}},
+		{Type: "image", Data: "aGVsbG8=", MimeType: "image/png"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Explain\n<file path=\"/project/main.go\">\npackage main\n</file>", prompt)
+	require.Len(t, attachments, 1)
+	require.Equal(t, "image/png", attachments[0].MimeType)
+	require.Equal(t, []byte("hello"), attachments[0].Content)
+
+	_, _, err = promptContent([]ContentBlock{{Type: "audio"}})
+	require.ErrorContains(t, err, "unsupported content type: audio")
+}
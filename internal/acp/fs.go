@@ -0,0 +1,45 @@
+package acp
+
+import (
+	"context"
+
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// clientFileSystem reads and writes the files through the client, for the
+// tools to see the unsaved changes of the editor and the editor to follow
+// the changes of the agent.
+type clientFileSystem struct {
+	conn      *jsonrpc2.Conn
+	sessionID string
+	// caps tells what the client can do, the files are read or written on
+	// disk otherwise.
+	caps FSCapabilities
+}
+
+func (fs *clientFileSystem) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	if !fs.caps.ReadTextFile {
+		return tools.LocalFileSystem{}.ReadFile(ctx, path)
+	}
+	var result ReadTextFileResult
+	err := fs.conn.Call(ctx, MethodFSReadTextFile, ReadTextFileParams{
+		SessionID: fs.sessionID,
+		Path:      path,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(result.Content), nil
+}
+
+func (fs *clientFileSystem) WriteFile(ctx context.Context, path string, data []byte) error {
+	if !fs.caps.WriteTextFile {
+		return tools.LocalFileSystem{}.WriteFile(ctx, path, data)
+	}
+	return fs.conn.Call(ctx, MethodFSWriteTextFile, WriteTextFileParams{
+		SessionID: fs.sessionID,
+		Path:      path,
+		Content:   string(data),
+	}, nil)
+}
@@ -0,0 +1,269 @@
+package acp
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// ProtocolVersion is the version of the Agent Client Protocol spoken by the
+// agent.
+const ProtocolVersion = 1
+
+// The methods of the agent, called by the client.
+const (
+	MethodInitialize    = "initialize"
+	MethodAuthenticate  = "authenticate"
+	MethodSessionNew    = "session/new"
+	MethodSessionLoad   = "session/load"
+	MethodSessionPrompt = "session/prompt"
+	MethodSessionCancel = "session/cancel"
+)
+
+// The methods of the client, called by the agent.
+const (
+	MethodSessionUpdate     = "session/update"
+	MethodRequestPermission = "session/request_permission"
+	MethodFSReadTextFile    = "fs/read_text_file"
+	MethodFSWriteTextFile   = "fs/write_text_file"
+)
+
+// The kinds of the session updates.
+const (
+	UpdateUserMessageChunk  = "user_message_chunk"
+	UpdateAgentMessageChunk = "agent_message_chunk"
+	UpdateAgentThoughtChunk = "agent_thought_chunk"
+	UpdateToolCall          = "tool_call"
+	UpdateToolCallUpdate    = "tool_call_update"
+)
+
+// The statuses of the tool calls.
+const (
+	ToolCallStatusPending    = "pending"
+	ToolCallStatusInProgress = "in_progress"
+	ToolCallStatusCompleted  = "completed"
+	ToolCallStatusFailed     = "failed"
+)
+
+// The kinds of the permission options and the outcomes of the permission
+// requests.
+const (
+	PermissionAllowOnce   = "allow_once"
+	PermissionAllowAlways = "allow_always"
+	PermissionRejectOnce  = "reject_once"
+	OutcomeSelected       = "selected"
+	OutcomeCancelled      = "cancelled"
+)
+
+// The reasons the agent stops a turn for.
+const (
+	StopReasonEndTurn   = "end_turn"
+	StopReasonMaxTokens = "max_tokens"
+	StopReasonMaxTurns  = "max_turn_requests"
+	StopReasonRefusal   = "refusal"
+	StopReasonCancelled = "cancelled"
+)
+
+type InitializeParams struct {
+	ProtocolVersion    int                `json:"protocolVersion"`
+	ClientCapabilities ClientCapabilities `json:"clientCapabilities"`
+}
+
+type ClientCapabilities struct {
+	FS       FSCapabilities `json:"fs"`
+	Terminal bool           `json:"terminal,omitempty"`
+}
+
+type FSCapabilities struct {
+	ReadTextFile  bool `json:"readTextFile"`
+	WriteTextFile bool `json:"writeTextFile"`
+}
+
+type InitializeResult struct {
+	ProtocolVersion   int               `json:"protocolVersion"`
+	AgentCapabilities AgentCapabilities `json:"agentCapabilities"`
+	AuthMethods       []AuthMethod      `json:"authMethods"`
+}
+
+type AgentCapabilities struct {
+	LoadSession        bool               `json:"loadSession"`
+	PromptCapabilities PromptCapabilities `json:"promptCapabilities"`
+}
+
+type PromptCapabilities struct {
+	Image           bool `json:"image"`
+	Audio           bool `json:"audio"`
+	EmbeddedContext bool `json:"embeddedContext"`
+}
+
+type AuthMethod struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// MCPServer is an MCP server the client asks the agent to connect to.
+type MCPServer struct {
+	Name    string `json:"name"`
+	Command string `json:"command,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+type NewSessionParams struct {
+	CWD        string      `json:"cwd"`
+	MCPServers []MCPServer `json:"mcpServers"`
+}
+
+type NewSessionResult struct {
+	SessionID string `json:"sessionId"`
+}
+
+type LoadSessionParams struct {
+	SessionID  string      `json:"sessionId"`
+	CWD        string      `json:"cwd"`
+	MCPServers []MCPServer `json:"mcpServers"`
+}
+
+type PromptParams struct {
+	SessionID string         `json:"sessionId"`
+	Prompt    []ContentBlock `json:"prompt"`
+}
+
+type PromptResult struct {
+	StopReason string `json:"stopReason"`
+}
+
+type CancelParams struct {
+	SessionID string `json:"sessionId"`
+}
+
+// ContentBlock is a block of content of a prompt or an update, its fields
+// depend on its type: text, image, audio, resource_link or resource.
+type ContentBlock struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	URI      string            `json:"uri,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Resource *EmbeddedResource `json:"resource,omitempty"`
+}
+
+type EmbeddedResource struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+func TextBlock(text string) ContentBlock {
+	return ContentBlock{Type: "text", Text: text}
+}
+
+type SessionNotification struct {
+	SessionID string        `json:"sessionId"`
+	Update    SessionUpdate `json:"update"`
+}
+
+// SessionUpdate is an update of a session, its fields depend on its kind
+// given by SessionUpdate: a chunk of a message, or a tool call or an update
+// of it.
+type SessionUpdate struct {
+	SessionUpdate string             `json:"sessionUpdate,omitempty"`
+	Content       *ContentBlock      `json:"content,omitempty"`
+	ToolCallID    string             `json:"toolCallId,omitempty"`
+	Title         string             `json:"title,omitempty"`
+	Kind          string             `json:"kind,omitempty"`
+	Status        string             `json:"status,omitempty"`
+	ToolContent   []ToolCallContent  `json:"-"`
+	Locations     []ToolCallLocation `json:"locations,omitempty"`
+	RawInput      json.RawMessage    `json:"rawInput,omitempty"`
+	RawOutput     json.RawMessage    `json:"rawOutput,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, the content of the tool calls
+// shares the content key with the one of the message chunks.
+func (u SessionUpdate) MarshalJSON() ([]byte, error) {
+	type update SessionUpdate
+	if u.ToolContent == nil {
+		return json.Marshal(update(u))
+	}
+	return json.Marshal(struct {
+		update
+		Content []ToolCallContent `json:"content"`
+	}{update: update(u), Content: u.ToolContent})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the content being a list for
+// the tool calls.
+func (u *SessionUpdate) UnmarshalJSON(data []byte) error {
+	type update SessionUpdate
+	var v struct {
+		update
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*u = SessionUpdate(v.update)
+	content := bytes.TrimSpace(v.Content)
+	switch {
+	case len(content) == 0 || bytes.Equal(content, []byte("null")):
+		return nil
+	case content[0] == '[':
+		return json.Unmarshal(content, &u.ToolContent)
+	default:
+		u.Content = new(ContentBlock)
+		return json.Unmarshal(content, u.Content)
+	}
+}
+
+// ToolCallContent is the content of a tool call, a block of content or the
+// diff of a file.
+type ToolCallContent struct {
+	Type    string        `json:"type"`
+	Content *ContentBlock `json:"content,omitempty"`
+	Path    string        `json:"path,omitempty"`
+	OldText string        `json:"oldText,omitempty"`
+	NewText string        `json:"newText,omitempty"`
+}
+
+type ToolCallLocation struct {
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+}
+
+type RequestPermissionParams struct {
+	SessionID string             `json:"sessionId"`
+	ToolCall  SessionUpdate      `json:"toolCall"`
+	Options   []PermissionOption `json:"options"`
+}
+
+type PermissionOption struct {
+	OptionID string `json:"optionId"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+}
+
+type RequestPermissionResult struct {
+	Outcome PermissionOutcome `json:"outcome"`
+}
+
+type PermissionOutcome struct {
+	Outcome  string `json:"outcome"`
+	OptionID string `json:"optionId,omitempty"`
+}
+
+type ReadTextFileParams struct {
+	SessionID string `json:"sessionId"`
+	Path      string `json:"path"`
+}
+
+type ReadTextFileResult struct {
+	Content string `json:"content"`
+}
+
+type WriteTextFileParams struct {
+	SessionID string `json:"sessionId"`
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+}
@@ -0,0 +1,152 @@
+package acp
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// clientSession is a session the client created or loaded. The messages are
+// whole on every event, so it keeps track of what was already sent to the
+// client.
+type clientSession struct {
+	id  string
+	cwd string
+
+	// mu is held while sending the updates, for them to arrive in order.
+	mu             sync.Mutex
+	cancelled      bool
+	textBytes      map[string]int
+	reasoningBytes map[string]int
+	toolCalls      map[string]bool
+	toolResults    map[string]bool
+}
+
+func newClientSession(id, cwd string) *clientSession {
+	return &clientSession{
+		id:             id,
+		cwd:            cwd,
+		textBytes:      make(map[string]int),
+		reasoningBytes: make(map[string]int),
+		toolCalls:      make(map[string]bool),
+		toolResults:    make(map[string]bool),
+	}
+}
+
+// updates returns the updates of the message the client didn't get yet. The
+// messages of the user are only sent when replaying the session, the client
+// already has the others.
+func (s *clientSession) updates(msg message.Message, replay bool) []SessionUpdate {
+	var updates []SessionUpdate
+	switch msg.Role {
+	case message.User:
+		if !replay {
+			return nil
+		}
+		if text := msg.Content().Text; text != "" {
+			block := TextBlock(text)
+			updates = append(updates, SessionUpdate{SessionUpdate: UpdateUserMessageChunk, Content: &block})
+		}
+	case message.Assistant:
+		thinking := msg.ReasoningContent().Thinking
+		if sent := s.reasoningBytes[msg.ID]; len(thinking) > sent {
+			block := TextBlock(thinking[sent:])
+			updates = append(updates, SessionUpdate{SessionUpdate: UpdateAgentThoughtChunk, Content: &block})
+			s.reasoningBytes[msg.ID] = len(thinking)
+		}
+		text := msg.Content().Text
+		if sent := s.textBytes[msg.ID]; len(text) > sent {
+			block := TextBlock(text[sent:])
+			updates = append(updates, SessionUpdate{SessionUpdate: UpdateAgentMessageChunk, Content: &block})
+			s.textBytes[msg.ID] = len(text)
+		}
+		for _, call := range msg.ToolCalls() {
+			if !call.Finished || s.toolCalls[call.ID] {
+				continue
+			}
+			s.toolCalls[call.ID] = true
+			updates = append(updates, s.toolCall(call))
+		}
+	case message.Tool:
+		for _, result := range msg.ToolResults() {
+			if s.toolResults[result.ToolCallID] {
+				continue
+			}
+			s.toolResults[result.ToolCallID] = true
+			status := ToolCallStatusCompleted
+			if result.IsError {
+				status = ToolCallStatusFailed
+			}
+			update := SessionUpdate{
+				SessionUpdate: UpdateToolCallUpdate,
+				ToolCallID:    result.ToolCallID,
+				Status:        status,
+			}
+			if result.Data == "" {
+				block := TextBlock(result.Content)
+				update.ToolContent = []ToolCallContent{{Type: "content", Content: &block}}
+			}
+			updates = append(updates, update)
+		}
+	}
+	return updates
+}
+
+func (s *clientSession) toolCall(call message.ToolCall) SessionUpdate {
+	update := SessionUpdate{
+		SessionUpdate: UpdateToolCall,
+		ToolCallID:    call.ID,
+		Title:         call.Name,
+		Kind:          toolKind(call.Name),
+		Status:        ToolCallStatusInProgress,
+	}
+	var input map[string]any
+	if err := json.Unmarshal([]byte(call.Input), &input); err != nil {
+		return update
+	}
+	update.RawInput = json.RawMessage(call.Input)
+	for _, key := range []string{"command", "file_path", "path", "pattern", "url"} {
+		if value, ok := input[key].(string); ok && value != "" {
+			update.Title = call.Name + " " + value
+			break
+		}
+	}
+	for _, key := range []string{"file_path", "path"} {
+		if path, ok := input[key].(string); ok && path != "" {
+			update.Locations = []ToolCallLocation{{Path: s.absPath(path)}}
+			break
+		}
+	}
+	return update
+}
+
+func (s *clientSession) absPath(path string) string {
+	if filepath.IsAbs(path) || s.cwd == "" {
+		return path
+	}
+	return filepath.Join(s.cwd, path)
+}
+
+// toolKind returns the kind of the tool, for the client to pick an icon.
+func toolKind(name string) string {
+	switch name {
+	case tools.ViewToolName:
+		return "read"
+	case tools.EditToolName, tools.MultiEditToolName, tools.WriteToolName:
+		return "edit"
+	case tools.GlobToolName, tools.GrepToolName, tools.LSToolName, tools.SourcegraphToolName:
+		return "search"
+	case tools.BashToolName:
+		return "execute"
+	case tools.FetchToolName, tools.DownloadToolName:
+		return "fetch"
+	case agent.AgentToolName:
+		return "think"
+	default:
+		return "other"
+	}
+}
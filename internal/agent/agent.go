@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,6 +24,8 @@ import (
 	"github.com/charmbracelet/crush/internal/agent/tools"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/hooks"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/session"
@@ -44,6 +48,29 @@ type SessionAgentCall struct {
 	TopK             *int64
 	FrequencyPenalty *float64
 	PresencePenalty  *float64
+	// AllowedTools restricts the tools available for this call, nil means
+	// all the agent tools are available.
+	AllowedTools []string
+	// PlanMode restricts the agent to the read-only tools and asks it to
+	// submit a plan instead of making changes.
+	PlanMode bool
+	// CostBudget and TokenBudget stop the agent once the call used that much,
+	// 0 means no limit.
+	CostBudget  float64
+	TokenBudget int64
+	// KeepTitle keeps the title of the session instead of generating one
+	// from the first prompt.
+	KeepTitle bool
+	// MaxTurns stops the agent after that many requests to the model, 0
+	// means no limit.
+	MaxTurns int
+
+	// promptHooksRan is set once the prompt submit hooks ran for the call,
+	// so they don't run again when it's taken from the queue.
+	promptHooksRan bool
+	// turnEndFeedback counts the prompts in a row that are the feedback of
+	// the turn end hooks.
+	turnEndFeedback int
 }
 
 type SessionAgent interface {
@@ -64,6 +91,11 @@ type Model struct {
 	Model      fantasy.LanguageModel
 	CatwalkCfg catwalk.Model
 	ModelCfg   config.SelectedModel
+	// Retry is the retry policy for the provider retryable errors.
+	Retry fantasy.RetryOptions
+	// Fallbacks are tried in order when the provider fails with a retryable
+	// error.
+	Fallbacks []Model
 }
 
 type sessionAgent struct {
@@ -76,6 +108,10 @@ type sessionAgent struct {
 	messages             message.Service
 	disableAutoSummarize bool
 	isYolo               bool
+	budget               config.Budget
+	compaction           config.Compaction
+	history              history.Service
+	hooks                *hooks.Runner
 
 	messageQueue   *csync.Map[string, []SessionAgentCall]
 	activeRequests *csync.Map[string, context.CancelFunc]
@@ -91,6 +127,13 @@ type SessionAgentOptions struct {
 	Sessions             session.Service
 	Messages             message.Service
 	Tools                []fantasy.AgentTool
+	Budget               config.Budget
+	Compaction           config.Compaction
+	// History records a checkpoint of the files of the session at each user
+	// message when set.
+	History history.Service
+	// Hooks runs the session lifecycle hooks, nil runs none.
+	Hooks *hooks.Runner
 }
 
 func NewSessionAgent(
@@ -106,6 +149,10 @@ func NewSessionAgent(
 		disableAutoSummarize: opts.DisableAutoSummarize,
 		tools:                opts.Tools,
 		isYolo:               opts.IsYolo,
+		budget:               opts.Budget,
+		compaction:           opts.Compaction,
+		history:              opts.History,
+		hooks:                opts.Hooks,
 		messageQueue:         csync.NewMap[string, []SessionAgentCall](),
 		activeRequests:       csync.NewMap[string, context.CancelFunc](),
 	}
@@ -119,6 +166,30 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		return nil, ErrSessionMissing
 	}
 
+	hookRunner := a.hookRunner(call.SessionID)
+	submitPrompt := func(ctx context.Context) error {
+		if call.promptHooksRan {
+			return nil
+		}
+		prompt, err := hookRunner.PromptSubmit(ctx, call.SessionID, call.Prompt)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		call.Prompt = prompt
+		call.promptHooksRan = true
+		return nil
+	}
+
+	// the hooks of a queued prompt run before it's queued, so the caller
+	// learns when they block it
+	if a.IsSessionBusy(call.SessionID) {
+		if err := submitPrompt(ctx); err != nil {
+			return nil, err
+		}
+	}
 	// Queue the message if busy
 	if a.IsSessionBusy(call.SessionID) {
 		existing, ok := a.messageQueue.Get(call.SessionID)
@@ -130,15 +201,47 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		return nil, nil
 	}
 
+	// the session is busy from now on, while the hooks run too, so the
+	// prompts sent meanwhile are queued. The hooks run on genCtx for a
+	// cancel to stop them.
+	ctx = context.WithValue(ctx, tools.SessionIDContextKey, call.SessionID)
+	genCtx, cancel := context.WithCancel(ctx)
+	a.activeRequests.Set(call.SessionID, cancel)
+
+	defer cancel()
+	defer a.activeRequests.Del(call.SessionID)
+
+	if err := submitPrompt(genCtx); err != nil {
+		return nil, err
+	}
+
 	if len(a.tools) > 0 {
 		// add anthropic caching to the last tool
 		a.tools[len(a.tools)-1].SetProviderOptions(a.getCacheControlOptions())
 	}
 
+	agentTools := a.tools
+	if call.AllowedTools != nil {
+		agentTools = filterTools(a.tools, call.AllowedTools)
+	}
+	systemPrompt := a.systemPrompt
+	if call.PlanMode {
+		agentTools = planModeTools(agentTools)
+		systemPrompt += "\n\n" + string(planModePrompt)
+	}
+
+	// the media returned by the tools during the run, by tool call ID
+	toolMedia := csync.NewMap[string, tools.Media]()
+	// the stubs of the tool results pruned during the run, by tool call ID
+	prunedResults := make(map[string]string)
+
+	// the model answering the steps, it changes when the provider fails and
+	// falls back to the next model
+	model := newFallbackModel(a.largeModel)
 	agent := fantasy.NewAgent(
-		a.largeModel.Model,
-		fantasy.WithSystemPrompt(a.systemPrompt),
-		fantasy.WithTools(a.tools...),
+		model,
+		fantasy.WithSystemPrompt(systemPrompt),
+		fantasy.WithTools(withToolMedia(agentTools, toolMedia)...),
 	)
 
 	sessionLock := sync.Mutex{}
@@ -147,6 +250,11 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
+	budget := newBudget(a.budget, a.sessions, call, currentSession)
+	if limit := budget.exceeded(ctx, currentSession); limit != nil {
+		return nil, budgetError(limit)
+	}
+
 	msgs, err := a.getSessionMessages(ctx, currentSession)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session messages: %w", err)
@@ -155,11 +263,22 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	var wg sync.WaitGroup
 	// Generate title if first message
 	if len(msgs) == 0 {
-		wg.Go(func() {
-			sessionLock.Lock()
-			a.generateTitle(ctx, &currentSession, call.Prompt)
-			sessionLock.Unlock()
-		})
+		sessionContext, err := hookRunner.SessionStart(genCtx, call.SessionID, call.Prompt)
+		if genCtx.Err() != nil {
+			return nil, genCtx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !call.KeepTitle {
+			prompt := call.Prompt
+			wg.Go(func() {
+				sessionLock.Lock()
+				a.generateTitle(ctx, &currentSession, prompt)
+				sessionLock.Unlock()
+			})
+		}
+		call.Prompt = hooks.AddContext(call.Prompt, sessionContext)
 	}
 
 	// Add the user message to the session
@@ -168,15 +287,6 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		return nil, err
 	}
 
-	// add the session to the context
-	ctx = context.WithValue(ctx, tools.SessionIDContextKey, call.SessionID)
-
-	genCtx, cancel := context.WithCancel(ctx)
-	a.activeRequests.Set(call.SessionID, cancel)
-
-	defer cancel()
-	defer a.activeRequests.Del(call.SessionID)
-
 	history, files := a.preparePrompt(msgs, call.Attachments...)
 
 	startTime := time.Now()
@@ -184,6 +294,42 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 
 	var currentAssistant *message.Message
 	var shouldSummarize bool
+	var (
+		exceededBudget  *budgetLimit
+		reachedMaxTurns bool
+	)
+	// the latency of a step is the time the model took to answer, without
+	// running the tools
+	var (
+		stepStart   time.Time
+		stepLatency time.Duration
+	)
+	model.onFallback = func(_, to Model, _ error) {
+		if currentAssistant == nil {
+			return
+		}
+		// record the model that is answering the step
+		currentAssistant.Model = to.ModelCfg.Model
+		currentAssistant.Provider = to.ModelCfg.Provider
+		if updateErr := a.messages.Update(genCtx, *currentAssistant); updateErr != nil {
+			slog.Error("failed to update assistant message model", "error", updateErr)
+		}
+	}
+	model.onRetry = func(retried Model, attempt int, apiErr *fantasy.APICallError, delay time.Duration) {
+		var messageID string
+		if currentAssistant != nil {
+			messageID = currentAssistant.ID
+		}
+		a.messages.PublishRetry(newRetryEvent(call.SessionID, messageID, retried, attempt, apiErr, delay))
+
+		sessionLock.Lock()
+		currentSession.RetryCount++
+		_, saveErr := a.sessions.Save(genCtx, currentSession)
+		sessionLock.Unlock()
+		if saveErr != nil {
+			slog.Error("failed to save session retry count", "error", saveErr)
+		}
+	}
 	result, err := agent.Stream(genCtx, fantasy.AgentStreamCall{
 		Prompt:           call.Prompt,
 		Files:            files,
@@ -202,9 +348,10 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 			for i := range prepared.Messages {
 				prepared.Messages[i].ProviderOptions = nil
 			}
+			restoreToolMedia(prepared.Messages, toolMedia)
+			applyPrunedResults(prepared.Messages, prunedResults)
 
-			queuedCalls, _ := a.messageQueue.Get(call.SessionID)
-			a.messageQueue.Del(call.SessionID)
+			queuedCalls := a.takeQueuedCalls(call)
 			for _, queued := range queuedCalls {
 				userMessage, createErr := a.createUserMessage(callContext, queued)
 				if createErr != nil {
@@ -237,13 +384,14 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 			assistantMsg, err = a.messages.Create(callContext, call.SessionID, message.CreateMessageParams{
 				Role:     message.Assistant,
 				Parts:    []message.ContentPart{},
-				Model:    a.largeModel.ModelCfg.Model,
-				Provider: a.largeModel.ModelCfg.Provider,
+				Model:    model.Current().ModelCfg.Model,
+				Provider: model.Current().ModelCfg.Provider,
 			})
 			if err != nil {
 				return callContext, prepared, err
 			}
 			callContext = context.WithValue(callContext, tools.MessageIDContextKey, assistantMsg.ID)
+			callContext = tools.WithLoadedContextFiles(callContext, loadedContextFiles(prepared.Messages))
 			currentAssistant = &assistantMsg
 			return callContext, prepared, err
 		},
@@ -289,9 +437,6 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 			currentAssistant.AddToolCall(toolCall)
 			return a.messages.Update(genCtx, *currentAssistant)
 		},
-		OnRetry: func(err *fantasy.APICallError, delay time.Duration) {
-			// TODO: implement
-		},
 		OnToolCall: func(tc fantasy.ToolCallContent) error {
 			toolCall := message.ToolCall{
 				ID:               tc.ToolCallID,
@@ -318,8 +463,6 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 					isError = true
 					resultContent = r.Error.Error()
 				}
-			case fantasy.ToolResultContentTypeMedia:
-				// TODO: handle this message type
 			}
 			toolResult := message.ToolResult{
 				ToolCallID: result.ToolCallID,
@@ -328,6 +471,10 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 				IsError:    isError,
 				Metadata:   result.ClientMetadata,
 			}
+			if media, ok := toolMedia.Get(result.ToolCallID); ok {
+				toolResult.Data = media.Data
+				toolResult.MIMEType = media.MIMEType
+			}
 			_, createMsgErr := a.messages.Create(genCtx, currentAssistant.SessionID, message.CreateMessageParams{
 				Role: message.Tool,
 				Parts: []message.ContentPart{
@@ -339,7 +486,19 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 			}
 			return nil
 		},
+		OnStepStart: func(int) error {
+			stepStart = time.Now()
+			stepLatency = 0
+			return nil
+		},
+		OnStreamFinish: func(fantasy.Usage, fantasy.FinishReason, fantasy.ProviderMetadata) error {
+			stepLatency = time.Since(stepStart)
+			return nil
+		},
 		OnStepFinish: func(stepResult fantasy.StepResult) error {
+			if stepLatency == 0 {
+				stepLatency = time.Since(stepStart)
+			}
 			finishReason := message.FinishReasonUnknown
 			switch stepResult.FinishReason {
 			case fantasy.FinishReasonLength:
@@ -350,7 +509,7 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 				finishReason = message.FinishReasonToolUse
 			}
 			currentAssistant.AddFinish(finishReason, "", "")
-			a.updateSessionUsage(a.largeModel, &currentSession, stepResult.Usage, a.openrouterCost(stepResult.ProviderMetadata))
+			a.updateSessionUsage(genCtx, model.Current(), &currentSession, currentAssistant.ID, stepResult.Usage, a.openrouterCost(stepResult.ProviderMetadata), stepLatency)
 			sessionLock.Lock()
 			_, sessionErr := a.sessions.Save(genCtx, currentSession)
 			sessionLock.Unlock()
@@ -361,21 +520,40 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		},
 		StopWhen: []fantasy.StopCondition{
 			func(_ []fantasy.StepResult) bool {
-				cw := int64(a.largeModel.CatwalkCfg.ContextWindow)
+				sessionLock.Lock()
+				current := currentSession
+				sessionLock.Unlock()
+				exceededBudget = budget.exceeded(genCtx, current)
+				return exceededBudget != nil
+			},
+			func(steps []fantasy.StepResult) bool {
+				reachedMaxTurns = call.MaxTurns > 0 && len(steps) >= call.MaxTurns
+				return reachedMaxTurns
+			},
+			func(_ []fantasy.StepResult) bool {
+				cw := int64(model.Current().CatwalkCfg.ContextWindow)
 				tokens := currentSession.CompletionTokens + currentSession.PromptTokens
 				remaining := cw - tokens
-				var threshold int64
-				if cw > 200_000 {
-					threshold = 20_000
-				} else {
-					threshold = int64(float64(cw) * 0.2)
+				if remaining > compactionThreshold(a.compaction, cw) || a.disableAutoSummarize {
+					return false
 				}
-				if (remaining <= threshold) && !a.disableAutoSummarize {
-					shouldSummarize = true
-					return true
+				if a.compaction.Strategy == config.CompactionPruneToolResults {
+					pruned, pruneErr := a.pruneToolResults(genCtx, currentSession)
+					if pruneErr != nil {
+						slog.Error("failed to prune tool results", "error", pruneErr)
+					}
+					// keep going with the pruned results, summarize once
+					// there is nothing left to prune
+					if len(pruned) > 0 {
+						maps.Copy(prunedResults, pruned)
+						return false
+					}
 				}
-				return false
+				shouldSummarize = true
+				return true
 			},
+			// the user reviews the plan before going further
+			fantasy.HasToolCall(tools.PlanToolName),
 		},
 	})
 
@@ -461,6 +639,28 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	}
 	wg.Wait()
 
+	// the budget only matters when it stopped the agent
+	if exceededBudget != nil && currentAssistant.FinishReason() == message.FinishReasonToolUse {
+		event := exceededBudget.event(call.SessionID, true)
+		currentAssistant.AddFinish(message.FinishReasonBudgetExceeded, "Budget exceeded", event.Status())
+		if updateErr := a.messages.Update(ctx, *currentAssistant); updateErr != nil {
+			return nil, updateErr
+		}
+		a.sessions.PublishBudget(event)
+		// the queued prompts would go over the budget too
+		a.messageQueue.Del(call.SessionID)
+		return result, nil
+	}
+
+	if reachedMaxTurns && currentAssistant.FinishReason() == message.FinishReasonToolUse {
+		currentAssistant.AddFinish(message.FinishReasonMaxTurns, "Max turns reached", fmt.Sprintf("Stopped after %d turns", call.MaxTurns))
+		if updateErr := a.messages.Update(ctx, *currentAssistant); updateErr != nil {
+			return nil, updateErr
+		}
+		a.messageQueue.Del(call.SessionID)
+		return result, nil
+	}
+
 	if shouldSummarize {
 		a.activeRequests.Del(call.SessionID)
 		if summarizeErr := a.Summarize(genCtx, call.SessionID, call.ProviderOptions); summarizeErr != nil {
@@ -478,6 +678,15 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		}
 	}
 
+	// the session stays busy until the turn end hooks are done
+	if currentAssistant.FinishReason() == message.FinishReasonEndTurn {
+		a.runTurnEndHooks(genCtx, call)
+		// the user cancelled while the hooks ran
+		if genCtx.Err() != nil {
+			return result, nil
+		}
+	}
+
 	// release active request before processing queued messages
 	a.activeRequests.Del(call.SessionID)
 	cancel()
@@ -492,6 +701,103 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	return a.Run(ctx, firstQueuedMessage)
 }
 
+// hookRunner returns the runner of the lifecycle hooks of the session, the
+// sessions of the agent tool don't run them.
+func (a *sessionAgent) hookRunner(sessionID string) *hooks.Runner {
+	if a.sessions.IsAgentToolSession(sessionID) {
+		return nil
+	}
+	return a.hooks
+}
+
+// loadedContextFiles returns the context files loaded in the tool results of
+// the messages, the ones summarized or pruned away aren't there anymore.
+func loadedContextFiles(msgs []fantasy.Message) *csync.Map[string, bool] {
+	loaded := csync.NewMap[string, bool]()
+	for _, msg := range msgs {
+		if msg.Role != fantasy.MessageRoleTool {
+			continue
+		}
+		for _, part := range msg.Content {
+			result, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](part)
+			if !ok {
+				continue
+			}
+			text, ok := result.Output.(fantasy.ToolResultOutputContentText)
+			if !ok {
+				continue
+			}
+			for _, path := range tools.LoadedContextFiles(text.Text) {
+				loaded.Set(path, true)
+			}
+		}
+	}
+	return loaded
+}
+
+// runTurnEndHooks runs the turn end hooks and queues their feedback to be
+// sent to the agent before the other queued prompts.
+func (a *sessionAgent) runTurnEndHooks(ctx context.Context, call SessionAgentCall) {
+	feedback, err := a.hookRunner(call.SessionID).TurnEnd(ctx, call.SessionID, call.turnEndFeedback > 0)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		slog.Error("Turn end hook failed", "session_id", call.SessionID, "error", err)
+	}
+	if feedback == "" {
+		return
+	}
+	if call.turnEndFeedback >= maxTurnEndFeedback {
+		slog.Warn("Turn end hook feedback ignored, too many in a row", "session_id", call.SessionID)
+		return
+	}
+	next := call
+	next.Prompt = fmt.Sprintf("A hook run at the end of your turn reported the following, address it:\n\n%s", feedback)
+	next.Attachments = nil
+	next.turnEndFeedback++
+	queued, _ := a.messageQueue.Get(call.SessionID)
+	a.messageQueue.Set(call.SessionID, append([]SessionAgentCall{next}, queued...))
+}
+
+// takeQueuedCalls removes the queued calls that can be merged into the given
+// call from the queue, calls with different tool restrictions or plan mode
+// stay queued so they run on their own.
+func (a *sessionAgent) takeQueuedCalls(call SessionAgentCall) []SessionAgentCall {
+	queuedCalls, ok := a.messageQueue.Get(call.SessionID)
+	if !ok {
+		return nil
+	}
+	var taken, remaining []SessionAgentCall
+	for _, queued := range queuedCalls {
+		if len(remaining) == 0 && queued.PlanMode == call.PlanMode && sameTools(queued.AllowedTools, call.AllowedTools) {
+			taken = append(taken, queued)
+		} else {
+			remaining = append(remaining, queued)
+		}
+	}
+	if len(remaining) == 0 {
+		a.messageQueue.Del(call.SessionID)
+	} else {
+		a.messageQueue.Set(call.SessionID, remaining)
+	}
+	return taken
+}
+
+func sameTools(a, b []string) bool {
+	return (a == nil) == (b == nil) && slices.Equal(a, b)
+}
+
+func filterTools(agentTools []fantasy.AgentTool, allowedTools []string) []fantasy.AgentTool {
+	filtered := []fantasy.AgentTool{}
+	for _, tool := range agentTools {
+		if slices.Contains(allowedTools, tool.Info().Name) {
+			filtered = append(filtered, tool)
+		}
+	}
+	return filtered
+}
+
 func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fantasy.ProviderOptions) error {
 	if a.IsSessionBusy(sessionID) {
 		return ErrSessionBusy
@@ -510,6 +816,15 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 		return nil
 	}
 
+	// keep the last turns out of the summary
+	var keptMessageID string
+	if a.compaction.Strategy == config.CompactionKeepRecent {
+		if kept := keptMessagesIndex(msgs, a.compaction.KeepTurns); kept > 0 {
+			keptMessageID = msgs[kept].ID
+			msgs = msgs[:kept]
+		}
+	}
+
 	aiMsgs, _ := a.preparePrompt(msgs)
 
 	genCtx, cancel := context.WithCancel(ctx)
@@ -517,19 +832,32 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 	defer a.activeRequests.Del(sessionID)
 	defer cancel()
 
-	agent := fantasy.NewAgent(a.largeModel.Model,
+	model := newFallbackModel(a.largeModel)
+	agent := fantasy.NewAgent(model,
 		fantasy.WithSystemPrompt(string(summaryPrompt)),
 	)
 	summaryMessage, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
 		Role:             message.Assistant,
-		Model:            a.largeModel.Model.Model(),
-		Provider:         a.largeModel.Model.Provider(),
+		Model:            a.largeModel.ModelCfg.Model,
+		Provider:         a.largeModel.ModelCfg.Provider,
 		IsSummaryMessage: true,
 	})
 	if err != nil {
 		return err
 	}
+	model.onRetry = func(retried Model, attempt int, apiErr *fantasy.APICallError, delay time.Duration) {
+		a.messages.PublishRetry(newRetryEvent(sessionID, summaryMessage.ID, retried, attempt, apiErr, delay))
+		currentSession.RetryCount++
+	}
+	model.onFallback = func(_, to Model, _ error) {
+		summaryMessage.Model = to.ModelCfg.Model
+		summaryMessage.Provider = to.ModelCfg.Provider
+		if updateErr := a.messages.Update(genCtx, summaryMessage); updateErr != nil {
+			slog.Error("failed to update summary message model", "error", updateErr)
+		}
+	}
 
+	start := time.Now()
 	resp, err := agent.Stream(genCtx, fantasy.AgentStreamCall{
 		Prompt:          "Provide a detailed summary of our conversation above.",
 		Messages:        aiMsgs,
@@ -588,17 +916,32 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 		}
 	}
 
-	a.updateSessionUsage(a.largeModel, &currentSession, resp.TotalUsage, openrouterCost)
+	a.updateSessionUsage(genCtx, model.Current(), &currentSession, summaryMessage.ID, resp.TotalUsage, openrouterCost, time.Since(start))
 
 	// just in case get just the last usage
 	usage := resp.Response.Usage
 	currentSession.SummaryMessageID = summaryMessage.ID
+	currentSession.SummaryKeptMessageID = keptMessageID
 	currentSession.CompletionTokens = usage.OutputTokens
 	currentSession.PromptTokens = 0
 	_, err = a.sessions.Save(genCtx, currentSession)
 	return err
 }
 
+func newRetryEvent(sessionID, messageID string, model Model, attempt int, err *fantasy.APICallError, delay time.Duration) message.RetryEvent {
+	return message.RetryEvent{
+		SessionID:  sessionID,
+		MessageID:  messageID,
+		Provider:   model.ModelCfg.Provider,
+		Model:      model.ModelCfg.Model,
+		Attempt:    attempt,
+		MaxRetries: model.Retry.MaxRetries,
+		Delay:      delay,
+		StatusCode: err.StatusCode,
+		Error:      err.Error(),
+	}
+}
+
 func (a *sessionAgent) getCacheControlOptions() fantasy.ProviderOptions {
 	if t, _ := strconv.ParseBool(os.Getenv("CRUSH_DISABLE_ANTHROPIC_CACHE")); t {
 		return fantasy.ProviderOptions{}
@@ -627,6 +970,11 @@ func (a *sessionAgent) createUserMessage(ctx context.Context, call SessionAgentC
 	if err != nil {
 		return message.Message{}, fmt.Errorf("failed to create user message: %w", err)
 	}
+	if a.history != nil {
+		if _, err := a.history.CreateCheckpoint(ctx, call.SessionID, msg.ID); err != nil {
+			slog.Error("failed to create checkpoint", "error", err)
+		}
+	}
 	return msg, nil
 }
 
@@ -670,13 +1018,37 @@ func (a *sessionAgent) getSessionMessages(ctx context.Context, session session.S
 			}
 		}
 		if summaryMsgInex != -1 {
-			msgs = msgs[summaryMsgInex:]
-			msgs[0].Role = message.User
+			kept := keptMessages(msgs[:summaryMsgInex], session.SummaryKeptMessageID)
+			summary := msgs[summaryMsgInex]
+			summary.Role = message.User
+			msgs = append(append([]message.Message{summary}, kept...), msgs[summaryMsgInex+1:]...)
 		}
 	}
 	return msgs, nil
 }
 
+// keptMessages returns the messages kept verbatim after the summary, from
+// the given message on.
+func keptMessages(msgs []message.Message, keptMessageID string) []message.Message {
+	if keptMessageID == "" {
+		return nil
+	}
+	for i, msg := range msgs {
+		if msg.ID != keptMessageID {
+			continue
+		}
+		var kept []message.Message
+		for _, msg := range msgs[i:] {
+			// the previous summaries are replaced by the new one
+			if !msg.IsSummaryMessage {
+				kept = append(kept, msg)
+			}
+		}
+		return kept
+	}
+	return nil
+}
+
 func (a *sessionAgent) generateTitle(ctx context.Context, session *session.Session, prompt string) {
 	if prompt == "" {
 		return
@@ -687,11 +1059,13 @@ func (a *sessionAgent) generateTitle(ctx context.Context, session *session.Sessi
 		maxOutput = a.smallModel.CatwalkCfg.DefaultMaxTokens
 	}
 
-	agent := fantasy.NewAgent(a.smallModel.Model,
+	model := newFallbackModel(a.smallModel)
+	agent := fantasy.NewAgent(model,
 		fantasy.WithSystemPrompt(string(titlePrompt)+"\n /no_think"),
 		fantasy.WithMaxOutputTokens(maxOutput),
 	)
 
+	start := time.Now()
 	resp, err := agent.Stream(ctx, fantasy.AgentStreamCall{
 		Prompt: fmt.Sprintf("Generate a concise title for the following content:\n\n%s\n <think>\n\n</think>", prompt),
 		PrepareStep: func(callContext context.Context, options fantasy.PrepareStepFunctionOptions) (_ context.Context, prepared fantasy.PrepareStepResult, err error) {
@@ -736,7 +1110,7 @@ func (a *sessionAgent) generateTitle(ctx context.Context, session *session.Sessi
 		}
 	}
 
-	a.updateSessionUsage(a.smallModel, session, resp.TotalUsage, openrouterCost)
+	a.updateSessionUsage(ctx, model.Current(), session, "", resp.TotalUsage, openrouterCost, time.Since(start))
 	_, saveErr := a.sessions.Save(ctx, *session)
 	if saveErr != nil {
 		slog.Error("failed to save session title & usage", "error", saveErr)
@@ -757,23 +1131,41 @@ func (a *sessionAgent) openrouterCost(metadata fantasy.ProviderMetadata) *float6
 	return &opts.Usage.Cost
 }
 
-func (a *sessionAgent) updateSessionUsage(model Model, session *session.Session, usage fantasy.Usage, overrideCost *float64) {
+// updateSessionUsage adds the usage of a request to the session, and records
+// it for the usage reports.
+func (a *sessionAgent) updateSessionUsage(ctx context.Context, model Model, sess *session.Session, messageID string, usage fantasy.Usage, overrideCost *float64, latency time.Duration) {
 	modelConfig := model.CatwalkCfg
 	cost := modelConfig.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
 		modelConfig.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
 		modelConfig.CostPer1MIn/1e6*float64(usage.InputTokens) +
 		modelConfig.CostPer1MOut/1e6*float64(usage.OutputTokens)
 
-	a.eventTokensUsed(session.ID, model, usage, cost)
+	a.eventTokensUsed(sess.ID, model, usage, cost)
 
 	if overrideCost != nil {
-		session.Cost += *overrideCost
-	} else {
-		session.Cost += cost
+		cost = *overrideCost
+	}
+	sess.Cost += cost
+
+	sess.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
+	sess.PromptTokens = usage.InputTokens + usage.CacheCreationTokens
+	sess.TotalTokens += sess.CompletionTokens + sess.PromptTokens
+
+	err := a.sessions.RecordUsage(ctx, session.StepUsage{
+		SessionID:           sess.ID,
+		MessageID:           messageID,
+		Provider:            model.ModelCfg.Provider,
+		Model:               model.ModelCfg.Model,
+		InputTokens:         usage.InputTokens,
+		OutputTokens:        usage.OutputTokens,
+		CacheReadTokens:     usage.CacheReadTokens,
+		CacheCreationTokens: usage.CacheCreationTokens,
+		Cost:                cost,
+		Latency:             latency,
+	})
+	if err != nil {
+		slog.Error("failed to record usage", "session_id", sess.ID, "error", err)
 	}
-
-	session.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
-	session.PromptTokens = usage.InputTokens + usage.CacheCreationTokens
 }
 
 func (a *sessionAgent) Cancel(sessionID string) {
@@ -1,14 +1,23 @@
 package agent
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"charm.land/fantasy"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/shell"
 	"github.com/stretchr/testify/assert"
@@ -617,3 +626,651 @@ func TestCoderAgent(t *testing.T) {
 		})
 	}
 }
+
+func TestSessionAgentAllowedTools(t *testing.T) {
+	env := testEnv(t)
+	allTools := []fantasy.AgentTool{
+		tools.NewBashTool(env.permissions, env.workingDir, &config.Attribution{}),
+		tools.NewEditTool(env.lspClients, env.permissions, env.history, env.workingDir, nil),
+		tools.NewGlobTool(env.workingDir),
+		tools.NewGrepTool(env.workingDir),
+		tools.NewViewTool(env.lspClients, env.permissions, env.workingDir, nil),
+		tools.NewWriteTool(env.lspClients, env.permissions, env.history, env.workingDir, nil),
+	}
+
+	t.Run("all tools", func(t *testing.T) {
+		large := &fakeModel{text: "Hello"}
+		agent := testSessionAgent(env, large, &fakeModel{text: "Title"}, "system", allTools...)
+
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+		_, err = agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Hello",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, [][]string{{"bash", "edit", "glob", "grep", "view", "write"}}, large.toolNames())
+	})
+
+	t.Run("read only tools", func(t *testing.T) {
+		large := &fakeModel{text: "Hello"}
+		agent := testSessionAgent(env, large, &fakeModel{text: "Title"}, "system", allTools...)
+
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+		_, err = agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Review the code",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+			AllowedTools:    []string{"glob", "grep", "view"},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, [][]string{{"glob", "grep", "view"}}, large.toolNames())
+	})
+
+	t.Run("no tools", func(t *testing.T) {
+		large := &fakeModel{text: "Hello"}
+		agent := testSessionAgent(env, large, &fakeModel{text: "Title"}, "system", allTools...)
+
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+		_, err = agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Hello",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+			AllowedTools:    []string{},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, [][]string{{}}, large.toolNames())
+	})
+}
+
+func TestSessionAgentPlanMode(t *testing.T) {
+	env := testEnv(t)
+	large := &fakeModel{
+		text: "Done",
+		toolCall: &fantasy.ToolCallContent{
+			ToolCallID: "call-1",
+			ToolName:   tools.PlanToolName,
+			Input:      `{"title": "Add a flag", "summary": "Add a --verbose flag", "steps": [{"description": "Add the flag to the command"}]}`,
+		},
+	}
+	agent := testSessionAgent(env, large, &fakeModel{text: "Title"}, "system",
+		tools.NewBashTool(env.permissions, env.workingDir, &config.Attribution{}),
+		tools.NewEditTool(env.lspClients, env.permissions, env.history, env.workingDir, nil),
+		tools.NewGlobTool(env.workingDir),
+		tools.NewGrepTool(env.workingDir),
+		tools.NewViewTool(env.lspClients, env.permissions, env.workingDir, nil),
+	)
+
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	_, err = agent.Run(t.Context(), SessionAgentCall{
+		Prompt:          "Add a flag",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+		PlanMode:        true,
+	})
+	require.NoError(t, err)
+
+	// the run stops once the plan is submitted
+	require.Equal(t, [][]string{{"glob", "grep", "view", tools.PlanToolName}}, large.toolNames())
+	require.Contains(t, large.calls[0].Prompt[0].Content[0].(fantasy.TextPart).Text, "<plan_mode>")
+
+	msgs, err := env.messages.List(t.Context(), session.ID)
+	require.NoError(t, err)
+	var results []message.ToolResult
+	for _, msg := range msgs {
+		results = append(results, msg.ToolResults()...)
+	}
+	require.Len(t, results, 1)
+	require.False(t, results[0].IsError)
+	var meta tools.PlanResponseMetadata
+	require.NoError(t, json.Unmarshal([]byte(results[0].Metadata), &meta))
+	require.Equal(t, "Add a flag", meta.Plan.Title)
+	require.Len(t, meta.Plan.Steps, 1)
+}
+
+func TestSessionAgentPlanModeQueue(t *testing.T) {
+	env := testEnv(t)
+	large := &fakeModel{
+		text: "Done",
+		toolCall: &fantasy.ToolCallContent{
+			ToolCallID: "call-1",
+			ToolName:   tools.PlanToolName,
+			Input:      `{"title": "Add a flag", "summary": "Add a --verbose flag", "steps": [{"description": "Add the flag to the command"}]}`,
+		},
+	}
+	agent := testSessionAgent(env, large, &fakeModel{text: "Title"}, "system",
+		tools.NewBashTool(env.permissions, env.workingDir, &config.Attribution{}),
+		tools.NewGlobTool(env.workingDir),
+	)
+
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	// the plan approved while the plan run is still going
+	agent.(*sessionAgent).messageQueue.Set(session.ID, []SessionAgentCall{{
+		Prompt:          "Implement the plan",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+		promptHooksRan:  true,
+	}})
+	_, err = agent.Run(t.Context(), SessionAgentCall{
+		Prompt:          "Add a flag",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+		PlanMode:        true,
+	})
+	require.NoError(t, err)
+
+	// the approved prompt runs on its own, out of plan mode
+	require.Equal(t, [][]string{{"glob", tools.PlanToolName}, {"bash", "glob"}}, large.toolNames())
+	require.NotContains(t, large.calls[1].Prompt[0].Content[0].(fantasy.TextPart).Text, "<plan_mode>")
+}
+
+func TestSessionAgentModelFallback(t *testing.T) {
+	env := testEnv(t)
+	catwalkCfg := catwalk.Model{
+		ContextWindow:    200000,
+		DefaultMaxTokens: 10000,
+	}
+	overloaded := fantasy.NewAPICallError("overloaded", "", "", 529, nil, "", nil, false)
+	unauthorized := fantasy.NewAPICallError("unauthorized", "", "", 401, nil, "", nil, false)
+
+	newAgent := func(large Model) SessionAgent {
+		small := Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg}
+		return NewSessionAgent(SessionAgentOptions{large, small, "", "system", false, true, env.sessions, env.messages, nil, config.Budget{}, config.Compaction{}, nil, nil})
+	}
+	run := func(t *testing.T, agent SessionAgent) (message.Message, error) {
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+		_, runErr := agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Hello",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+		})
+		msgs, err := env.messages.List(t.Context(), session.ID)
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+		return msgs[1], runErr
+	}
+
+	t.Run("falls back on retryable errors", func(t *testing.T) {
+		primary := &fakeModel{err: overloaded}
+		second := &fakeModel{err: overloaded}
+		third := &fakeModel{text: "Hello from the fallback"}
+		agent := newAgent(Model{
+			Model:      primary,
+			CatwalkCfg: catwalkCfg,
+			ModelCfg:   config.SelectedModel{Provider: "anthropic", Model: "claude"},
+			Fallbacks: []Model{
+				{Model: second, CatwalkCfg: catwalkCfg, ModelCfg: config.SelectedModel{Provider: "bedrock", Model: "claude"}},
+				{Model: third, CatwalkCfg: catwalkCfg, ModelCfg: config.SelectedModel{Provider: "openrouter", Model: "claude"}},
+			},
+		})
+
+		assistant, err := run(t, agent)
+		require.NoError(t, err)
+		require.Equal(t, 1, primary.callCount())
+		require.Equal(t, 1, second.callCount())
+		require.Equal(t, 1, third.callCount())
+		require.Equal(t, "Hello from the fallback", assistant.Content().Text)
+		require.Equal(t, "openrouter", assistant.Provider)
+		require.Equal(t, "claude", assistant.Model)
+	})
+
+	t.Run("retries before falling back", func(t *testing.T) {
+		retry := fantasy.RetryOptions{MaxRetries: 2, InitialDelayIn: time.Millisecond, BackoffFactor: 2}
+		primary := &fakeModel{err: overloaded}
+		fallback := &fakeModel{text: "Hello from the fallback"}
+		agent := newAgent(Model{
+			Model:      primary,
+			CatwalkCfg: catwalkCfg,
+			ModelCfg:   config.SelectedModel{Provider: "anthropic", Model: "claude"},
+			Retry:      retry,
+			Fallbacks: []Model{
+				{Model: fallback, CatwalkCfg: catwalkCfg, ModelCfg: config.SelectedModel{Provider: "openrouter", Model: "claude"}, Retry: retry},
+			},
+		})
+
+		assistant, err := run(t, agent)
+		require.NoError(t, err)
+		require.Equal(t, 3, primary.callCount())
+		require.Equal(t, 1, fallback.callCount())
+		require.Equal(t, "openrouter", assistant.Provider)
+	})
+
+	t.Run("does not fall back on other errors", func(t *testing.T) {
+		primary := &fakeModel{err: unauthorized}
+		fallback := &fakeModel{text: "Hello from the fallback"}
+		agent := newAgent(Model{
+			Model:      primary,
+			CatwalkCfg: catwalkCfg,
+			ModelCfg:   config.SelectedModel{Provider: "anthropic", Model: "claude"},
+			Fallbacks: []Model{
+				{Model: fallback, CatwalkCfg: catwalkCfg, ModelCfg: config.SelectedModel{Provider: "openrouter", Model: "claude"}},
+			},
+		})
+
+		assistant, err := run(t, agent)
+		require.ErrorIs(t, err, unauthorized)
+		require.Equal(t, 0, fallback.callCount())
+		require.Equal(t, "anthropic", assistant.Provider)
+		require.Equal(t, message.FinishReasonError, assistant.FinishReason())
+	})
+
+	t.Run("summarizes with the fallback", func(t *testing.T) {
+		primary := &fakeModel{err: overloaded}
+		fallback := &fakeModel{text: "The summary"}
+		agent := newAgent(Model{
+			Model:      primary,
+			CatwalkCfg: catwalkCfg,
+			ModelCfg:   config.SelectedModel{Provider: "anthropic", Model: "claude"},
+			Fallbacks: []Model{
+				{Model: fallback, CatwalkCfg: catwalkCfg, ModelCfg: config.SelectedModel{Provider: "openrouter", Model: "claude"}},
+			},
+		})
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+		_, err = env.messages.Create(t.Context(), session.ID, message.CreateMessageParams{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: "Hello"}},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, agent.Summarize(t.Context(), session.ID, nil))
+		msgs, err := env.messages.List(t.Context(), session.ID)
+		require.NoError(t, err)
+		summary := msgs[len(msgs)-1]
+		require.True(t, summary.IsSummaryMessage)
+		require.Equal(t, "The summary", summary.Content().Text)
+		// the configured model, not the name of the provider implementation
+		require.Equal(t, "openrouter", summary.Provider)
+		require.Equal(t, "claude", summary.Model)
+	})
+}
+
+func TestSessionAgentRetries(t *testing.T) {
+	env := testEnv(t)
+	catwalkCfg := catwalk.Model{
+		ContextWindow:    200000,
+		DefaultMaxTokens: 10000,
+	}
+	large := &fakeModel{
+		text:     "Hello",
+		err:      fantasy.NewAPICallError("rate limited", "", "", 429, nil, "", nil, false),
+		failures: 2,
+	}
+	agent := NewSessionAgent(SessionAgentOptions{
+		LargeModel: Model{
+			Model:      large,
+			CatwalkCfg: catwalkCfg,
+			ModelCfg:   config.SelectedModel{Provider: "anthropic", Model: "claude"},
+			Retry:      fantasy.RetryOptions{MaxRetries: 3, InitialDelayIn: time.Millisecond, BackoffFactor: 2},
+		},
+		SmallModel: Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg},
+		IsYolo:     true,
+		Sessions:   env.sessions,
+		Messages:   env.messages,
+	})
+
+	retries := env.messages.SubscribeRetries(t.Context())
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	_, err = agent.Run(t.Context(), SessionAgentCall{
+		Prompt:          "Hello",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, large.callCount())
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		event := <-retries
+		require.Equal(t, session.ID, event.Payload.SessionID)
+		require.NotEmpty(t, event.Payload.MessageID)
+		require.Equal(t, "anthropic", event.Payload.Provider)
+		require.Equal(t, attempt, event.Payload.Attempt)
+		require.Equal(t, 3, event.Payload.MaxRetries)
+		require.Equal(t, 429, event.Payload.StatusCode)
+	}
+
+	session, err = env.sessions.Get(t.Context(), session.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), session.RetryCount)
+}
+
+func TestSessionAgentUsage(t *testing.T) {
+	env := testEnv(t)
+	catwalkCfg := catwalk.Model{
+		ContextWindow:    200000,
+		DefaultMaxTokens: 10000,
+		CostPer1MIn:      3,
+		CostPer1MOut:     15,
+	}
+	large := &fakeModel{
+		text: "Done",
+		toolCall: &fantasy.ToolCallContent{
+			ToolCallID: "call-1",
+			ToolName:   tools.GlobToolName,
+			Input:      `{"pattern": "*.go"}`,
+		},
+		usage: fantasy.Usage{InputTokens: 1000, OutputTokens: 100, CacheReadTokens: 500},
+	}
+	agent := NewSessionAgent(SessionAgentOptions{
+		LargeModel: Model{
+			Model:      large,
+			CatwalkCfg: catwalkCfg,
+			ModelCfg:   config.SelectedModel{Provider: "anthropic", Model: "claude"},
+		},
+		SmallModel: Model{
+			Model:      &fakeModel{text: "Title"},
+			CatwalkCfg: catwalkCfg,
+			ModelCfg:   config.SelectedModel{Provider: "openai", Model: "mini"},
+		},
+		IsYolo:   true,
+		Sessions: env.sessions,
+		Messages: env.messages,
+		Tools:    []fantasy.AgentTool{tools.NewGlobTool(env.workingDir)},
+	})
+
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	_, err = agent.Run(t.Context(), SessionAgentCall{
+		Prompt:          "Find the go files",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+	})
+	require.NoError(t, err)
+
+	// one row per model, the title included
+	rows, err := env.sessions.ListUsage(t.Context(), time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	usage := rows[0]
+	require.Equal(t, session.ID, usage.SessionID)
+	require.Equal(t, "anthropic", usage.Provider)
+	require.Equal(t, "claude", usage.Model)
+	require.Equal(t, int64(2), usage.Requests)
+	require.Equal(t, int64(2000), usage.InputTokens)
+	require.Equal(t, int64(200), usage.OutputTokens)
+	require.Equal(t, int64(1000), usage.CacheReadTokens)
+	require.InDelta(t, 0.009, usage.Cost, 1e-9)
+	require.Equal(t, "openai", rows[1].Provider)
+	require.Equal(t, int64(1), rows[1].Requests)
+
+	session, err = env.sessions.Get(t.Context(), session.ID)
+	require.NoError(t, err)
+	daily, err := env.sessions.UsageSince(t.Context(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.InDelta(t, session.Cost, daily.Cost, 1e-9)
+	require.Equal(t, session.TotalTokens, daily.Tokens)
+}
+
+func TestSessionAgentBudget(t *testing.T) {
+	newAgent := func(env env, large *fakeModel, budget config.Budget) SessionAgent {
+		catwalkCfg := catwalk.Model{ContextWindow: 200000, DefaultMaxTokens: 10000}
+		return NewSessionAgent(SessionAgentOptions{
+			LargeModel: Model{Model: large, CatwalkCfg: catwalkCfg},
+			SmallModel: Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg},
+			IsYolo:     true,
+			Sessions:   env.sessions,
+			Messages:   env.messages,
+			Tools:      []fantasy.AgentTool{tools.NewGlobTool(env.workingDir)},
+			Budget:     budget,
+		})
+	}
+	newModel := func() *fakeModel {
+		return &fakeModel{
+			text:  "Done",
+			usage: fantasy.Usage{InputTokens: 100},
+			toolCall: &fantasy.ToolCallContent{
+				ToolCallID: "call-1",
+				ToolName:   tools.GlobToolName,
+				Input:      `{"pattern": "*.go"}`,
+			},
+		}
+	}
+
+	t.Run("stops the run", func(t *testing.T) {
+		env := testEnv(t)
+		large := newModel()
+		agent := newAgent(env, large, config.Budget{SessionTokens: 100})
+
+		budgets := env.sessions.SubscribeBudgets(t.Context())
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+		_, err = agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Find the go files",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, large.callCount())
+
+		msgs, err := env.messages.List(t.Context(), session.ID)
+		require.NoError(t, err)
+		assistant := msgs[len(msgs)-2]
+		require.Equal(t, message.Assistant, assistant.Role)
+		require.Equal(t, message.FinishReasonBudgetExceeded, assistant.FinishReason())
+
+		event := <-budgets
+		require.True(t, event.Payload.Exceeded)
+		require.Equal(t, "session token budget reached (100 tokens of 100 tokens)", event.Payload.Status())
+
+		// the next prompts are refused
+		_, err = agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Continue",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+		})
+		require.ErrorIs(t, err, ErrBudgetExceeded)
+		require.Equal(t, 1, large.callCount())
+	})
+
+	t.Run("run budget", func(t *testing.T) {
+		env := testEnv(t)
+		large := newModel()
+		agent := newAgent(env, large, config.Budget{})
+
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+		_, err = agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Find the go files",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+			TokenBudget:     100,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, large.callCount())
+
+		// a new run gets a new budget
+		_, err = agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Continue",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+			TokenBudget:     1000,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, large.callCount())
+	})
+
+	t.Run("warns before the limit", func(t *testing.T) {
+		env := testEnv(t)
+		large := newModel()
+		agent := newAgent(env, large, config.Budget{DailyTokens: 120})
+
+		budgets := env.sessions.SubscribeBudgets(t.Context())
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+		_, err = agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Find the go files",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+		})
+		require.NoError(t, err)
+		// the agent ended on its own after going over the budget
+		require.Equal(t, 2, large.callCount())
+
+		event := <-budgets
+		require.False(t, event.Payload.Exceeded)
+		require.Equal(t, "83% of the daily token budget used (100 tokens of 120 tokens)", event.Payload.Status())
+
+		_, err = agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Continue",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+		})
+		require.ErrorIs(t, err, ErrBudgetExceeded)
+	})
+}
+
+func TestSessionAgentMaxTurns(t *testing.T) {
+	env := testEnv(t)
+	catwalkCfg := catwalk.Model{ContextWindow: 200000, DefaultMaxTokens: 10000}
+	large := &fakeModel{
+		text: "Done",
+		toolCall: &fantasy.ToolCallContent{
+			ToolCallID: "call-1",
+			ToolName:   tools.GlobToolName,
+			Input:      `{"pattern": "*.go"}`,
+		},
+	}
+	agent := NewSessionAgent(SessionAgentOptions{
+		LargeModel: Model{Model: large, CatwalkCfg: catwalkCfg},
+		SmallModel: Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg},
+		IsYolo:     true,
+		Sessions:   env.sessions,
+		Messages:   env.messages,
+		Tools:      []fantasy.AgentTool{tools.NewGlobTool(env.workingDir)},
+	})
+
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	_, err = agent.Run(t.Context(), SessionAgentCall{
+		Prompt:          "Find the go files",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+		MaxTurns:        1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, large.callCount())
+
+	msgs, err := env.messages.List(t.Context(), session.ID)
+	require.NoError(t, err)
+	assistant := msgs[len(msgs)-2]
+	require.Equal(t, message.Assistant, assistant.Role)
+	require.Equal(t, message.FinishReasonMaxTurns, assistant.FinishReason())
+}
+
+func TestSessionAgentMediaToolResults(t *testing.T) {
+	env := testEnv(t)
+
+	var img bytes.Buffer
+	require.NoError(t, png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+	imagePath := filepath.Join(env.workingDir, "chart.png")
+	require.NoError(t, os.WriteFile(imagePath, img.Bytes(), 0o644))
+
+	large := &fakeModel{
+		text: "It is a chart",
+		toolCall: &fantasy.ToolCallContent{
+			ToolCallID: "call-1",
+			ToolName:   tools.ViewToolName,
+			Input:      fmt.Sprintf(`{"file_path": %q}`, imagePath),
+		},
+	}
+	agent := testSessionAgent(env, large, &fakeModel{text: "Title"}, "system", tools.NewViewTool(env.lspClients, env.permissions, env.workingDir, nil))
+
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	_, err = agent.Run(t.Context(), SessionAgentCall{
+		Prompt:          "What is in chart.png?",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+	})
+	require.NoError(t, err)
+
+	msgs, err := env.messages.List(t.Context(), session.ID)
+	require.NoError(t, err)
+	var results []message.ToolResult
+	for _, msg := range msgs {
+		results = append(results, msg.ToolResults()...)
+	}
+	require.Len(t, results, 1)
+	require.Equal(t, "image/png", results[0].MIMEType)
+	require.Equal(t, base64.StdEncoding.EncodeToString(img.Bytes()), results[0].Data)
+
+	// the test model can't see images
+	require.Equal(t, 2, large.callCount())
+	var output fantasy.ToolResultOutputContent
+	for _, msg := range large.calls[1].Prompt {
+		for _, part := range msg.Content {
+			if result, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](part); ok {
+				output = result.Output
+			}
+		}
+	}
+	text, ok := fantasy.AsToolResultOutputType[fantasy.ToolResultOutputContentText](output)
+	require.True(t, ok)
+	require.Contains(t, text.Text, "can't view it")
+}
+
+func TestPrepareMediaResults(t *testing.T) {
+	prompt := fantasy.Prompt{
+		fantasy.NewUserMessage("Take a screenshot"),
+		{
+			Role: fantasy.MessageRoleAssistant,
+			Content: []fantasy.MessagePart{
+				fantasy.ToolCallPart{ToolCallID: "call-1", ToolName: "screenshot", Input: "{}"},
+			},
+		},
+		{
+			Role: fantasy.MessageRoleTool,
+			Content: []fantasy.MessagePart{
+				fantasy.ToolResultPart{
+					ToolCallID: "call-1",
+					Output: fantasy.ToolResultOutputContentMedia{
+						Data:      base64.StdEncoding.EncodeToString([]byte("image")),
+						MediaType: "image/png",
+					},
+				},
+			},
+		},
+	}
+	vision := catwalk.Model{SupportsImages: true}
+
+	t.Run("anthropic keeps the images in the tool results", func(t *testing.T) {
+		prepared := prepareMediaResults(prompt, Model{Model: &fakeModel{provider: "anthropic"}, CatwalkCfg: vision})
+		require.Equal(t, prompt, prepared)
+	})
+
+	t.Run("other vision models get the images in a user message", func(t *testing.T) {
+		prepared := prepareMediaResults(prompt, Model{Model: &fakeModel{provider: "openai"}, CatwalkCfg: vision})
+		require.Len(t, prepared, 4)
+
+		result, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](prepared[2].Content[0])
+		require.True(t, ok)
+		require.Equal(t, fantasy.ToolResultContentTypeText, result.Output.GetType())
+
+		require.Equal(t, fantasy.MessageRoleUser, prepared[3].Role)
+		file, ok := fantasy.AsMessagePart[fantasy.FilePart](prepared[3].Content[1])
+		require.True(t, ok)
+		require.Equal(t, []byte("image"), file.Data)
+		require.Equal(t, "image/png", file.MediaType)
+	})
+
+	t.Run("models without vision get a note", func(t *testing.T) {
+		prepared := prepareMediaResults(prompt, Model{Model: &fakeModel{provider: "anthropic"}})
+		require.Len(t, prepared, 3)
+
+		result, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](prepared[2].Content[0])
+		require.True(t, ok)
+		text, ok := fantasy.AsToolResultOutputType[fantasy.ToolResultOutputContentText](result.Output)
+		require.True(t, ok)
+		require.Contains(t, text.Text, "can't view it")
+	})
+}
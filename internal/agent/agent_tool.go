@@ -9,7 +9,6 @@ import (
 
 	"charm.land/fantasy"
 
-	"github.com/charmbracelet/crush/internal/agent/prompt"
 	"github.com/charmbracelet/crush/internal/agent/tools"
 	"github.com/charmbracelet/crush/internal/config"
 )
@@ -30,7 +29,7 @@ func (c *coordinator) agentTool(ctx context.Context) (fantasy.AgentTool, error)
 	if !ok {
 		return nil, errors.New("task agent not configured")
 	}
-	prompt, err := taskPrompt(prompt.WithWorkingDir(c.cfg.WorkingDir()))
+	prompt, err := agentPrompt(agentCfg, c.cfg.WorkingDir())
 	if err != nil {
 		return nil, err
 	}
@@ -75,16 +74,17 @@ func (c *coordinator) agentTool(ctx context.Context) (fantasy.AgentTool, error)
 			if !ok {
 				return fantasy.ToolResponse{}, errors.New("model provider not configured")
 			}
+			mergedOptions, temp, topP, topK, freqPenalty, presPenalty := mergeCallOptions(model, providerCfg)
 			result, err := agent.Run(ctx, SessionAgentCall{
 				SessionID:        session.ID,
 				Prompt:           params.Prompt,
 				MaxOutputTokens:  maxTokens,
-				ProviderOptions:  getProviderOptions(model, providerCfg),
-				Temperature:      model.ModelCfg.Temperature,
-				TopP:             model.ModelCfg.TopP,
-				TopK:             model.ModelCfg.TopK,
-				FrequencyPenalty: model.ModelCfg.FrequencyPenalty,
-				PresencePenalty:  model.ModelCfg.PresencePenalty,
+				ProviderOptions:  mergedOptions,
+				Temperature:      temp,
+				TopP:             topP,
+				TopK:             topK,
+				FrequencyPenalty: freqPenalty,
+				PresencePenalty:  presPenalty,
 			})
 			if err != nil {
 				return fantasy.NewTextErrorResponse("error generating response"), nil
@@ -99,6 +99,7 @@ func (c *coordinator) agentTool(ctx context.Context) (fantasy.AgentTool, error)
 			}
 
 			parentSession.Cost += updatedSession.Cost
+			parentSession.TotalTokens += updatedSession.TotalTokens
 
 			_, err = c.sessions.Save(ctx, parentSession)
 			if err != nil {
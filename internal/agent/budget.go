@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// budgetLimit is one of the budgets and the usage counted against it.
+type budgetLimit struct {
+	name  string // e.g. "session cost"
+	used  float64
+	limit float64
+}
+
+func (l budgetLimit) event(sessionID string, exceeded bool) session.BudgetEvent {
+	return session.BudgetEvent{
+		SessionID: sessionID,
+		Budget:    l.name,
+		Used:      l.used,
+		Limit:     l.limit,
+		Exceeded:  exceeded,
+	}
+}
+
+// budget tracks the usage of a run against the session, run and daily
+// budgets.
+type budget struct {
+	cfg       config.Budget
+	sessions  session.Service
+	sessionID string
+	// limits of the run, 0 means no limit
+	runCost   float64
+	runTokens int64
+	// usage of the session when the run started
+	start  session.Usage
+	warned map[string]bool
+}
+
+func newBudget(cfg config.Budget, sessions session.Service, call SessionAgentCall, current session.Session) *budget {
+	return &budget{
+		cfg:       cfg,
+		sessions:  sessions,
+		sessionID: call.SessionID,
+		runCost:   call.CostBudget,
+		runTokens: call.TokenBudget,
+		start:     session.Usage{Cost: current.Cost, Tokens: current.TotalTokens},
+		warned:    make(map[string]bool),
+	}
+}
+
+// limits returns the configured budgets with their current usage.
+func (b *budget) limits(ctx context.Context, current session.Session) []budgetLimit {
+	var limits []budgetLimit
+	add := func(name string, used, limit float64) {
+		if limit > 0 {
+			limits = append(limits, budgetLimit{name: name, used: used, limit: limit})
+		}
+	}
+	add("session cost", current.Cost, b.cfg.SessionCost)
+	add("session token", float64(current.TotalTokens), float64(b.cfg.SessionTokens))
+	add("run cost", current.Cost-b.start.Cost, b.runCost)
+	add("run token", float64(current.TotalTokens-b.start.Tokens), float64(b.runTokens))
+
+	if b.cfg.DailyCost > 0 || b.cfg.DailyTokens > 0 {
+		daily, err := b.sessions.UsageSince(ctx, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			slog.Error("failed to get the daily usage", "error", err)
+		} else {
+			add("daily cost", daily.Cost, b.cfg.DailyCost)
+			add("daily token", float64(daily.Tokens), float64(b.cfg.DailyTokens))
+		}
+	}
+	return limits
+}
+
+// exceeded returns the first budget reached by the session, if any, and
+// warns once per budget when the usage gets over the warning threshold.
+func (b *budget) exceeded(ctx context.Context, current session.Session) *budgetLimit {
+	for _, limit := range b.limits(ctx, current) {
+		if limit.used >= limit.limit {
+			return &limit
+		}
+		threshold := b.cfg.Warning()
+		if threshold > 0 && limit.used >= limit.limit*threshold && !b.warned[limit.name] {
+			b.warned[limit.name] = true
+			b.sessions.PublishBudget(limit.event(b.sessionID, false))
+		}
+	}
+	return nil
+}
+
+// budgetError returns the error returned when a run starts with one of the
+// budgets already reached.
+func budgetError(limit *budgetLimit) error {
+	return fmt.Errorf("%w: %s", ErrBudgetExceeded, limit.event("", true).Status())
+}
@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionAgentCheckpoints(t *testing.T) {
+	env := testEnv(t)
+	ctx := t.Context()
+	session, err := env.sessions.Create(ctx, "New Session")
+	require.NoError(t, err)
+
+	changeFile := func(path, content string) {
+		t.Helper()
+		if _, err := env.history.GetByPathAndSession(ctx, path, session.ID); err != nil {
+			old, _ := os.ReadFile(path)
+			_, err = env.history.Create(ctx, session.ID, path, string(old))
+			require.NoError(t, err)
+		}
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		_, err := env.history.CreateVersion(ctx, session.ID, path, content)
+		require.NoError(t, err)
+	}
+
+	changed := filepath.Join(env.workingDir, "changed.txt")
+	created := filepath.Join(env.workingDir, "created.txt")
+	require.NoError(t, os.WriteFile(changed, []byte("one"), 0o644))
+	changeFile(changed, "two")
+
+	catwalkCfg := catwalk.Model{ContextWindow: 200000, DefaultMaxTokens: 10000}
+	agent := NewSessionAgent(SessionAgentOptions{
+		LargeModel: Model{Model: &fakeModel{text: "Done"}, CatwalkCfg: catwalkCfg},
+		SmallModel: Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg},
+		IsYolo:     true,
+		Sessions:   env.sessions,
+		Messages:   env.messages,
+		Tools:      []fantasy.AgentTool{},
+		History:    env.history,
+	})
+	_, err = agent.Run(ctx, SessionAgentCall{
+		Prompt:          "Change the files",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+	})
+	require.NoError(t, err)
+
+	msgs, err := env.messages.List(ctx, session.ID)
+	require.NoError(t, err)
+	require.Equal(t, message.User, msgs[0].Role)
+	checkpoints, err := env.history.ListCheckpoints(ctx, session.ID)
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 1)
+	require.Equal(t, msgs[0].ID, checkpoints[0].MessageID)
+
+	// the changes made after the prompt
+	changeFile(changed, "three")
+	changeFile(created, "new")
+
+	changes, err := env.history.RewindChanges(ctx, session.ID, msgs[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, []history.FileChange{
+		{Path: changed, Content: "two"},
+		{Path: created, Deleted: true},
+	}, changes)
+
+	// a dry run changes nothing
+	content, err := os.ReadFile(changed)
+	require.NoError(t, err)
+	require.Equal(t, "three", string(content))
+
+	_, err = env.history.Rewind(ctx, session.ID, msgs[0].ID)
+	require.NoError(t, err)
+	content, err = os.ReadFile(changed)
+	require.NoError(t, err)
+	require.Equal(t, "two", string(content))
+	require.NoFileExists(t, created)
+
+	changes, err = env.history.RewindChanges(ctx, session.ID, msgs[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	_, err = env.history.RewindChanges(ctx, session.ID, msgs[1].ID)
+	require.ErrorIs(t, err, history.ErrNoCheckpoint)
+}
+
+func TestSessionAgentFork(t *testing.T) {
+	env := testEnv(t)
+	ctx := t.Context()
+	catwalkCfg := catwalk.Model{ContextWindow: 200000, DefaultMaxTokens: 10000}
+	large := &fakeModel{
+		text: "Done",
+		toolCall: &fantasy.ToolCallContent{
+			ToolCallID: "call-1",
+			ToolName:   tools.GlobToolName,
+			Input:      `{"pattern": "*.go"}`,
+		},
+	}
+	agent := NewSessionAgent(SessionAgentOptions{
+		LargeModel: Model{Model: large, CatwalkCfg: catwalkCfg},
+		SmallModel: Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg},
+		IsYolo:     true,
+		Sessions:   env.sessions,
+		Messages:   env.messages,
+		Tools:      []fantasy.AgentTool{tools.NewGlobTool(env.workingDir)},
+		History:    env.history,
+	})
+
+	session, err := env.sessions.Create(ctx, "New Session")
+	require.NoError(t, err)
+	_, err = agent.Run(ctx, SessionAgentCall{
+		Prompt:          "Find the go files",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+	})
+	require.NoError(t, err)
+	msgs, err := env.messages.List(ctx, session.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+	require.Len(t, msgs[1].ToolCalls(), 1)
+
+	// forking at the tool call keeps its result
+	fork, err := env.sessions.Fork(ctx, session.ID, msgs[1].ID)
+	require.NoError(t, err)
+	forkMsgs, err := env.messages.List(ctx, fork.ID)
+	require.NoError(t, err)
+	require.Len(t, forkMsgs, 3)
+	require.Equal(t, message.Tool, forkMsgs[2].Role)
+	require.Equal(t, msgs[2].ID, fork.ForkedFromMessageID)
+
+	_, err = agent.Run(ctx, SessionAgentCall{
+		Prompt:          "Now the test files",
+		SessionID:       fork.ID,
+		MaxOutputTokens: 10000,
+	})
+	require.NoError(t, err)
+	var roles []fantasy.MessageRole
+	for _, msg := range large.calls[len(large.calls)-1].Prompt {
+		roles = append(roles, msg.Role)
+	}
+	require.Equal(t, []fantasy.MessageRole{
+		fantasy.MessageRoleUser,
+		fantasy.MessageRoleAssistant,
+		fantasy.MessageRoleTool,
+		fantasy.MessageRoleUser,
+	}, roles)
+
+	// the copied prompt can be rewound in the fork
+	checkpoints, err := env.history.ListCheckpoints(ctx, fork.ID)
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 2)
+	require.Equal(t, forkMsgs[0].ID, checkpoints[0].MessageID)
+	_, err = env.history.RewindChanges(ctx, fork.ID, forkMsgs[0].ID)
+	require.NoError(t, err)
+}
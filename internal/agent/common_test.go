@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -100,6 +101,108 @@ func zAIBuilder(model string) builderFunc {
 	}
 }
 
+// fakeModel is a language model that records the calls it receives and
+// always answers with the same text.
+type fakeModel struct {
+	mu    sync.Mutex
+	calls []fantasy.Call
+	text  string
+	// err makes the model fail the calls with the given error.
+	err error
+	// failures is the number of calls failing with err, all of them if 0.
+	failures int
+	// toolCall makes the model answer the first call with the tool call.
+	toolCall *fantasy.ToolCallContent
+	// provider is the name of the provider, fake if empty.
+	provider string
+	// usage is the usage reported for each call.
+	usage fantasy.Usage
+}
+
+func (m *fakeModel) Generate(_ context.Context, call fantasy.Call) (*fantasy.Response, error) {
+	if err := m.record(call); err != nil {
+		return nil, err
+	}
+	return &fantasy.Response{
+		Content:      fantasy.ResponseContent{fantasy.TextContent{Text: m.text}},
+		FinishReason: fantasy.FinishReasonStop,
+	}, nil
+}
+
+func (m *fakeModel) Stream(_ context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	err := m.record(call)
+	return func(yield func(fantasy.StreamPart) bool) {
+		if err != nil {
+			yield(fantasy.StreamPart{Type: fantasy.StreamPartTypeError, Error: err})
+			return
+		}
+		if m.toolCall != nil && m.callCount() == 1 {
+			parts := []fantasy.StreamPart{
+				{Type: fantasy.StreamPartTypeToolCall, ID: m.toolCall.ToolCallID, ToolCallName: m.toolCall.ToolName, ToolCallInput: m.toolCall.Input},
+				{Type: fantasy.StreamPartTypeFinish, FinishReason: fantasy.FinishReasonToolCalls, Usage: m.usage},
+			}
+			for _, part := range parts {
+				if !yield(part) {
+					return
+				}
+			}
+			return
+		}
+		parts := []fantasy.StreamPart{
+			{Type: fantasy.StreamPartTypeTextStart, ID: "0"},
+			{Type: fantasy.StreamPartTypeTextDelta, ID: "0", Delta: m.text},
+			{Type: fantasy.StreamPartTypeTextEnd, ID: "0"},
+			{Type: fantasy.StreamPartTypeFinish, FinishReason: fantasy.FinishReasonStop, Usage: m.usage},
+		}
+		for _, part := range parts {
+			if !yield(part) {
+				return
+			}
+		}
+	}, nil
+}
+
+func (m *fakeModel) Provider() string {
+	if m.provider != "" {
+		return m.provider
+	}
+	return "fake"
+}
+
+func (m *fakeModel) Model() string { return "fake-model" }
+
+func (m *fakeModel) callCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.calls)
+}
+
+// record records the call and returns the error the call should fail with.
+func (m *fakeModel) record(call fantasy.Call) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call)
+	if m.failures == 0 || len(m.calls) <= m.failures {
+		return m.err
+	}
+	return nil
+}
+
+// toolNames returns the names of the tools sent in each call to the model.
+func (m *fakeModel) toolNames() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names [][]string
+	for _, call := range m.calls {
+		callNames := []string{}
+		for _, tool := range call.Tools {
+			callNames = append(callNames, tool.GetName())
+		}
+		names = append(names, callNames)
+	}
+	return names
+}
+
 func testEnv(t *testing.T) env {
 	workingDir := filepath.Join("/tmp/crush-test/", t.Name())
 	os.RemoveAll(workingDir)
@@ -148,7 +251,7 @@ func testSessionAgent(env env, large, small fantasy.LanguageModel, systemPrompt
 			DefaultMaxTokens: 10000,
 		},
 	}
-	agent := NewSessionAgent(SessionAgentOptions{largeModel, smallModel, "", systemPrompt, false, true, env.sessions, env.messages, tools})
+	agent := NewSessionAgent(SessionAgentOptions{largeModel, smallModel, "", systemPrompt, false, true, env.sessions, env.messages, tools, config.Budget{}, config.Compaction{}, nil, nil})
 	return agent
 }
 
@@ -177,15 +280,15 @@ func coderAgent(r *recorder.Recorder, env env, large, small fantasy.LanguageMode
 	allTools := []fantasy.AgentTool{
 		tools.NewBashTool(env.permissions, env.workingDir, cfg.Options.Attribution),
 		tools.NewDownloadTool(env.permissions, env.workingDir, r.GetDefaultClient()),
-		tools.NewEditTool(env.lspClients, env.permissions, env.history, env.workingDir),
-		tools.NewMultiEditTool(env.lspClients, env.permissions, env.history, env.workingDir),
+		tools.NewEditTool(env.lspClients, env.permissions, env.history, env.workingDir, nil),
+		tools.NewMultiEditTool(env.lspClients, env.permissions, env.history, env.workingDir, nil),
 		tools.NewFetchTool(env.permissions, env.workingDir, r.GetDefaultClient()),
 		tools.NewGlobTool(env.workingDir),
 		tools.NewGrepTool(env.workingDir),
 		tools.NewLsTool(env.permissions, env.workingDir, cfg.Tools.Ls),
 		tools.NewSourcegraphTool(r.GetDefaultClient()),
-		tools.NewViewTool(env.lspClients, env.permissions, env.workingDir),
-		tools.NewWriteTool(env.lspClients, env.permissions, env.history, env.workingDir),
+		tools.NewViewTool(env.lspClients, env.permissions, env.workingDir, nil),
+		tools.NewWriteTool(env.lspClients, env.permissions, env.history, env.workingDir, nil),
 	}
 
 	return testSessionAgent(env, large, small, systemPrompt, allTools...), nil
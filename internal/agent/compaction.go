@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+const (
+	defaultKeepTurns       = 2
+	defaultKeepToolResults = 10
+	defaultMinPruneSize    = 2000
+)
+
+// compactionThreshold returns the number of tokens left in the context window
+// under which the context is compacted.
+func compactionThreshold(cfg config.Compaction, contextWindow int64) int64 {
+	switch {
+	case cfg.ReservedTokens > 0:
+		return cfg.ReservedTokens
+	case cfg.Threshold > 0:
+		return int64(float64(contextWindow) * cfg.Threshold)
+	case contextWindow > 200_000:
+		return 20_000
+	default:
+		return int64(float64(contextWindow) * 0.2)
+	}
+}
+
+// keptMessagesIndex returns the index of the first message of the last turns
+// kept verbatim by the keep_recent strategy, 0 when there aren't more turns
+// than that and everything is summarized.
+func keptMessagesIndex(msgs []message.Message, keepTurns int) int {
+	if keepTurns <= 0 {
+		keepTurns = defaultKeepTurns
+	}
+	turns := 0
+	// the first message is never kept, there would be nothing to summarize
+	for i := len(msgs) - 1; i > 0; i-- {
+		if msgs[i].Role != message.User {
+			continue
+		}
+		turns++
+		if turns == keepTurns {
+			return i
+		}
+	}
+	return 0
+}
+
+// pruneToolResults marks the large results of the older tool calls of the
+// session as pruned, and returns the stubs replacing them by tool call ID.
+func (a *sessionAgent) pruneToolResults(ctx context.Context, current session.Session) (map[string]string, error) {
+	msgs, err := a.getSessionMessages(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := defaultKeepToolResults
+	if a.compaction.KeepToolResults != nil {
+		keep = *a.compaction.KeepToolResults
+	}
+	minSize := a.compaction.MinPruneSize
+	if minSize <= 0 {
+		minSize = defaultMinPruneSize
+	}
+
+	total := 0
+	for _, msg := range msgs {
+		if msg.Role == message.Tool {
+			total += len(msg.ToolResults())
+		}
+	}
+
+	pruned := make(map[string]string)
+	seen := 0
+	for _, msg := range msgs {
+		if msg.Role != message.Tool {
+			continue
+		}
+		changed := false
+		for i, part := range msg.Parts {
+			result, ok := part.(message.ToolResult)
+			if !ok {
+				continue
+			}
+			seen++
+			if seen > total-keep {
+				break
+			}
+			if result.Pruned || result.Size() <= minSize {
+				continue
+			}
+			result.Pruned = true
+			msg.Parts[i] = result
+			pruned[result.ToolCallID] = result.PrunedContent()
+			changed = true
+		}
+		if changed {
+			if err := a.messages.Update(ctx, msg); err != nil {
+				return nil, fmt.Errorf("failed to prune tool results: %w", err)
+			}
+		}
+	}
+	return pruned, nil
+}
+
+// applyPrunedResults replaces the results pruned during the run with their
+// stubs.
+func applyPrunedResults(msgs []fantasy.Message, pruned map[string]string) {
+	if len(pruned) == 0 {
+		return
+	}
+	for _, msg := range msgs {
+		if msg.Role != fantasy.MessageRoleTool {
+			continue
+		}
+		for i, part := range msg.Content {
+			result, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](part)
+			if !ok {
+				continue
+			}
+			if stub, ok := pruned[result.ToolCallID]; ok {
+				result.Output = fantasy.ToolResultOutputContentText{Text: stub}
+				msg.Content[i] = result
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactionThreshold(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           config.Compaction
+		contextWindow int64
+		want          int64
+	}{
+		{"default", config.Compaction{}, 100_000, 20_000},
+		{"default large window", config.Compaction{}, 1_000_000, 20_000},
+		{"threshold", config.Compaction{Threshold: 0.3}, 1_000_000, 300_000},
+		{"reserved tokens", config.Compaction{Threshold: 0.3, ReservedTokens: 50_000}, 1_000_000, 50_000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, compactionThreshold(tt.cfg, tt.contextWindow))
+		})
+	}
+}
+
+func newCompactionAgent(env env, large *fakeModel, compaction config.Compaction) *sessionAgent {
+	catwalkCfg := catwalk.Model{ContextWindow: 200000, DefaultMaxTokens: 10000}
+	return NewSessionAgent(SessionAgentOptions{
+		LargeModel: Model{Model: large, CatwalkCfg: catwalkCfg},
+		SmallModel: Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg},
+		IsYolo:     true,
+		Sessions:   env.sessions,
+		Messages:   env.messages,
+		Tools:      []fantasy.AgentTool{tools.NewGlobTool(env.workingDir)},
+		Compaction: compaction,
+	}).(*sessionAgent)
+}
+
+func createTurn(t *testing.T, env env, sessionID, prompt, answer string) {
+	_, err := env.messages.Create(t.Context(), sessionID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: prompt}},
+	})
+	require.NoError(t, err)
+	_, err = env.messages.Create(t.Context(), sessionID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: answer},
+			message.Finish{Reason: message.FinishReasonEndTurn},
+		},
+	})
+	require.NoError(t, err)
+}
+
+func TestSessionAgentPruneToolResults(t *testing.T) {
+	env := testEnv(t)
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+
+	// an earlier turn with a large tool result
+	_, err = env.messages.Create(t.Context(), session.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Read the logs"}},
+	})
+	require.NoError(t, err)
+	_, err = env.messages.Create(t.Context(), session.ID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.ToolCall{ID: "call-0", Name: tools.ViewToolName, Input: `{"file_path": "app.log"}`, Finished: true},
+			message.Finish{Reason: message.FinishReasonToolUse},
+		},
+	})
+	require.NoError(t, err)
+	_, err = env.messages.Create(t.Context(), session.ID, message.CreateMessageParams{
+		Role:  message.Tool,
+		Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call-0", Name: tools.ViewToolName, Content: strings.Repeat("log line\n", 1000)}},
+	})
+	require.NoError(t, err)
+	_, err = env.messages.Create(t.Context(), session.ID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: "The logs are fine"},
+			message.Finish{Reason: message.FinishReasonEndTurn},
+		},
+	})
+	require.NoError(t, err)
+
+	large := &fakeModel{
+		text:  "Done",
+		usage: fantasy.Usage{InputTokens: 190_000},
+		toolCall: &fantasy.ToolCallContent{
+			ToolCallID: "call-1",
+			ToolName:   tools.GlobToolName,
+			Input:      `{"pattern": "*.go"}`,
+		},
+	}
+	keep := 1
+	agent := newCompactionAgent(env, large, config.Compaction{
+		Strategy:        config.CompactionPruneToolResults,
+		KeepToolResults: &keep,
+	})
+	_, err = agent.Run(t.Context(), SessionAgentCall{
+		Prompt:          "Find the go files",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+	})
+	require.NoError(t, err)
+
+	// the old result is pruned for the next step
+	var output fantasy.ToolResultOutputContent
+	for _, msg := range large.calls[1].Prompt {
+		for _, part := range msg.Content {
+			if result, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](part); ok && result.ToolCallID == "call-0" {
+				output = result.Output
+			}
+		}
+	}
+	text, ok := fantasy.AsToolResultOutputType[fantasy.ToolResultOutputContentText](output)
+	require.True(t, ok)
+	require.Equal(t, "[The result (9000 bytes) was pruned to save context, run the tool again if you need it]", text.Text)
+
+	msgs, err := env.messages.List(t.Context(), session.ID)
+	require.NoError(t, err)
+	var results []message.ToolResult
+	for _, msg := range msgs {
+		results = append(results, msg.ToolResults()...)
+	}
+	require.Len(t, results, 2)
+	require.True(t, results[0].Pruned)
+	require.False(t, results[1].Pruned)
+
+	// with nothing left to prune the session is summarized
+	require.Equal(t, 3, large.callCount())
+	session, err = env.sessions.Get(t.Context(), session.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, session.SummaryMessageID)
+}
+
+func TestSessionAgentSummarizeKeepRecent(t *testing.T) {
+	env := testEnv(t)
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	createTurn(t, env, session.ID, "First", "First answer")
+	createTurn(t, env, session.ID, "Second", "Second answer")
+	createTurn(t, env, session.ID, "Third", "Third answer")
+
+	large := &fakeModel{text: "Summary"}
+	agent := newCompactionAgent(env, large, config.Compaction{
+		Strategy:  config.CompactionKeepRecent,
+		KeepTurns: 2,
+	})
+	require.NoError(t, agent.Summarize(t.Context(), session.ID, nil))
+
+	// only the first turn is summarized
+	var prompts []string
+	for _, msg := range large.calls[0].Prompt {
+		if msg.Role == fantasy.MessageRoleUser {
+			prompts = append(prompts, msg.Content[0].(fantasy.TextPart).Text)
+		}
+	}
+	require.Equal(t, []string{"First", "Provide a detailed summary of our conversation above."}, prompts)
+
+	session, err = env.sessions.Get(t.Context(), session.ID)
+	require.NoError(t, err)
+	msgs, err := agent.getSessionMessages(t.Context(), session)
+	require.NoError(t, err)
+	var contents []string
+	for _, msg := range msgs {
+		contents = append(contents, msg.Content().Text)
+	}
+	require.Equal(t, []string{"Summary", "Second", "Second answer", "Third", "Third answer"}, contents)
+	require.Equal(t, message.User, msgs[0].Role)
+}
+
+func TestSessionAgentReloadsContextFiles(t *testing.T) {
+	env := testEnv(t)
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	dir := filepath.Join(env.workingDir, "api")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "AGENTS.md"), []byte("api rules"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package api"), 0o644))
+
+	loader := tools.NewContextLoader(env.workingDir, []string{"AGENTS.md"})
+	view := tools.NewViewTool(csync.NewMap[string, *lsp.Client](), nil, env.workingDir, loader)
+	catwalkCfg := catwalk.Model{ContextWindow: 200000, DefaultMaxTokens: 10000}
+	// read returns the result of the view tool of a run reading main.go
+	read := func(callID string) string {
+		t.Helper()
+		agent := NewSessionAgent(SessionAgentOptions{
+			LargeModel: Model{Model: &fakeModel{text: "Done", toolCall: &fantasy.ToolCallContent{
+				ToolCallID: callID,
+				ToolName:   tools.ViewToolName,
+				Input:      `{"file_path": "api/main.go"}`,
+			}}, CatwalkCfg: catwalkCfg},
+			SmallModel: Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg},
+			IsYolo:     true,
+			Sessions:   env.sessions,
+			Messages:   env.messages,
+			Tools:      []fantasy.AgentTool{view},
+		})
+		_, err := agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Read main.go",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+			KeepTitle:       true,
+		})
+		require.NoError(t, err)
+		msgs, err := env.messages.List(t.Context(), session.ID)
+		require.NoError(t, err)
+		for _, msg := range msgs {
+			for _, result := range msg.ToolResults() {
+				if result.ToolCallID == callID {
+					return result.Content
+				}
+			}
+		}
+		t.Fatalf("no result for %s", callID)
+		return ""
+	}
+
+	require.Contains(t, read("call-1"), "api rules")
+	require.NotContains(t, read("call-2"), "api rules")
+
+	// once the result carrying them is pruned, the context files are loaded
+	// again
+	msgs, err := env.messages.List(t.Context(), session.ID)
+	require.NoError(t, err)
+	for _, msg := range msgs {
+		for i, part := range msg.Parts {
+			if result, ok := part.(message.ToolResult); ok && result.ToolCallID == "call-1" {
+				result.Pruned = true
+				msg.Parts[i] = result
+				require.NoError(t, env.messages.Update(t.Context(), msg))
+			}
+		}
+	}
+	require.Contains(t, read("call-3"), "api rules")
+}
@@ -13,6 +13,7 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"time"
 
 	"charm.land/fantasy"
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
@@ -21,6 +22,7 @@ import (
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/hooks"
 	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/lsp"
 	"github.com/charmbracelet/crush/internal/message"
@@ -39,10 +41,28 @@ import (
 	"github.com/qjebbs/go-jsons"
 )
 
+// RunOptions holds the options for a single Coordinator run.
+type RunOptions struct {
+	// AllowedTools restricts the tools the agent can use for this run, nil
+	// means all the agent tools are available.
+	AllowedTools []string
+	// CostBudget and TokenBudget stop the agent once the run used that much,
+	// 0 means no limit.
+	CostBudget  float64
+	TokenBudget int64
+	// KeepTitle keeps the title of the session instead of generating one
+	// from the first prompt.
+	KeepTitle bool
+	// MaxTurns stops the agent after that many requests to the model, 0
+	// means no limit.
+	MaxTurns int
+}
+
 type Coordinator interface {
-	// INFO: (kujtim) this is not used yet we will use this when we have multiple agents
-	// SetMainAgent(string)
 	Run(ctx context.Context, sessionID, prompt string, attachments ...message.Attachment) (*fantasy.AgentResult, error)
+	// RunWithOptions is like Run but allows changing the behavior of the
+	// agent for this run only.
+	RunWithOptions(ctx context.Context, sessionID, prompt string, opts RunOptions, attachments ...message.Attachment) (*fantasy.AgentResult, error)
 	Cancel(sessionID string)
 	CancelAll()
 	IsSessionBusy(sessionID string) bool
@@ -52,6 +72,16 @@ type Coordinator interface {
 	Summarize(context.Context, string) error
 	Model() Model
 	UpdateModels(ctx context.Context) error
+	// SetSessionAgent switches the agent used for the given session.
+	SetSessionAgent(sessionID, agentID string) error
+	// SessionAgentID returns the id of the agent used for the given session.
+	SessionAgentID(sessionID string) string
+	// SetPlanMode switches the given session in or out of plan mode, where
+	// the agent only gets the read-only tools and submits a plan for
+	// approval. A busy session switches from its next run on.
+	SetPlanMode(sessionID string, enabled bool) error
+	// IsPlanMode reports whether the given session is in plan mode.
+	IsPlanMode(sessionID string) bool
 }
 
 type coordinator struct {
@@ -62,8 +92,10 @@ type coordinator struct {
 	history     history.Service
 	lspClients  *csync.Map[string, *lsp.Client]
 
-	currentAgent SessionAgent
-	agents       map[string]SessionAgent
+	currentAgent  SessionAgent
+	agents        map[string]SessionAgent
+	sessionAgents *csync.Map[string, string]
+	planSessions  *csync.Map[string, bool]
 
 	readyWg errgroup.Group
 }
@@ -78,42 +110,68 @@ func NewCoordinator(
 	lspClients *csync.Map[string, *lsp.Client],
 ) (Coordinator, error) {
 	c := &coordinator{
-		cfg:         cfg,
-		sessions:    sessions,
-		messages:    messages,
-		permissions: permissions,
-		history:     history,
-		lspClients:  lspClients,
-		agents:      make(map[string]SessionAgent),
+		cfg:           cfg,
+		sessions:      sessions,
+		messages:      messages,
+		permissions:   permissions,
+		history:       history,
+		lspClients:    lspClients,
+		agents:        make(map[string]SessionAgent),
+		sessionAgents: csync.NewMap[string, string](),
+		planSessions:  csync.NewMap[string, bool](),
+	}
+
+	if _, ok := cfg.Agents[config.AgentCoder]; !ok {
+		return nil, errors.New("coder agent not configured")
 	}
 
-	agentCfg, ok := cfg.Agents[config.AgentCoder]
-	if !ok {
-		return nil, errors.New("coder agent not configured")
+	for _, agentCfg := range cfg.EnabledAgents() {
+		prompt, err := agentPrompt(agentCfg, c.cfg.WorkingDir())
+		if err != nil {
+			return nil, err
+		}
+
+		agent, err := c.buildAgent(ctx, prompt, agentCfg)
+		if err != nil {
+			return nil, err
+		}
+		c.agents[agentCfg.ID] = agent
 	}
+	c.currentAgent = c.agents[config.AgentCoder]
+	return c, nil
+}
 
-	// TODO: make this dynamic when we support multiple agents
-	prompt, err := coderPrompt(prompt.WithWorkingDir(c.cfg.WorkingDir()))
-	if err != nil {
-		return nil, err
+// budget returns the configured budgets.
+func (c *coordinator) budget() config.Budget {
+	if c.cfg.Options.Budget == nil {
+		return config.Budget{}
 	}
+	return *c.cfg.Options.Budget
+}
 
-	agent, err := c.buildAgent(ctx, prompt, agentCfg)
-	if err != nil {
-		return nil, err
+// sessionAgent returns the agent that handles the given session.
+func (c *coordinator) sessionAgent(sessionID string) SessionAgent {
+	if agentID, ok := c.sessionAgents.Get(sessionID); ok {
+		if agent, ok := c.agents[agentID]; ok {
+			return agent
+		}
 	}
-	c.currentAgent = agent
-	c.agents[config.AgentCoder] = agent
-	return c, nil
+	return c.currentAgent
 }
 
 // Run implements Coordinator.
 func (c *coordinator) Run(ctx context.Context, sessionID string, prompt string, attachments ...message.Attachment) (*fantasy.AgentResult, error) {
+	return c.RunWithOptions(ctx, sessionID, prompt, RunOptions{}, attachments...)
+}
+
+// RunWithOptions implements Coordinator.
+func (c *coordinator) RunWithOptions(ctx context.Context, sessionID string, prompt string, opts RunOptions, attachments ...message.Attachment) (*fantasy.AgentResult, error) {
 	if err := c.readyWg.Wait(); err != nil {
 		return nil, err
 	}
 
-	model := c.currentAgent.Model()
+	agent := c.sessionAgent(sessionID)
+	model := agent.Model()
 	maxTokens := model.CatwalkCfg.DefaultMaxTokens
 	if model.ModelCfg.MaxTokens != 0 {
 		maxTokens = model.ModelCfg.MaxTokens
@@ -130,7 +188,7 @@ func (c *coordinator) Run(ctx context.Context, sessionID string, prompt string,
 
 	mergedOptions, temp, topP, topK, freqPenalty, presPenalty := mergeCallOptions(model, providerCfg)
 
-	return c.currentAgent.Run(ctx, SessionAgentCall{
+	return agent.Run(ctx, SessionAgentCall{
 		SessionID:        sessionID,
 		Prompt:           prompt,
 		Attachments:      attachments,
@@ -141,6 +199,12 @@ func (c *coordinator) Run(ctx context.Context, sessionID string, prompt string,
 		TopK:             topK,
 		FrequencyPenalty: freqPenalty,
 		PresencePenalty:  presPenalty,
+		AllowedTools:     opts.AllowedTools,
+		PlanMode:         c.IsPlanMode(sessionID),
+		CostBudget:       opts.CostBudget,
+		TokenBudget:      opts.TokenBudget,
+		KeepTitle:        opts.KeepTitle,
+		MaxTurns:         opts.MaxTurns,
 	})
 }
 
@@ -274,8 +338,27 @@ func mergeCallOptions(model Model, cfg config.ProviderConfig) (fantasy.ProviderO
 	return modelOptions, temp, topP, topK, freqPenalty, presPenalty
 }
 
+// providerRetryOptions returns the retry policy of the provider, falling back
+// to the fantasy defaults for the values not configured.
+func providerRetryOptions(providerCfg config.ProviderConfig) fantasy.RetryOptions {
+	opts := fantasy.DefaultRetryOptions()
+	if providerCfg.Retry == nil {
+		return opts
+	}
+	if providerCfg.Retry.MaxRetries != nil {
+		opts.MaxRetries = max(*providerCfg.Retry.MaxRetries, 0)
+	}
+	if providerCfg.Retry.InitialDelay > 0 {
+		opts.InitialDelayIn = time.Duration(providerCfg.Retry.InitialDelay * float64(time.Second))
+	}
+	if providerCfg.Retry.BackoffFactor >= 1 {
+		opts.BackoffFactor = providerCfg.Retry.BackoffFactor
+	}
+	return opts
+}
+
 func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, agent config.Agent) (SessionAgent, error) {
-	large, small, err := c.buildAgentModels(ctx)
+	large, small, err := c.buildAgentModels(ctx, agent)
 	if err != nil {
 		return nil, err
 	}
@@ -285,6 +368,11 @@ func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, age
 		return nil, err
 	}
 
+	var compaction config.Compaction
+	if agent.Compaction != nil {
+		compaction = *agent.Compaction
+	}
+
 	largeProviderCfg, _ := c.cfg.Providers.Get(large.ModelCfg.Provider)
 	result := NewSessionAgent(SessionAgentOptions{
 		large,
@@ -296,6 +384,10 @@ func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, age
 		c.sessions,
 		c.messages,
 		nil,
+		c.budget(),
+		compaction,
+		c.history,
+		hooks.NewRunner(c.cfg.WorkingDir(), c.cfg.Hooks),
 	})
 	c.readyWg.Go(func() error {
 		tools, err := c.buildTools(ctx, agent)
@@ -310,39 +402,15 @@ func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, age
 }
 
 func (c *coordinator) buildTools(ctx context.Context, agent config.Agent) ([]fantasy.AgentTool, error) {
-	var allTools []fantasy.AgentTool
+	var filteredTools []fantasy.AgentTool
 	if slices.Contains(agent.AllowedTools, AgentToolName) {
 		agentTool, err := c.agentTool(ctx)
 		if err != nil {
 			return nil, err
 		}
-		allTools = append(allTools, agentTool)
-	}
-
-	allTools = append(allTools,
-		tools.NewBashTool(c.permissions, c.cfg.WorkingDir(), c.cfg.Options.Attribution),
-		tools.NewDownloadTool(c.permissions, c.cfg.WorkingDir(), nil),
-		tools.NewEditTool(c.lspClients, c.permissions, c.history, c.cfg.WorkingDir()),
-		tools.NewMultiEditTool(c.lspClients, c.permissions, c.history, c.cfg.WorkingDir()),
-		tools.NewFetchTool(c.permissions, c.cfg.WorkingDir(), nil),
-		tools.NewGlobTool(c.cfg.WorkingDir()),
-		tools.NewGrepTool(c.cfg.WorkingDir()),
-		tools.NewLsTool(c.permissions, c.cfg.WorkingDir(), c.cfg.Tools.Ls),
-		tools.NewSourcegraphTool(nil),
-		tools.NewViewTool(c.lspClients, c.permissions, c.cfg.WorkingDir()),
-		tools.NewWriteTool(c.lspClients, c.permissions, c.history, c.cfg.WorkingDir()),
-	)
-
-	if len(c.cfg.LSP) > 0 {
-		allTools = append(allTools, tools.NewDiagnosticsTool(c.lspClients), tools.NewReferencesTool(c.lspClients))
-	}
-
-	var filteredTools []fantasy.AgentTool
-	for _, tool := range allTools {
-		if slices.Contains(agent.AllowedTools, tool.Info().Name) {
-			filteredTools = append(filteredTools, tool)
-		}
+		filteredTools = append(filteredTools, agentTool)
 	}
+	filteredTools = append(filteredTools, builtinTools(c.cfg, agent, c.permissions, c.history, c.lspClients)...)
 
 	mcpTools := tools.GetMCPTools(context.Background(), c.permissions, c.cfg)
 
@@ -372,91 +440,145 @@ func (c *coordinator) buildTools(ctx context.Context, agent config.Agent) ([]fan
 	slices.SortFunc(filteredTools, func(a, b fantasy.AgentTool) int {
 		return strings.Compare(a.Info().Name, b.Info().Name)
 	})
-	return filteredTools, nil
+	return withToolHooks(filteredTools, c.cfg.WorkingDir(), c.cfg.Hooks), nil
 }
 
-// TODO: when we support multiple agents we need to change this so that we pass in the agent specific model config
-func (c *coordinator) buildAgentModels(ctx context.Context) (Model, Model, error) {
-	largeModelCfg, ok := c.cfg.Models[config.SelectedModelTypeLarge]
-	if !ok {
-		return Model{}, Model{}, errors.New("large model not selected")
-	}
-	smallModelCfg, ok := c.cfg.Models[config.SelectedModelTypeSmall]
-	if !ok {
-		return Model{}, Model{}, errors.New("small model not selected")
+// BuiltinTools returns the built-in tools the agent is allowed, sorted by
+// name and wrapped with the tool hooks of the configuration. The agent tool,
+// which needs a model, and the tools of the MCP servers are left out.
+func BuiltinTools(cfg *config.Config, agent config.Agent, permissions permission.Service, history history.Service, lspClients *csync.Map[string, *lsp.Client]) []fantasy.AgentTool {
+	builtin := builtinTools(cfg, agent, permissions, history, lspClients)
+	slices.SortFunc(builtin, func(a, b fantasy.AgentTool) int {
+		return strings.Compare(a.Info().Name, b.Info().Name)
+	})
+	return withToolHooks(builtin, cfg.WorkingDir(), cfg.Hooks)
+}
+
+func builtinTools(cfg *config.Config, agent config.Agent, permissions permission.Service, history history.Service, lspClients *csync.Map[string, *lsp.Client]) []fantasy.AgentTool {
+	contextPaths := cfg.Options.ContextPaths
+	if agent.ContextPaths != nil {
+		contextPaths = agent.ContextPaths
+	}
+	contextFiles := tools.NewContextLoader(cfg.WorkingDir(), contextPaths)
+
+	allTools := []fantasy.AgentTool{
+		tools.NewBashTool(permissions, cfg.WorkingDir(), cfg.Options.Attribution),
+		tools.NewDownloadTool(permissions, cfg.WorkingDir(), nil),
+		tools.NewEditTool(lspClients, permissions, history, cfg.WorkingDir(), contextFiles),
+		tools.NewMultiEditTool(lspClients, permissions, history, cfg.WorkingDir(), contextFiles),
+		tools.NewFetchTool(permissions, cfg.WorkingDir(), nil),
+		tools.NewGlobTool(cfg.WorkingDir()),
+		tools.NewGrepTool(cfg.WorkingDir()),
+		tools.NewLsTool(permissions, cfg.WorkingDir(), cfg.Tools.Ls),
+		tools.NewSourcegraphTool(nil),
+		tools.NewViewTool(lspClients, permissions, cfg.WorkingDir(), contextFiles),
+		tools.NewWriteTool(lspClients, permissions, history, cfg.WorkingDir(), contextFiles),
 	}
 
-	largeProviderCfg, ok := c.cfg.Providers.Get(largeModelCfg.Provider)
-	if !ok {
-		return Model{}, Model{}, errors.New("large model provider not configured")
+	if len(cfg.LSP) > 0 {
+		allTools = append(allTools, tools.NewDiagnosticsTool(lspClients), tools.NewReferencesTool(lspClients))
 	}
 
-	largeProvider, err := c.buildProvider(largeProviderCfg, largeModelCfg)
-	if err != nil {
-		return Model{}, Model{}, err
+	var filteredTools []fantasy.AgentTool
+	for _, tool := range allTools {
+		if slices.Contains(agent.AllowedTools, tool.Info().Name) {
+			filteredTools = append(filteredTools, tool)
+		}
 	}
+	return filteredTools
+}
 
-	smallProviderCfg, ok := c.cfg.Providers.Get(smallModelCfg.Provider)
+// buildAgentModels builds the main and small models of the agent, the main
+// model is the one selected by the agent model type.
+func (c *coordinator) buildAgentModels(ctx context.Context, agent config.Agent) (Model, Model, error) {
+	largeModelCfg, ok := c.cfg.AgentModel(agent, cmp.Or(agent.Model, config.SelectedModelTypeLarge))
+	if !ok {
+		return Model{}, Model{}, fmt.Errorf("%s model not selected", cmp.Or(agent.Model, config.SelectedModelTypeLarge))
+	}
+	smallModelCfg, ok := c.cfg.AgentModel(agent, config.SelectedModelTypeSmall)
 	if !ok {
-		return Model{}, Model{}, errors.New("large model provider not configured")
+		return Model{}, Model{}, errors.New("small model not selected")
 	}
 
-	smallProvider, err := c.buildProvider(smallProviderCfg, largeModelCfg)
+	largeModel, err := c.buildModel(ctx, largeModelCfg)
+	if err != nil {
+		return Model{}, Model{}, fmt.Errorf("large model: %w", err)
+	}
+	smallModel, err := c.buildModel(ctx, smallModelCfg)
 	if err != nil {
-		return Model{}, Model{}, err
+		return Model{}, Model{}, fmt.Errorf("small model: %w", err)
 	}
+	return largeModel, smallModel, nil
+}
 
-	var largeCatwalkModel *catwalk.Model
-	var smallCatwalkModel *catwalk.Model
+// buildModel builds the language model for the given selection together with
+// its fallback models, fallbacks that can't be built are skipped.
+func (c *coordinator) buildModel(ctx context.Context, modelCfg config.SelectedModel) (Model, error) {
+	providerCfg, ok := c.cfg.Providers.Get(modelCfg.Provider)
+	if !ok {
+		return Model{}, errors.New("provider not configured")
+	}
 
-	for _, m := range largeProviderCfg.Models {
-		if m.ID == largeModelCfg.Model {
-			largeCatwalkModel = &m
+	var catwalkModel *catwalk.Model
+	for _, m := range providerCfg.Models {
+		if m.ID == modelCfg.Model {
+			catwalkModel = &m
 		}
 	}
-	for _, m := range smallProviderCfg.Models {
-		if m.ID == smallModelCfg.Model {
-			smallCatwalkModel = &m
-		}
+	if catwalkModel == nil {
+		return Model{}, errors.New("model not found in provider config")
 	}
 
-	if largeCatwalkModel == nil {
-		return Model{}, Model{}, errors.New("large model not found in provider config")
+	provider, err := c.buildProvider(providerCfg, modelCfg)
+	if err != nil {
+		return Model{}, err
 	}
 
-	if smallCatwalkModel == nil {
-		return Model{}, Model{}, errors.New("snall model not found in provider config")
+	modelID := modelCfg.Model
+	if modelCfg.Provider == openrouter.Name && isExactoSupported(modelID) {
+		modelID += ":exacto"
 	}
 
-	largeModelID := largeModelCfg.Model
-	smallModelID := smallModelCfg.Model
-
-	if largeModelCfg.Provider == openrouter.Name && isExactoSupported(largeModelID) {
-		largeModelID += ":exacto"
+	languageModel, err := provider.LanguageModel(ctx, modelID)
+	if err != nil {
+		return Model{}, err
 	}
 
-	if smallModelCfg.Provider == openrouter.Name && isExactoSupported(smallModelID) {
-		smallModelID += ":exacto"
+	if modelCfg.MaxTokens == 0 {
+		modelCfg.MaxTokens = catwalkModel.DefaultMaxTokens
 	}
-
-	largeModel, err := largeProvider.LanguageModel(ctx, largeModelID)
-	if err != nil {
-		return Model{}, Model{}, err
+	model := Model{
+		Model:      languageModel,
+		CatwalkCfg: *catwalkModel,
+		ModelCfg:   modelCfg,
+		Retry:      providerRetryOptions(providerCfg),
 	}
-	smallModel, err := smallProvider.LanguageModel(ctx, smallModelID)
-	if err != nil {
-		return Model{}, Model{}, err
+	for _, fallbackCfg := range modelCfg.Fallbacks {
+		if fallbackCfg.Provider == modelCfg.Provider && fallbackCfg.Model == modelCfg.Model {
+			continue
+		}
+		// fallbacks don't have fallbacks of their own
+		fallbackCfg.Fallbacks = nil
+		fallback, err := c.buildModel(ctx, fallbackCfg)
+		if err != nil {
+			slog.Warn("Skipping fallback model", "provider", fallbackCfg.Provider, "model", fallbackCfg.Model, "error", err)
+			continue
+		}
+		fallbackProviderCfg, _ := c.cfg.Providers.Get(fallbackCfg.Provider)
+		options, temp, topP, topK, freqPenalty, presPenalty := mergeCallOptions(fallback, fallbackProviderCfg)
+		fallback.Model = &callOptionsModel{
+			LanguageModel:    fallback.Model,
+			maxTokens:        fallback.ModelCfg.MaxTokens,
+			providerOptions:  options,
+			temperature:      temp,
+			topP:             topP,
+			topK:             topK,
+			frequencyPenalty: freqPenalty,
+			presencePenalty:  presPenalty,
+		}
+		model.Fallbacks = append(model.Fallbacks, fallback)
 	}
-
-	return Model{
-			Model:      largeModel,
-			CatwalkCfg: *largeCatwalkModel,
-			ModelCfg:   largeModelCfg,
-		}, Model{
-			Model:      smallModel,
-			CatwalkCfg: *smallCatwalkModel,
-			ModelCfg:   smallModelCfg,
-		}, nil
+	return model, nil
 }
 
 func (c *coordinator) buildAnthropicProvider(baseURL, apiKey string, headers map[string]string) (fantasy.Provider, error) {
@@ -696,23 +818,30 @@ func isExactoSupported(modelID string) bool {
 }
 
 func (c *coordinator) Cancel(sessionID string) {
-	c.currentAgent.Cancel(sessionID)
+	c.sessionAgent(sessionID).Cancel(sessionID)
 }
 
 func (c *coordinator) CancelAll() {
-	c.currentAgent.CancelAll()
+	for _, agent := range c.agents {
+		agent.CancelAll()
+	}
 }
 
 func (c *coordinator) ClearQueue(sessionID string) {
-	c.currentAgent.ClearQueue(sessionID)
+	c.sessionAgent(sessionID).ClearQueue(sessionID)
 }
 
 func (c *coordinator) IsBusy() bool {
-	return c.currentAgent.IsBusy()
+	for _, agent := range c.agents {
+		if agent.IsBusy() {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *coordinator) IsSessionBusy(sessionID string) bool {
-	return c.currentAgent.IsSessionBusy(sessionID)
+	return c.sessionAgent(sessionID).IsSessionBusy(sessionID)
 }
 
 func (c *coordinator) Model() Model {
@@ -720,34 +849,73 @@ func (c *coordinator) Model() Model {
 }
 
 func (c *coordinator) UpdateModels(ctx context.Context) error {
-	// build the models again so we make sure we get the latest config
-	large, small, err := c.buildAgentModels(ctx)
-	if err != nil {
-		return err
+	for id, agent := range c.agents {
+		agentCfg, ok := c.cfg.Agents[id]
+		if !ok {
+			return fmt.Errorf("%s agent not configured", id)
+		}
+
+		// build the models again so we make sure we get the latest config
+		large, small, err := c.buildAgentModels(ctx, agentCfg)
+		if err != nil {
+			return err
+		}
+		agent.SetModels(large, small)
+
+		tools, err := c.buildTools(ctx, agentCfg)
+		if err != nil {
+			return err
+		}
+		agent.SetTools(tools)
 	}
-	c.currentAgent.SetModels(large, small)
+	return nil
+}
 
-	agentCfg, ok := c.cfg.Agents[config.AgentCoder]
-	if !ok {
-		return errors.New("coder agent not configured")
+func (c *coordinator) QueuedPrompts(sessionID string) int {
+	return c.sessionAgent(sessionID).QueuedPrompts(sessionID)
+}
+
+func (c *coordinator) SetSessionAgent(sessionID, agentID string) error {
+	if _, ok := c.agents[agentID]; !ok {
+		return ErrAgentNotFound
 	}
+	if c.IsSessionBusy(sessionID) {
+		return ErrSessionBusy
+	}
+	c.sessionAgents.Set(sessionID, agentID)
+	return nil
+}
 
-	tools, err := c.buildTools(ctx, agentCfg)
-	if err != nil {
-		return err
+func (c *coordinator) SessionAgentID(sessionID string) string {
+	if agentID, ok := c.sessionAgents.Get(sessionID); ok {
+		if _, ok := c.agents[agentID]; ok {
+			return agentID
+		}
+	}
+	return config.AgentCoder
+}
+
+func (c *coordinator) SetPlanMode(sessionID string, enabled bool) error {
+	// the mode is read when a run starts, the prompts queued from now on get
+	// the new one
+	if enabled {
+		c.planSessions.Set(sessionID, true)
+	} else {
+		c.planSessions.Del(sessionID)
 	}
-	c.currentAgent.SetTools(tools)
 	return nil
 }
 
-func (c *coordinator) QueuedPrompts(sessionID string) int {
-	return c.currentAgent.QueuedPrompts(sessionID)
+func (c *coordinator) IsPlanMode(sessionID string) bool {
+	_, ok := c.planSessions.Get(sessionID)
+	return ok
 }
 
 func (c *coordinator) Summarize(ctx context.Context, sessionID string) error {
-	providerCfg, ok := c.cfg.Providers.Get(c.currentAgent.Model().ModelCfg.Provider)
+	agent := c.sessionAgent(sessionID)
+	providerCfg, ok := c.cfg.Providers.Get(agent.Model().ModelCfg.Provider)
 	if !ok {
 		return errors.New("model provider not configured")
 	}
-	return c.currentAgent.Summarize(ctx, sessionID, getProviderOptions(c.currentAgent.Model(), providerCfg))
+	return agent.Summarize(ctx, sessionID, getProviderOptions(agent.Model(), providerCfg))
 }
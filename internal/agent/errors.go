@@ -10,6 +10,8 @@ var (
 	ErrSessionBusy      = errors.New("session is currently processing another request")
 	ErrEmptyPrompt      = errors.New("prompt is empty")
 	ErrSessionMissing   = errors.New("session id is missing")
+	ErrAgentNotFound    = errors.New("agent not found")
+	ErrBudgetExceeded   = errors.New("budget exceeded")
 )
 
 func isCancelledErr(err error) bool {
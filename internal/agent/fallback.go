@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"log/slog"
+	"time"
+
+	"charm.land/fantasy"
+)
+
+// fallbackModel is a language model that retries the calls failing with a
+// retryable provider error (rate limits, overload or server errors) before
+// producing any output, following the retry policy of the model. Once the
+// retries are exhausted it moves to the next model of the fallback chain.
+//
+// Once it falls back it keeps using that model for the following calls, it
+// is meant to live for a single agent run.
+type fallbackModel struct {
+	models  []Model
+	current int
+
+	// onRetry is called before waiting to retry a call, attempt starts at 1.
+	onRetry func(model Model, attempt int, err *fantasy.APICallError, delay time.Duration)
+	// onFallback is called after switching to the next model.
+	onFallback func(from, to Model, err error)
+}
+
+func newFallbackModel(model Model) *fallbackModel {
+	return &fallbackModel{
+		models: append([]Model{model}, model.Fallbacks...),
+	}
+}
+
+// Current returns the model answering the calls.
+func (f *fallbackModel) Current() Model {
+	return f.models[f.current]
+}
+
+func (f *fallbackModel) Provider() string {
+	return f.Current().Model.Provider()
+}
+
+func (f *fallbackModel) Model() string {
+	return f.Current().Model.Model()
+}
+
+func (f *fallbackModel) Generate(ctx context.Context, call fantasy.Call) (*fantasy.Response, error) {
+	for {
+		model := f.Current()
+		retry := fantasy.RetryWithExponentialBackoffRespectingRetryHeaders[*fantasy.Response](f.retryOptions(model))
+		modelCall := call
+		modelCall.Prompt = prepareMediaResults(call.Prompt, model)
+		resp, err := retry(ctx, func() (*fantasy.Response, error) {
+			return model.Model.Generate(ctx, modelCall)
+		})
+		if err != nil && f.fallback(ctx, err) {
+			continue
+		}
+		return resp, err
+	}
+}
+
+func (f *fallbackModel) Stream(ctx context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	for {
+		model := f.Current()
+		retry := fantasy.RetryWithExponentialBackoffRespectingRetryHeaders[fantasy.StreamResponse](f.retryOptions(model))
+		modelCall := call
+		modelCall.Prompt = prepareMediaResults(call.Prompt, model)
+		stream, err := retry(ctx, func() (fantasy.StreamResponse, error) {
+			return startStream(ctx, model.Model, modelCall)
+		})
+		if err != nil && f.fallback(ctx, err) {
+			continue
+		}
+		return stream, err
+	}
+}
+
+// retryOptions returns the retry policy of the model reporting the retries.
+func (f *fallbackModel) retryOptions(model Model) fantasy.RetryOptions {
+	opts := model.Retry
+	attempt := 0
+	opts.OnRetry = func(err *fantasy.APICallError, delay time.Duration) {
+		attempt++
+		slog.Warn(
+			"Provider request failed, retrying",
+			"provider", model.ModelCfg.Provider,
+			"model", model.ModelCfg.Model,
+			"attempt", attempt,
+			"delay", delay,
+			"error", err,
+		)
+		if f.onRetry != nil {
+			f.onRetry(model, attempt, err, delay)
+		}
+	}
+	return opts
+}
+
+// fallback moves to the next model if the error allows it, it reports
+// whether there is a model to try.
+func (f *fallbackModel) fallback(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || !isRetryableErr(err) || f.current+1 >= len(f.models) {
+		return false
+	}
+	from := f.Current()
+	f.current++
+	to := f.Current()
+	slog.Warn(
+		"Model failed, falling back to the next model",
+		"from_provider", from.ModelCfg.Provider,
+		"from_model", from.ModelCfg.Model,
+		"to_provider", to.ModelCfg.Provider,
+		"to_model", to.ModelCfg.Model,
+		"error", err,
+	)
+	if f.onFallback != nil {
+		f.onFallback(from, to, err)
+	}
+	return true
+}
+
+// startStream starts the stream of the model and waits for it to produce
+// output, errors reported by the provider before that are returned so the
+// call can be retried.
+func startStream(ctx context.Context, model fantasy.LanguageModel, call fantasy.Call) (fantasy.StreamResponse, error) {
+	stream, err := model.Stream(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	next, stop := iter.Pull(stream)
+	// hold the parts until we know the model is answering, warnings come
+	// before the provider reports any error
+	var pending []fantasy.StreamPart
+	for {
+		part, ok := next()
+		if !ok {
+			break
+		}
+		pending = append(pending, part)
+		if part.Type != fantasy.StreamPartTypeWarnings {
+			break
+		}
+	}
+	if len(pending) > 0 && pending[len(pending)-1].Type == fantasy.StreamPartTypeError {
+		stop()
+		return nil, pending[len(pending)-1].Error
+	}
+
+	return func(yield func(fantasy.StreamPart) bool) {
+		defer stop()
+		for _, part := range pending {
+			if !yield(part) {
+				return
+			}
+		}
+		for {
+			part, ok := next()
+			if !ok || !yield(part) {
+				return
+			}
+		}
+	}, nil
+}
+
+// isRetryableErr reports whether the provider error is transient, such as
+// rate limits, overload or server errors.
+func isRetryableErr(err error) bool {
+	var apiErr *fantasy.APICallError
+	if errors.As(err, &apiErr) {
+		return apiErr.IsRetryable
+	}
+	var retryErr *fantasy.RetryError
+	if errors.As(err, &retryErr) && len(retryErr.Errors) > 0 {
+		return isRetryableErr(retryErr.Errors[len(retryErr.Errors)-1])
+	}
+	return false
+}
+
+// callOptionsModel replaces the options of the calls made to a fallback model
+// with its own, the agent builds the call options for the main model.
+type callOptionsModel struct {
+	fantasy.LanguageModel
+
+	maxTokens        int64
+	providerOptions  fantasy.ProviderOptions
+	temperature      *float64
+	topP             *float64
+	topK             *int64
+	frequencyPenalty *float64
+	presencePenalty  *float64
+}
+
+func (m *callOptionsModel) Generate(ctx context.Context, call fantasy.Call) (*fantasy.Response, error) {
+	return m.LanguageModel.Generate(ctx, m.prepareCall(call))
+}
+
+func (m *callOptionsModel) Stream(ctx context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	return m.LanguageModel.Stream(ctx, m.prepareCall(call))
+}
+
+func (m *callOptionsModel) prepareCall(call fantasy.Call) fantasy.Call {
+	if m.maxTokens > 0 && (call.MaxOutputTokens == nil || *call.MaxOutputTokens > m.maxTokens) {
+		call.MaxOutputTokens = &m.maxTokens
+	}
+	call.ProviderOptions = m.providerOptions
+	call.Temperature = m.temperature
+	call.TopP = m.topP
+	call.TopK = m.topK
+	call.FrequencyPenalty = m.frequencyPenalty
+	call.PresencePenalty = m.presencePenalty
+	return call
+}
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/hooks"
+)
+
+// maxTurnEndFeedback is the number of prompts in a row made of the feedback
+// of the turn end hooks, so a hook that keeps failing doesn't keep the agent
+// busy forever.
+const maxTurnEndFeedback = 3
+
+// hookTool runs the pre and post tool use hooks matching the calls of the
+// tool around them.
+type hookTool struct {
+	fantasy.AgentTool
+	workingDir string
+	hooks      config.Hooks
+}
+
+func withToolHooks(agentTools []fantasy.AgentTool, workingDir string, cfg config.Hooks) []fantasy.AgentTool {
+	if len(cfg.PreToolUse) == 0 && len(cfg.PostToolUse) == 0 {
+		return agentTools
+	}
+	wrapped := make([]fantasy.AgentTool, 0, len(agentTools))
+	for _, tool := range agentTools {
+		wrapped = append(wrapped, &hookTool{AgentTool: tool, workingDir: workingDir, hooks: cfg})
+	}
+	return wrapped
+}
+
+func (t *hookTool) Run(ctx context.Context, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	input := hooks.Input{
+		Event:      hooks.PreToolUse,
+		SessionID:  tools.GetSessionFromContext(ctx),
+		ToolCallID: call.ID,
+		ToolName:   call.Name,
+		ToolInput:  json.RawMessage(call.Input),
+	}
+	if !json.Valid(input.ToolInput) {
+		input.ToolInput = json.RawMessage("{}")
+	}
+
+	for _, hook := range t.hooks.PreToolUse {
+		if !hooks.MatchTool(hook, call.Name, call.Input) {
+			continue
+		}
+		result, err := hooks.Run(ctx, t.workingDir, hook.Command, hook.Timeout, input)
+		if err != nil {
+			slog.Warn("Pre tool use hook failed", "tool", call.Name, "error", err)
+			continue
+		}
+		if result.Blocked() {
+			return fantasy.NewTextErrorResponse(fmt.Sprintf("The %s call was blocked by a hook: %s", call.Name, result.Reason())), nil
+		}
+		if result.ExitCode != 0 {
+			slog.Warn("Pre tool use hook failed", "tool", call.Name, "exit_code", result.ExitCode, "output", result.Output())
+		}
+	}
+
+	response, err := t.AgentTool.Run(ctx, call)
+	if err != nil {
+		return response, err
+	}
+
+	input.Event = hooks.PostToolUse
+	input.ToolResponse = &hooks.ToolResponse{
+		Content: response.Content,
+		IsError: response.IsError,
+	}
+	for _, hook := range t.hooks.PostToolUse {
+		if !hooks.MatchTool(hook, call.Name, call.Input) {
+			continue
+		}
+		result, err := hooks.Run(ctx, t.workingDir, hook.Command, hook.Timeout, input)
+		if err != nil {
+			slog.Warn("Post tool use hook failed", "tool", call.Name, "error", err)
+			continue
+		}
+		// the media responses hold JSON, only the text ones get the feedback
+		output := result.Output()
+		if output == "" || response.Type != "text" {
+			continue
+		}
+		response.Content += fmt.Sprintf("\n\n<hook_feedback command=%q>\n%s\n</hook_feedback>", hook.Command, output)
+	}
+	return response, nil
+}
@@ -0,0 +1,224 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/hooks"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolHooks(t *testing.T) {
+	type params struct {
+		FilePath string `json:"file_path"`
+	}
+	ran := false
+	tool := fantasy.NewAgentTool("write", "Write a file", func(ctx context.Context, p params, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		ran = true
+		return fantasy.NewTextResponse("written"), nil
+	})
+	hooks := config.Hooks{
+		PreToolUse: []config.ToolHook{{
+			Tools:   []string{"write"},
+			Pattern: `\.env"`,
+			Command: "echo 'secrets are off limits' >&2; exit 2",
+		}},
+		PostToolUse: []config.ToolHook{{
+			Tools:   []string{"write"},
+			Command: "echo 'main.go: formatted'",
+		}},
+	}
+	wrapped := withToolHooks([]fantasy.AgentTool{tool}, t.TempDir(), hooks)[0]
+
+	response, err := wrapped.Run(t.Context(), fantasy.ToolCall{ID: "1", Name: "write", Input: `{"file_path":".env"}`})
+	require.NoError(t, err)
+	require.False(t, ran)
+	require.True(t, response.IsError)
+	require.Equal(t, "The write call was blocked by a hook: secrets are off limits", response.Content)
+
+	response, err = wrapped.Run(t.Context(), fantasy.ToolCall{ID: "2", Name: "write", Input: `{"file_path":"main.go"}`})
+	require.NoError(t, err)
+	require.True(t, ran)
+	require.False(t, response.IsError)
+	require.Equal(t, "written\n\n<hook_feedback command=\"echo 'main.go: formatted'\">\nmain.go: formatted\n</hook_feedback>", response.Content)
+}
+
+func TestSessionAgentLifecycleHooks(t *testing.T) {
+	env := testEnv(t)
+	catwalkCfg := catwalk.Model{
+		ContextWindow:    200000,
+		DefaultMaxTokens: 10000,
+	}
+	large := Model{Model: &fakeModel{text: "Done"}, CatwalkCfg: catwalkCfg}
+	small := Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg}
+	runner := hooks.NewRunner(env.workingDir, config.Hooks{
+		SessionStart: []config.Hook{{Command: "echo 'branch: main'"}},
+		TurnEnd: []config.Hook{{
+			Command: `grep -q '"turn_end_hook_active":true' && exit 0; echo 'tests fail' >&2; exit 2`,
+		}},
+	})
+	agent := NewSessionAgent(SessionAgentOptions{large, small, "", "system", false, true, env.sessions, env.messages, nil, config.Budget{}, config.Compaction{}, nil, runner})
+
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	_, err = agent.Run(t.Context(), SessionAgentCall{
+		Prompt:          "Fix the tests",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+	})
+	require.NoError(t, err)
+
+	msgs, err := env.messages.List(t.Context(), session.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+	require.Equal(t, "Fix the tests\n\n<hook_context>\nbranch: main\n</hook_context>", msgs[0].Content().Text)
+	require.Equal(t, message.User, msgs[2].Role)
+	require.Contains(t, msgs[2].Content().Text, "tests fail")
+}
+
+func TestSessionAgentBusyDuringHooks(t *testing.T) {
+	env := testEnv(t)
+	catwalkCfg := catwalk.Model{
+		ContextWindow:    200000,
+		DefaultMaxTokens: 10000,
+	}
+	large := Model{Model: &fakeModel{text: "Done"}, CatwalkCfg: catwalkCfg}
+	small := Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg}
+	runner := hooks.NewRunner(env.workingDir, config.Hooks{
+		SessionStart: []config.Hook{{Command: "echo start >> starts"}},
+		PromptSubmit: []config.Hook{{Command: "echo prompt >> prompts; sleep 0.5"}},
+		TurnEnd:      []config.Hook{{Command: "echo end >> ends; sleep 0.5"}},
+	})
+	agent := NewSessionAgent(SessionAgentOptions{large, small, "", "system", false, true, env.sessions, env.messages, nil, config.Budget{}, config.Compaction{}, nil, runner})
+
+	session, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		_, err := agent.Run(t.Context(), SessionAgentCall{
+			Prompt:          "Fix the tests",
+			SessionID:       session.ID,
+			MaxOutputTokens: 10000,
+		})
+		done <- err
+	}()
+
+	// the prompt submit hook of the first prompt is running
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(env.workingDir, "prompts"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, agent.IsSessionBusy(session.ID))
+
+	result, err := agent.Run(t.Context(), SessionAgentCall{
+		Prompt:          "Then commit",
+		SessionID:       session.ID,
+		MaxOutputTokens: 10000,
+	})
+	require.NoError(t, err)
+	require.Nil(t, result)
+	require.Equal(t, 1, agent.QueuedPrompts(session.ID))
+
+	// the turn end hook of the first prompt is running
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(env.workingDir, "ends"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, agent.IsSessionBusy(session.ID))
+	require.NoError(t, <-done)
+	require.False(t, agent.IsSessionBusy(session.ID))
+
+	starts, err := os.ReadFile(filepath.Join(env.workingDir, "starts"))
+	require.NoError(t, err)
+	require.Equal(t, "start\n", string(starts))
+	prompts, err := os.ReadFile(filepath.Join(env.workingDir, "prompts"))
+	require.NoError(t, err)
+	require.Equal(t, "prompt\nprompt\n", string(prompts))
+
+	msgs, err := env.messages.List(t.Context(), session.ID)
+	require.NoError(t, err)
+	var userPrompts []string
+	for _, msg := range msgs {
+		if msg.Role == message.User {
+			userPrompts = append(userPrompts, msg.Content().Text)
+		}
+	}
+	require.Equal(t, []string{"Fix the tests", "Then commit"}, userPrompts)
+}
+
+func TestSessionAgentCancelDuringHooks(t *testing.T) {
+	env := testEnv(t)
+	catwalkCfg := catwalk.Model{
+		ContextWindow:    200000,
+		DefaultMaxTokens: 10000,
+	}
+	large := Model{Model: &fakeModel{text: "Done"}, CatwalkCfg: catwalkCfg}
+	small := Model{Model: &fakeModel{text: "Title"}, CatwalkCfg: catwalkCfg}
+
+	// cancel waits for the hook writing the file to run and cancels the run,
+	// which returns soon after
+	cancel := func(t *testing.T, agent SessionAgent, sessionID, file string) {
+		done := make(chan error, 1)
+		go func() {
+			_, err := agent.Run(t.Context(), SessionAgentCall{
+				Prompt:          "Fix the tests",
+				SessionID:       sessionID,
+				MaxOutputTokens: 10000,
+			})
+			done <- err
+		}()
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(filepath.Join(env.workingDir, file))
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		agent.Cancel(sessionID)
+		require.False(t, agent.IsSessionBusy(sessionID))
+		select {
+		case err := <-done:
+			if file == "prompted" {
+				require.ErrorIs(t, err, context.Canceled)
+			} else {
+				require.NoError(t, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the run kept going after the cancel")
+		}
+		require.False(t, agent.IsSessionBusy(sessionID))
+		require.Zero(t, agent.QueuedPrompts(sessionID))
+	}
+
+	t.Run("prompt submit", func(t *testing.T) {
+		runner := hooks.NewRunner(env.workingDir, config.Hooks{
+			PromptSubmit: []config.Hook{{Command: "touch prompted; sleep 10"}},
+		})
+		agent := NewSessionAgent(SessionAgentOptions{large, small, "", "system", false, true, env.sessions, env.messages, nil, config.Budget{}, config.Compaction{}, nil, runner})
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+
+		cancel(t, agent, session.ID, "prompted")
+		msgs, err := env.messages.List(t.Context(), session.ID)
+		require.NoError(t, err)
+		require.Empty(t, msgs)
+	})
+
+	t.Run("turn end", func(t *testing.T) {
+		runner := hooks.NewRunner(env.workingDir, config.Hooks{
+			TurnEnd: []config.Hook{{Command: "touch ended; sleep 10; echo 'tests fail' >&2; exit 2"}},
+		})
+		agent := NewSessionAgent(SessionAgentOptions{large, small, "", "system", false, true, env.sessions, env.messages, nil, config.Budget{}, config.Compaction{}, nil, runner})
+		session, err := env.sessions.Create(t.Context(), "New Session")
+		require.NoError(t, err)
+
+		cancel(t, agent, session.ID, "ended")
+		msgs, err := env.messages.List(t.Context(), session.ID)
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+	})
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"charm.land/fantasy"
+	"charm.land/fantasy/providers/anthropic"
+	"charm.land/fantasy/providers/bedrock"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/csync"
+)
+
+// mediaTool keeps the media returned by the tool, keyed by tool call ID, and
+// hands its text to the agent. Fantasy only handles text tool responses, the
+// media is put back in the prompt by restoreToolMedia.
+type mediaTool struct {
+	fantasy.AgentTool
+	media *csync.Map[string, tools.Media]
+}
+
+func withToolMedia(agentTools []fantasy.AgentTool, media *csync.Map[string, tools.Media]) []fantasy.AgentTool {
+	wrapped := make([]fantasy.AgentTool, 0, len(agentTools))
+	for _, tool := range agentTools {
+		wrapped = append(wrapped, &mediaTool{AgentTool: tool, media: media})
+	}
+	return wrapped
+}
+
+func (t *mediaTool) Run(ctx context.Context, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	response, err := t.AgentTool.Run(ctx, call)
+	if err != nil {
+		return response, err
+	}
+	media, ok := tools.MediaFromResponse(response)
+	if !ok {
+		return response, nil
+	}
+	t.media.Set(call.ID, media)
+	response.Type = "text"
+	response.Content = media.Text
+	return response, nil
+}
+
+// restoreToolMedia replaces the text of the tool results that returned media
+// with the media.
+func restoreToolMedia(msgs []fantasy.Message, media *csync.Map[string, tools.Media]) {
+	for _, msg := range msgs {
+		if msg.Role != fantasy.MessageRoleTool {
+			continue
+		}
+		for i, part := range msg.Content {
+			result, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](part)
+			if !ok {
+				continue
+			}
+			if m, ok := media.Get(result.ToolCallID); ok {
+				result.Output = fantasy.ToolResultOutputContentMedia{
+					Data:      m.Data,
+					MediaType: m.MIMEType,
+				}
+				msg.Content[i] = result
+			}
+		}
+	}
+}
+
+// prepareMediaResults adapts the media tool results of the prompt to the
+// model. Only the anthropic models take images in tool results, the other
+// vision models get them in a user message following the tool results, and
+// the models that can't see them get a note instead.
+func prepareMediaResults(prompt fantasy.Prompt, model Model) fantasy.Prompt {
+	var (
+		native   = model.Model.Provider() == anthropic.Name || model.Model.Provider() == bedrock.Name
+		prepared = make(fantasy.Prompt, 0, len(prompt))
+		files    []fantasy.MessagePart
+	)
+	flushFiles := func() {
+		if len(files) == 0 {
+			return
+		}
+		prepared = append(prepared, fantasy.Message{
+			Role:    fantasy.MessageRoleUser,
+			Content: append([]fantasy.MessagePart{fantasy.TextPart{Text: "Images returned by the tools:"}}, files...),
+		})
+		files = nil
+	}
+
+	for _, msg := range prompt {
+		if msg.Role != fantasy.MessageRoleTool {
+			flushFiles()
+			prepared = append(prepared, msg)
+			continue
+		}
+
+		parts := make([]fantasy.MessagePart, 0, len(msg.Content))
+		for _, part := range msg.Content {
+			result, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](part)
+			if !ok {
+				parts = append(parts, part)
+				continue
+			}
+			media, ok := fantasy.AsToolResultOutputType[fantasy.ToolResultOutputContentMedia](result.Output)
+			if !ok {
+				parts = append(parts, part)
+				continue
+			}
+
+			viewable := strings.HasPrefix(media.MediaType, "image/") && model.CatwalkCfg.SupportsImages
+			if viewable && native {
+				parts = append(parts, part)
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(media.Data)
+			if viewable && err == nil {
+				files = append(files, fantasy.FilePart{
+					Data:      data,
+					MediaType: media.MediaType,
+				})
+				result.Output = fantasy.ToolResultOutputContentText{
+					Text: fmt.Sprintf("The tool returned an image (%s), it is attached to the next message.", media.MediaType),
+				}
+			} else {
+				result.Output = fantasy.ToolResultOutputContentText{
+					Text: fmt.Sprintf("The tool returned %s content, the current model can't view it.", media.MediaType),
+				}
+			}
+			parts = append(parts, result)
+		}
+		msg.Content = parts
+		prepared = append(prepared, msg)
+	}
+	flushFiles()
+	return prepared
+}
@@ -0,0 +1,36 @@
+package agent
+
+import (
+	_ "embed"
+	"fmt"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+)
+
+//go:embed templates/plan.md
+var planModePrompt []byte
+
+// PlanModeTools are the tools available to the agent in plan mode, along with
+// the tool to submit the plan.
+var PlanModeTools = []string{
+	tools.ViewToolName,
+	tools.GrepToolName,
+	tools.GlobToolName,
+	tools.LSToolName,
+	tools.DiagnosticsToolName,
+	tools.ReferencesToolName,
+	tools.FetchToolName,
+}
+
+// planModeTools returns the read-only tools of the agent and the tool to
+// submit the plan.
+func planModeTools(agentTools []fantasy.AgentTool) []fantasy.AgentTool {
+	return append(filterTools(agentTools, PlanModeTools), tools.NewPlanTool())
+}
+
+// ApprovedPlanPrompt returns the prompt asking the agent to implement the plan
+// approved by the user.
+func ApprovedPlanPrompt(plan string) string {
+	return fmt.Sprintf("The plan was approved, implement it:\n\n%s", plan)
+}
@@ -3,9 +3,12 @@ package prompt
 import (
 	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 	"text/template"
@@ -18,13 +21,19 @@ import (
 
 // Prompt represents a template-based prompt generator.
 type Prompt struct {
-	name       string
-	template   string
-	now        func() time.Time
-	platform   string
-	workingDir string
+	name         string
+	template     string
+	now          func() time.Time
+	platform     string
+	workingDir   string
+	contextPaths []string
+	templateDirs []string
 }
 
+// defaultTemplate is the name under which the prompt template is available to
+// the templates overriding it.
+const defaultTemplate = "default"
+
 type PromptDat struct {
 	Provider     string
 	Model        string
@@ -62,6 +71,30 @@ func WithWorkingDir(workingDir string) Option {
 	}
 }
 
+// WithContextPaths overrides the context paths from the config.
+func WithContextPaths(paths []string) Option {
+	return func(p *Prompt) {
+		p.contextPaths = paths
+	}
+}
+
+// WithTemplateDirs sets the directories templates overriding or extending the
+// prompt are loaded from, in order of precedence.
+func WithTemplateDirs(dirs ...string) Option {
+	return func(p *Prompt) {
+		p.templateDirs = dirs
+	}
+}
+
+// Dirs returns the directories prompt templates are loaded from, the project
+// ones first and then the user ones.
+func Dirs(workingDir string) []string {
+	return []string{
+		filepath.Join(workingDir, ".crush", "prompts"),
+		filepath.Join(filepath.Dir(config.GlobalConfig()), "prompts"),
+	}
+}
+
 func NewPrompt(name, promptTemplate string, opts ...Option) (*Prompt, error) {
 	p := &Prompt{
 		name:     name,
@@ -75,9 +108,9 @@ func NewPrompt(name, promptTemplate string, opts ...Option) (*Prompt, error) {
 }
 
 func (p *Prompt) Build(ctx context.Context, provider, model string, cfg config.Config) (string, error) {
-	t, err := template.New(p.name).Parse(p.template)
+	t, err := p.parse()
 	if err != nil {
-		return "", fmt.Errorf("parsing template: %w", err)
+		return "", err
 	}
 	var sb strings.Builder
 	d, err := p.promptData(ctx, provider, model, cfg)
@@ -91,6 +124,63 @@ func (p *Prompt) Build(ctx context.Context, provider, model string, cfg config.C
 	return sb.String(), nil
 }
 
+// parse parses the prompt template along with every template of the template
+// directories, which can redefine its blocks or replace it altogether with a
+// "<name>.md.tpl" template. The prompt template stays available as "default".
+func (p *Prompt) parse() (*template.Template, error) {
+	root := template.New(p.name)
+	if _, err := root.New(defaultTemplate).Parse(p.template); err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+	// parse the directories with the lowest precedence first so the
+	// definitions of the others win
+	for i := len(p.templateDirs) - 1; i >= 0; i-- {
+		if err := parseTemplateDir(root, p.templateDirs[i]); err != nil {
+			return nil, err
+		}
+	}
+	if t := root.Lookup(p.name + ".md"); t != nil {
+		return t, nil
+	}
+	return root.Lookup(defaultTemplate), nil
+}
+
+// parseTemplateDir adds the *.tpl files of dir to the templates, named after
+// their path relative to dir without the extension, e.g. "partials/go.md".
+func parseTemplateDir(root *template.Template, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".tpl" {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(rel), ".tpl")
+		if _, err := root.New(name).Parse(string(content)); err != nil {
+			return fmt.Errorf("parsing template %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// importPattern matches the lines of a context file importing another file,
+// e.g. "@docs/conventions.md".
+var importPattern = regexp.MustCompile(`^@(\S+)\s*$`)
+
+// maxImportDepth limits how deep imports can be nested.
+const maxImportDepth = 5
+
 func processFile(filePath string) *ContextFile {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -98,15 +188,51 @@ func processFile(filePath string) *ContextFile {
 	}
 	return &ContextFile{
 		Path:    filePath,
-		Content: string(content),
+		Content: expandImports(string(content), filePath, map[string]bool{filePath: true}),
+	}
+}
+
+// expandImports replaces the import lines of the content of filePath, outside
+// of code blocks, with the content of the imported files. Paths are relative
+// to the importing file, imports that can't be read are left as is.
+func expandImports(content, filePath string, seen map[string]bool) string {
+	if len(seen) > maxImportDepth || !strings.Contains(content, "@") {
+		return content
+	}
+	lines := strings.Split(content, "\n")
+	inCode := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		match := importPattern.FindStringSubmatch(line)
+		if inCode || match == nil {
+			continue
+		}
+		path := home.Long(match[1])
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(filePath), path)
+		}
+		if seen[path] {
+			continue
+		}
+		imported, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		seen[path] = true
+		lines[i] = strings.TrimSuffix(expandImports(string(imported), path, seen), "\n")
+		delete(seen, path)
 	}
+	return strings.Join(lines, "\n")
 }
 
-func processContextPath(p string, cfg config.Config) []ContextFile {
+func processContextPath(p string, baseDir string) []ContextFile {
 	var contexts []ContextFile
 	fullPath := p
 	if !filepath.IsAbs(p) {
-		fullPath = filepath.Join(cfg.WorkingDir(), p)
+		fullPath = filepath.Join(baseDir, p)
 	}
 	info, err := os.Stat(fullPath)
 	if err != nil {
@@ -133,6 +259,27 @@ func processContextPath(p string, cfg config.Config) []ContextFile {
 	return contexts
 }
 
+// ContextFiles returns the context files found in dir for the relative
+// context paths, the other paths only apply to the working directory.
+func ContextFiles(dir string, paths []string) []ContextFile {
+	var contexts []ContextFile
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		if filepath.IsAbs(p) || strings.HasPrefix(p, "~") || strings.HasPrefix(p, "$") {
+			continue
+		}
+		for _, file := range processContextPath(p, dir) {
+			key := strings.ToLower(file.Path)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			contexts = append(contexts, file)
+		}
+	}
+	return contexts
+}
+
 // expandPath expands ~ and environment variables in file paths
 func expandPath(path string, cfg config.Config) string {
 	path = home.Long(path)
@@ -150,15 +297,20 @@ func (p *Prompt) promptData(ctx context.Context, provider, model string, cfg con
 	workingDir := cmp.Or(p.workingDir, cfg.WorkingDir())
 	platform := cmp.Or(p.platform, runtime.GOOS)
 
+	contextPaths := cfg.Options.ContextPaths
+	if p.contextPaths != nil {
+		contextPaths = p.contextPaths
+	}
+
 	files := map[string][]ContextFile{}
 
-	for _, pth := range cfg.Options.ContextPaths {
+	for _, pth := range contextPaths {
 		expanded := expandPath(pth, cfg)
 		pathKey := strings.ToLower(expanded)
 		if _, ok := files[pathKey]; ok {
 			continue
 		}
-		content := processContextPath(expanded, cfg)
+		content := processContextPath(expanded, cfg.WorkingDir())
 		files[pathKey] = content
 	}
 
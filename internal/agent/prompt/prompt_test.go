@@ -0,0 +1,81 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func buildPrompt(t *testing.T, tmpl string, dirs ...string) string {
+	t.Helper()
+	p, err := NewPrompt("coder", tmpl, WithPlatform("linux"), WithTemplateDirs(dirs...))
+	require.NoError(t, err)
+	out, err := p.Build(t.Context(), "provider", "model", config.Config{Options: &config.Options{}})
+	require.NoError(t, err)
+	return out
+}
+
+const testTemplate = `{{block "rules" .}}<rules>default</rules>{{end}}
+{{block "extra" .}}{{end}}`
+
+func TestPromptTemplateDirs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing dirs", func(t *testing.T) {
+		t.Parallel()
+		out := buildPrompt(t, testTemplate, filepath.Join(t.TempDir(), "missing"))
+		require.Equal(t, "<rules>default</rules>\n", out)
+	})
+
+	t.Run("partials redefine blocks", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		writeTemplate(t, dir, "partials/extra.md.tpl", `{{define "extra"}}on {{.Platform}}{{end}}`)
+		writeTemplate(t, dir, "notes.md", `{{define "extra"}}ignored{{end}}`)
+		out := buildPrompt(t, testTemplate, dir)
+		require.Equal(t, "<rules>default</rules>\non linux", out)
+	})
+
+	t.Run("override includes the default", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		writeTemplate(t, dir, "coder.md.tpl", `{{template "header.md" .}}{{template "default" .}}`)
+		writeTemplate(t, dir, "header.md.tpl", "{{.Model}} header\n")
+		out := buildPrompt(t, testTemplate, dir)
+		require.Equal(t, "model header\n<rules>default</rules>\n", out)
+	})
+
+	t.Run("project wins over user", func(t *testing.T) {
+		t.Parallel()
+		project, user := t.TempDir(), t.TempDir()
+		writeTemplate(t, user, "rules.md.tpl", `{{define "rules"}}user{{end}}`)
+		writeTemplate(t, user, "extra.md.tpl", `{{define "extra"}}user extra{{end}}`)
+		writeTemplate(t, project, "rules.md.tpl", `{{define "rules"}}project{{end}}`)
+		out := buildPrompt(t, testTemplate, project, user)
+		require.Equal(t, "project\nuser extra", out)
+	})
+}
+
+func TestContextFilesImports(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, "CRUSH.md", "# Rules\n@shared/style.md\n```\n@not/imported.md\n```\n@missing.md\n")
+	writeTemplate(t, dir, "shared/style.md", "Use tabs.\n@../shared/style.md\n@nested.md\n")
+	writeTemplate(t, dir, "shared/nested.md", "Be nice.\n")
+
+	files := ContextFiles(dir, []string{"CRUSH.md", "crush.md", "/etc/CRUSH.md"})
+	require.Len(t, files, 1)
+	require.Equal(t, filepath.Join(dir, "CRUSH.md"), files[0].Path)
+	require.Equal(t, "# Rules\nUse tabs.\n@../shared/style.md\nBe nice.\n```\n@not/imported.md\n```\n@missing.md\n", files[0].Content)
+}
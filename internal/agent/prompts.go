@@ -1,9 +1,16 @@
 package agent
 
 import (
+	"cmp"
+	"context"
 	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/charmbracelet/crush/internal/agent/prompt"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/home"
 )
 
 //go:embed templates/coder.md.tpl
@@ -31,6 +38,53 @@ func taskPrompt(opts ...prompt.Option) (*prompt.Prompt, error) {
 	return systemPrompt, nil
 }
 
+// agentPrompt returns the system prompt for the given agent, either loaded
+// from the agent prompt file or one of the built-in prompts.
+func agentPrompt(agent config.Agent, workingDir string) (*prompt.Prompt, error) {
+	opts := []prompt.Option{
+		prompt.WithWorkingDir(workingDir),
+		prompt.WithContextPaths(agent.ContextPaths),
+		prompt.WithTemplateDirs(prompt.Dirs(workingDir)...),
+	}
+	if agent.Prompt != "" {
+		path := home.Long(agent.Prompt)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading prompt for agent %s: %w", agent.ID, err)
+		}
+		return prompt.NewPrompt(agent.ID, string(content), opts...)
+	}
+	if agent.ID == config.AgentTask {
+		return taskPrompt(opts...)
+	}
+	return coderPrompt(opts...)
+}
+
+// SystemPrompt renders the system prompt of the given agent for its large
+// model.
+func SystemPrompt(ctx context.Context, cfg *config.Config, agentID string) (string, error) {
+	agent, ok := cfg.Agents[agentID]
+	if !ok {
+		return "", fmt.Errorf("agent %s not found", agentID)
+	}
+	modelCfg, ok := cfg.AgentModel(agent, cmp.Or(agent.Model, config.SelectedModelTypeLarge))
+	if !ok {
+		return "", fmt.Errorf("%s model not selected", cmp.Or(agent.Model, config.SelectedModelTypeLarge))
+	}
+	provider := modelCfg.Provider
+	if providerCfg, ok := cfg.Providers.Get(modelCfg.Provider); ok {
+		provider = cmp.Or(string(providerCfg.Type), provider)
+	}
+	systemPrompt, err := agentPrompt(agent, cfg.WorkingDir())
+	if err != nil {
+		return "", err
+	}
+	return systemPrompt.Build(ctx, provider, modelCfg.Model, *cfg)
+}
+
 func InitializePrompt() string {
 	return string(initializePrompt)
 }
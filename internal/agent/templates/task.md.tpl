@@ -13,3 +13,4 @@ Platform: {{.Platform}}
 Today's date: {{.Date}}
 </env>
 
+{{block "extra" .}}{{end}}
\ No newline at end of file
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/agent/prompt"
+	"github.com/charmbracelet/crush/internal/csync"
+)
+
+// ContextLoader loads the context files of the subdirectories of the working
+// directory, e.g. services/api/AGENTS.md, when the agent reads or edits a
+// file under them and they aren't in the messages sent to the model yet. The
+// ones of the working directory itself are already part of the system prompt.
+type ContextLoader struct {
+	workingDir string
+	paths      []string
+	// context files loaded by session, keyed by session ID and path, when
+	// the context doesn't tell the ones in the messages sent to the model
+	loaded *csync.Map[string, bool]
+}
+
+type loadedContextFilesKey struct{}
+
+// WithLoadedContextFiles returns a context telling the tools the context
+// files in the messages sent to the model, by path. The ones dropped from the
+// messages, by a summary, a pruning or a rewind, are loaded again.
+func WithLoadedContextFiles(ctx context.Context, loaded *csync.Map[string, bool]) context.Context {
+	return context.WithValue(ctx, loadedContextFilesKey{}, loaded)
+}
+
+var contextFilePathRe = regexp.MustCompile(`<file path="([^"]+)">`)
+
+// LoadedContextFiles returns the paths of the context files loaded in the
+// result of a tool.
+func LoadedContextFiles(result string) []string {
+	_, memory, ok := strings.Cut(result, contextFilesHeader)
+	if !ok {
+		return nil
+	}
+	memory, _, _ = strings.Cut(memory, "</memory>")
+	var paths []string
+	for _, match := range contextFilePathRe.FindAllStringSubmatch(memory, -1) {
+		paths = append(paths, match[1])
+	}
+	return paths
+}
+
+const contextFilesHeader = "\n<memory>\nThe following context files apply to the files under their directory, follow them when working there:\n"
+
+func NewContextLoader(workingDir string, paths []string) *ContextLoader {
+	return &ContextLoader{
+		workingDir: filepath.Clean(workingDir),
+		paths:      paths,
+		loaded:     csync.NewMap[string, bool](),
+	}
+}
+
+// Load returns the context files of the directories containing filePath
+// that weren't loaded in the session yet, formatted to be appended to the
+// result of the tool. It returns an empty string when there are none.
+func (l *ContextLoader) Load(ctx context.Context, filePath string) string {
+	sessionID := GetSessionFromContext(ctx)
+	if l == nil || sessionID == "" {
+		return ""
+	}
+	loaded, prefix := l.loaded, sessionID+":"
+	if inPrompt, ok := ctx.Value(loadedContextFilesKey{}).(*csync.Map[string, bool]); ok {
+		loaded, prefix = inPrompt, ""
+	}
+	rel, err := filepath.Rel(l.workingDir, filepath.Dir(filePath))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+
+	// from the outermost directory to the innermost one, so the most
+	// specific instructions come last
+	var dirs []string
+	for dir := filepath.Dir(filePath); dir != l.workingDir && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	slices.Reverse(dirs)
+
+	var files []prompt.ContextFile
+	for _, dir := range dirs {
+		for _, file := range prompt.ContextFiles(dir, l.paths) {
+			key := prefix + filepath.ToSlash(file.Path)
+			if _, ok := loaded.Get(key); ok {
+				continue
+			}
+			loaded.Set(key, true)
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(contextFilesHeader)
+	for _, file := range files {
+		fmt.Fprintf(&sb, "<file path=\"%s\">\n%s\n</file>\n", filepath.ToSlash(file.Path), file.Content)
+	}
+	sb.WriteString("</memory>\n")
+	return sb.String()
+}
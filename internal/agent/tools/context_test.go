@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextLoader(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(workingDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	write("AGENTS.md", "root rules")
+	write("services/AGENTS.md", "services rules")
+	write("services/api/AGENTS.md", "api rules")
+	write("services/api/handlers/user.go", "package handlers")
+	write("services/web/main.go", "package main")
+
+	loader := NewContextLoader(workingDir, []string{"AGENTS.md"})
+	file := filepath.Join(workingDir, "services", "api", "handlers", "user.go")
+	session := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	other := context.WithValue(t.Context(), SessionIDContextKey, "other")
+
+	out := loader.Load(session, file)
+	require.NotContains(t, out, "root rules")
+	require.Contains(t, out, "services rules")
+	require.Contains(t, out, "api rules")
+	require.Less(t, strings.Index(out, "services rules"), strings.Index(out, "api rules"))
+
+	require.Empty(t, loader.Load(session, file), "context files are only loaded once per session")
+	require.Empty(t, loader.Load(session, filepath.Join(workingDir, "services", "web", "main.go")))
+	require.Contains(t, loader.Load(other, file), "api rules")
+	require.Empty(t, loader.Load(session, filepath.Join(workingDir, "main.go")))
+	require.Empty(t, loader.Load(session, filepath.Join(filepath.Dir(workingDir), "main.go")))
+
+	require.ElementsMatch(t, []string{
+		filepath.ToSlash(filepath.Join(workingDir, "services", "AGENTS.md")),
+		filepath.ToSlash(filepath.Join(workingDir, "services", "api", "AGENTS.md")),
+	}, LoadedContextFiles("<file>\n</file>\n"+out))
+	require.Empty(t, LoadedContextFiles("<file>\n</file>\n"))
+
+	// the context files are loaded again once they're out of the messages
+	// sent to the model
+	loaded := csync.NewMap[string, bool]()
+	loaded.Set(filepath.ToSlash(filepath.Join(workingDir, "services", "AGENTS.md")), true)
+	inPrompt := WithLoadedContextFiles(session, loaded)
+	out = loader.Load(inPrompt, file)
+	require.NotContains(t, out, "services rules")
+	require.Contains(t, out, "api rules")
+	require.Empty(t, loader.Load(inPrompt, file))
+
+	var nilLoader *ContextLoader
+	require.Empty(t, nilLoader.Load(session, file))
+}
@@ -53,7 +53,7 @@ type editContext struct {
 	workingDir  string
 }
 
-func NewEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string) fantasy.AgentTool {
+func NewEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string, contextFiles *ContextLoader) fantasy.AgentTool {
 	return fantasy.NewAgentTool(
 		EditToolName,
 		string(editDescription),
@@ -97,6 +97,7 @@ func NewEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permiss
 
 			text := fmt.Sprintf("<result>\n%s\n</result>\n", response.Content)
 			text += getDiagnostics(params.FilePath, lspClients)
+			text += contextFiles.Load(ctx, params.FilePath)
 			response.Content = text
 			return response, nil
 		})
@@ -147,7 +148,7 @@ func createNewFile(edit editContext, filePath, content string, call fantasy.Tool
 		return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
 	}
 
-	err = os.WriteFile(filePath, []byte(content), 0o644)
+	err = writeFile(edit.ctx, filePath, []byte(content))
 	if err != nil {
 		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
@@ -206,7 +207,7 @@ func deleteContent(edit editContext, filePath, oldString string, replaceAll bool
 			)), nil
 	}
 
-	content, err := os.ReadFile(filePath)
+	content, err := readFile(edit.ctx, filePath)
 	if err != nil {
 		return fantasy.ToolResponse{}, fmt.Errorf("failed to read file: %w", err)
 	}
@@ -272,7 +273,7 @@ func deleteContent(edit editContext, filePath, oldString string, replaceAll bool
 		newContent, _ = fsext.ToWindowsLineEndings(newContent)
 	}
 
-	err = os.WriteFile(filePath, []byte(newContent), 0o644)
+	err = writeFile(edit.ctx, filePath, []byte(newContent))
 	if err != nil {
 		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
@@ -339,7 +340,7 @@ func replaceContent(edit editContext, filePath, oldString, newString string, rep
 			)), nil
 	}
 
-	content, err := os.ReadFile(filePath)
+	content, err := readFile(edit.ctx, filePath)
 	if err != nil {
 		return fantasy.ToolResponse{}, fmt.Errorf("failed to read file: %w", err)
 	}
@@ -407,7 +408,7 @@ func replaceContent(edit editContext, filePath, oldString, newString string, rep
 		newContent, _ = fsext.ToWindowsLineEndings(newContent)
 	}
 
-	err = os.WriteFile(filePath, []byte(newContent), 0o644)
+	err = writeFile(edit.ctx, filePath, []byte(newContent))
 	if err != nil {
 		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+)
+
+// FileSystem reads and writes the files of the view, edit and write tools.
+// The editors driving the agent provide their own, to serve the content of
+// their unsaved buffers and keep track of the changes.
+type FileSystem interface {
+	ReadFile(ctx context.Context, path string) ([]byte, error)
+	WriteFile(ctx context.Context, path string, data []byte) error
+}
+
+type fileSystemContextKey string
+
+// FileSystemContextKey is the context key of the file system of the tools,
+// the local one is used when there is none.
+const FileSystemContextKey fileSystemContextKey = "file_system"
+
+// LocalFileSystem reads and writes the files on disk, the default one.
+type LocalFileSystem struct{}
+
+func (LocalFileSystem) ReadFile(_ context.Context, path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (LocalFileSystem) WriteFile(_ context.Context, path string, data []byte) error {
+	return os.WriteFile(path, data, 0o644)
+}
+
+func GetFileSystemFromContext(ctx context.Context) FileSystem {
+	fs, ok := ctx.Value(FileSystemContextKey).(FileSystem)
+	if !ok || fs == nil {
+		return LocalFileSystem{}
+	}
+	return fs
+}
+
+func readFile(ctx context.Context, path string) ([]byte, error) {
+	return GetFileSystemFromContext(ctx).ReadFile(ctx, path)
+}
+
+func writeFile(ctx context.Context, path string, data []byte) error {
+	return GetFileSystemFromContext(ctx).WriteFile(ctx, path, data)
+}
+
+// openFile opens the file for reading, through the file system of the
+// context when there is one.
+func openFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	if _, ok := GetFileSystemFromContext(ctx).(LocalFileSystem); ok {
+		return os.Open(path)
+	}
+	data, err := readFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+// bufferFileSystem serves the files from the buffers of an editor.
+type bufferFileSystem struct {
+	buffers map[string]string
+}
+
+func (fs *bufferFileSystem) ReadFile(_ context.Context, path string) ([]byte, error) {
+	content, ok := fs.buffers[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(content), nil
+}
+
+func (fs *bufferFileSystem) WriteFile(_ context.Context, path string, data []byte) error {
+	fs.buffers[path] = string(data)
+	return nil
+}
+
+func TestFileSystemFromContext(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte("package main\n"), 0o644))
+
+	fs := &bufferFileSystem{buffers: map[string]string{path: "package main\n\nfunc main() {}\n"}}
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	ctx = context.WithValue(ctx, FileSystemContextKey, fs)
+
+	lspClients := csync.NewMap[string, *lsp.Client]()
+	permissions := &mockPermissionService{Broker: pubsub.NewBroker[permission.PermissionRequest]()}
+	files := &mockHistoryService{Broker: pubsub.NewBroker[history.File]()}
+
+	view := NewViewTool(lspClients, permissions, tmpDir, nil)
+	response, err := view.Run(ctx, fantasy.ToolCall{ID: "1", Name: ViewToolName, Input: `{"file_path":"main.go"}`})
+	require.NoError(t, err)
+	require.Contains(t, response.Content, "func main() {}")
+
+	write := NewWriteTool(lspClients, permissions, files, tmpDir, nil)
+	response, err = write.Run(ctx, fantasy.ToolCall{ID: "2", Name: WriteToolName, Input: `{"file_path":"main.go","content":"package app\n"}`})
+	require.NoError(t, err)
+	require.False(t, response.IsError, response.Content)
+	require.Equal(t, "package app\n", fs.buffers[path])
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "package main\n", string(content), "the file on disk is left to the editor")
+}
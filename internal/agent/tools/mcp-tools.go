@@ -164,13 +164,34 @@ func runTool(ctx context.Context, name, toolName string, input string) (fantasy.
 	}
 
 	output := make([]string, 0, len(result.Content))
+	// only the first media is sent to the model, the others are described
+	var (
+		mediaData     []byte
+		mediaMIMEType string
+	)
 	for _, v := range result.Content {
-		if vv, ok := v.(*mcp.TextContent); ok {
+		switch vv := v.(type) {
+		case *mcp.TextContent:
 			output = append(output, vv.Text)
-		} else {
+		case *mcp.ImageContent:
+			if mediaData == nil {
+				mediaData, mediaMIMEType = vv.Data, vv.MIMEType
+			} else {
+				output = append(output, fmt.Sprintf("[%s image]", vv.MIMEType))
+			}
+		case *mcp.AudioContent:
+			if mediaData == nil {
+				mediaData, mediaMIMEType = vv.Data, vv.MIMEType
+			} else {
+				output = append(output, fmt.Sprintf("[%s audio]", vv.MIMEType))
+			}
+		default:
 			output = append(output, fmt.Sprintf("%v", v))
 		}
 	}
+	if mediaData != nil {
+		return NewMediaResponse(mediaData, mediaMIMEType, strings.Join(output, "\n")), nil
+	}
 	return fantasy.NewTextResponse(strings.Join(output, "\n")), nil
 }
 
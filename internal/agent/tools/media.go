@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"charm.land/fantasy"
+)
+
+// MediaResponseType is the type of the tool responses carrying media.
+const MediaResponseType = "media"
+
+// Media is an image or other binary output of a tool.
+type Media struct {
+	// Text describes the media, it is what the models that can't see the
+	// media get.
+	Text string `json:"text"`
+	// Data is the base64 encoded media.
+	Data     string `json:"data"`
+	MIMEType string `json:"mime_type"`
+}
+
+// IsImage reports whether the media is an image.
+func (m Media) IsImage() bool {
+	return strings.HasPrefix(m.MIMEType, "image/")
+}
+
+// NewMediaResponse creates a response carrying media for the model, the
+// agent sends it to the models that support it and falls back to the text
+// otherwise.
+func NewMediaResponse(data []byte, mimeType, text string) fantasy.ToolResponse {
+	content, err := json.Marshal(Media{
+		Text:     text,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		MIMEType: mimeType,
+	})
+	if err != nil {
+		return fantasy.NewTextResponse(text)
+	}
+	return fantasy.ToolResponse{
+		Type:    MediaResponseType,
+		Content: string(content),
+	}
+}
+
+// MediaFromResponse returns the media of a response created with
+// NewMediaResponse.
+func MediaFromResponse(response fantasy.ToolResponse) (Media, bool) {
+	if response.Type != MediaResponseType || response.IsError {
+		return Media{}, false
+	}
+	var media Media
+	if err := json.Unmarshal([]byte(response.Content), &media); err != nil || media.Data == "" {
+		return Media{}, false
+	}
+	return media, true
+}
@@ -30,6 +30,8 @@ func (m *mockPermissionService) GrantPersistent(req permission.PermissionRequest
 
 func (m *mockPermissionService) AutoApproveSession(sessionID string) {}
 
+func (m *mockPermissionService) SetDenyRequests(deny bool) {}
+
 func (m *mockPermissionService) SetSkipRequests(skip bool) {}
 
 func (m *mockPermissionService) SkipRequests() bool {
@@ -76,6 +78,22 @@ func (m *mockHistoryService) DeleteSessionFiles(ctx context.Context, sessionID s
 	return nil
 }
 
+func (m *mockHistoryService) CreateCheckpoint(ctx context.Context, sessionID, messageID string) (history.Checkpoint, error) {
+	return history.Checkpoint{}, nil
+}
+
+func (m *mockHistoryService) ListCheckpoints(ctx context.Context, sessionID string) ([]history.Checkpoint, error) {
+	return nil, nil
+}
+
+func (m *mockHistoryService) RewindChanges(ctx context.Context, sessionID, messageID string) ([]history.FileChange, error) {
+	return nil, nil
+}
+
+func (m *mockHistoryService) Rewind(ctx context.Context, sessionID, messageID string) ([]history.FileChange, error) {
+	return nil, nil
+}
+
 func TestApplyEditToContentPartialSuccess(t *testing.T) {
 	t.Parallel()
 
@@ -116,7 +134,7 @@ func TestMultiEditSequentialApplication(t *testing.T) {
 	files := &mockHistoryService{Broker: pubsub.NewBroker[history.File]()}
 
 	// Create multiedit tool.
-	_ = NewMultiEditTool(lspClients, permissions, files, tmpDir)
+	_ = NewMultiEditTool(lspClients, permissions, files, tmpDir, nil)
 
 	// Simulate reading the file first.
 	recordFileRead(testFile)
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"charm.land/fantasy"
+)
+
+const PlanToolName = "submit_plan"
+
+//go:embed plan.md
+var planDescription []byte
+
+type PlanStep struct {
+	Description string   `json:"description" description:"What to do in this step"`
+	Files       []string `json:"files,omitempty" description:"The files changed by this step"`
+}
+
+type PlanParams struct {
+	Title   string     `json:"title" description:"A short title for the plan"`
+	Summary string     `json:"summary" description:"The goal of the change and the approach taken"`
+	Steps   []PlanStep `json:"steps" description:"The ordered steps to implement the plan"`
+}
+
+type PlanResponseMetadata struct {
+	Plan PlanParams `json:"plan"`
+}
+
+// Markdown returns the plan formatted as markdown.
+func (p PlanParams) Markdown() string {
+	var sb strings.Builder
+	if p.Title != "" {
+		fmt.Fprintf(&sb, "# %s\n\n", p.Title)
+	}
+	if p.Summary != "" {
+		fmt.Fprintf(&sb, "%s\n\n", strings.TrimSpace(p.Summary))
+	}
+	for i, step := range p.Steps {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, strings.TrimSpace(step.Description))
+		for _, file := range step.Files {
+			fmt.Fprintf(&sb, "   - `%s`\n", file)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func NewPlanTool() fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		PlanToolName,
+		string(planDescription),
+		func(ctx context.Context, params PlanParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			if len(params.Steps) == 0 {
+				return fantasy.NewTextErrorResponse("the plan needs at least one step"), nil
+			}
+			return fantasy.WithResponseMetadata(
+				fantasy.NewTextResponse("Plan submitted, the user will review it before it is implemented."),
+				PlanResponseMetadata{Plan: params},
+			), nil
+		})
+}
@@ -51,7 +51,7 @@ const (
 	MaxLineLength    = 2000
 )
 
-func NewViewTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, workingDir string) fantasy.AgentTool {
+func NewViewTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, workingDir string, contextFiles *ContextLoader) fantasy.AgentTool {
 	return fantasy.NewAgentTool(
 		ViewToolName,
 		string(viewDescription),
@@ -149,13 +149,26 @@ func NewViewTool(lspClients *csync.Map[string, *lsp.Client], permissions permiss
 
 			// Check if it's an image file
 			isImage, imageType := isImageFile(filePath)
-			// TODO: handle images
 			if isImage {
-				return fantasy.NewTextErrorResponse(fmt.Sprintf("This is an image file of type: %s\n", imageType)), nil
+				mimeType := imageMIMEType(filePath)
+				if mimeType == "" {
+					return fantasy.NewTextErrorResponse(fmt.Sprintf("This is an image file of type: %s\n", imageType)), nil
+				}
+				data, err := readFile(ctx, filePath)
+				if err != nil {
+					return fantasy.ToolResponse{}, fmt.Errorf("error reading file: %w", err)
+				}
+				recordFileRead(filePath)
+				return fantasy.WithResponseMetadata(
+					NewMediaResponse(data, mimeType, fmt.Sprintf("This is an image file of type: %s", imageType)),
+					ViewResponseMetadata{
+						FilePath: filePath,
+					},
+				), nil
 			}
 
 			// Read the file content
-			content, lineCount, err := readTextFile(filePath, params.Offset, params.Limit)
+			content, lineCount, err := readTextFile(ctx, filePath, params.Offset, params.Limit)
 			isValidUt8 := utf8.ValidString(content)
 			if !isValidUt8 {
 				return fantasy.NewTextErrorResponse("File content is not valid UTF-8"), nil
@@ -176,6 +189,7 @@ func NewViewTool(lspClients *csync.Map[string, *lsp.Client], permissions permiss
 			}
 			output += "\n</file>\n"
 			output += getDiagnostics(filePath, lspClients)
+			output += contextFiles.Load(ctx, filePath)
 			recordFileRead(filePath)
 			return fantasy.WithResponseMetadata(
 				fantasy.NewTextResponse(output),
@@ -212,8 +226,8 @@ func addLineNumbers(content string, startLine int) string {
 	return strings.Join(result, "\n")
 }
 
-func readTextFile(filePath string, offset, limit int) (string, int, error) {
-	file, err := os.Open(filePath)
+func readTextFile(ctx context.Context, filePath string, offset, limit int) (string, int, error) {
+	file, err := openFile(ctx, filePath)
 	if err != nil {
 		return "", 0, err
 	}
@@ -231,13 +245,6 @@ func readTextFile(filePath string, offset, limit int) (string, int, error) {
 		}
 	}
 
-	if offset == 0 {
-		_, err = file.Seek(0, io.SeekStart)
-		if err != nil {
-			return "", 0, err
-		}
-	}
-
 	// Pre-allocate slice with expected capacity
 	lines := make([]string, 0, limit)
 	lineCount = offset
@@ -283,6 +290,23 @@ func isImageFile(filePath string) (bool, string) {
 	}
 }
 
+// imageMIMEType returns the MIME type of the images that can be sent to the
+// models, it is empty for the others.
+func imageMIMEType(filePath string) string {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	default:
+		return ""
+	}
+}
+
 type LineScanner struct {
 	scanner *bufio.Scanner
 }
@@ -1,15 +1,18 @@
 package app
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"slices"
 	"sync"
 	"time"
 
-	"charm.land/fantasy"
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/crush/internal/agent"
 	"github.com/charmbracelet/crush/internal/agent/tools"
@@ -101,14 +104,50 @@ func (app *App) Config() *config.Config {
 	return app.config
 }
 
+// NonInteractiveOptions are the options of a non-interactive run.
+type NonInteractiveOptions struct {
+	// Quiet hides the spinner.
+	Quiet bool
+	// OutputFormat is the format of the output, text if empty.
+	OutputFormat OutputFormat
+	// SessionID is the session to send the prompt to, a new session is
+	// created if empty.
+	SessionID string
+	// Continue sends the prompt to the most recently used session.
+	Continue bool
+	// Title is the title of the session, generated from the prompt if
+	// empty.
+	Title string
+	// PrintSessionID prints the ID of the session on the standard error.
+	PrintSessionID bool
+	// MaxTurns stops the agent after that many requests to the model, 0
+	// means no limit.
+	MaxTurns int
+	// MaxCost stops the agent once the run cost that much, in USD, instead
+	// of the configured run budget.
+	MaxCost float64
+}
+
 // RunNonInteractive handles the execution flow when a prompt is provided via
 // CLI flag.
-func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool) error {
+func (app *App) RunNonInteractive(ctx context.Context, prompt string, opts NonInteractiveOptions) error {
 	slog.Info("Running in non-interactive mode")
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	outputFormat := cmp.Or(opts.OutputFormat, OutputText)
+	// the JSON output formats keep the standard output for the events
+	quiet := opts.Quiet || outputFormat != OutputText
+
+	sess, err := app.nonInteractiveSession(ctx, prompt, opts)
+	if err != nil {
+		return err
+	}
+	if opts.PrintSessionID {
+		fmt.Fprintln(os.Stderr, sess.ID)
+	}
+
 	var spinner *format.Spinner
 	if !quiet {
 		spinner = format.NewSpinner(ctx, cancel, "Generating")
@@ -124,61 +163,68 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 	defer stopSpinner()
 
-	const maxPromptLengthForTitle = 100
-	titlePrefix := "Non-interactive: "
-	var titleSuffix string
+	// Nobody is there to answer the permission requests, only the allowed
+	// tools run
+	app.Permissions.SetDenyRequests(true)
 
-	if len(prompt) > maxPromptLengthForTitle {
-		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
-	} else {
-		titleSuffix = prompt
-	}
-	title := titlePrefix + titleSuffix
+	done := make(chan error, 1)
 
-	sess, err := app.Sessions.Create(ctx, title)
-	if err != nil {
-		return fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+	var runOpts agent.RunOptions
+	if budget := app.config.Options.Budget; budget != nil {
+		runOpts.CostBudget = budget.RunCost
+		runOpts.TokenBudget = budget.RunTokens
 	}
-	slog.Info("Created session for non-interactive run", "session_id", sess.ID)
-
-	// Automatically approve all permission requests for this non-interactive session
-	app.Permissions.AutoApproveSession(sess.ID)
+	if opts.MaxCost > 0 {
+		runOpts.CostBudget = opts.MaxCost
+	}
+	runOpts.KeepTitle = opts.Title != ""
+	runOpts.MaxTurns = opts.MaxTurns
 
-	type response struct {
-		result *fantasy.AgentResult
-		err    error
+	messageEvents := app.Messages.Subscribe(ctx)
+	retryEvents := app.Messages.SubscribeRetries(ctx)
+	budgetEvents := app.Sessions.SubscribeBudgets(ctx)
+	sessionEvents := app.Sessions.Subscribe(ctx)
+	permissionEvents := app.Permissions.SubscribeNotifications(ctx)
+	printer, err := app.sessionPrinter(ctx, os.Stdout, outputFormat, sess.ID)
+	if err != nil {
+		return err
 	}
-	done := make(chan response, 1)
 
 	go func(ctx context.Context, sessionID, prompt string) {
-		result, err := app.AgentCoordinator.Run(ctx, sess.ID, prompt)
+		_, err := app.AgentCoordinator.RunWithOptions(ctx, sessionID, prompt, runOpts)
 		if err != nil {
-			done <- response{
-				err: fmt.Errorf("failed to start agent processing stream: %w", err),
-			}
-		}
-		done <- response{
-			result: result,
+			err = fmt.Errorf("failed to start agent processing stream: %w", err)
 		}
+		done <- err
 	}(ctx, sess.ID, prompt)
 
-	messageEvents := app.Messages.Subscribe(ctx)
-	messageReadBytes := make(map[string]int)
-
-	defer fmt.Printf(ansi.ResetProgressBar)
+	if outputFormat == OutputText {
+		defer fmt.Printf(ansi.ResetProgressBar)
+	}
 	for {
-		// HACK: add it again on every iteration so it doesn't get hidden by
-		// the terminal due to inactivity.
-		fmt.Printf(ansi.SetIndeterminateProgressBar)
+		if outputFormat == OutputText {
+			// HACK: add it again on every iteration so it doesn't get hidden by
+			// the terminal due to inactivity.
+			fmt.Printf(ansi.SetIndeterminateProgressBar)
+		}
 		select {
-		case result := <-done:
+		case runErr := <-done:
 			stopSpinner()
-			if result.err != nil {
-				if errors.Is(result.err, context.Canceled) || errors.Is(result.err, agent.ErrRequestCancelled) {
-					slog.Info("Non-interactive: agent processing cancelled", "session_id", sess.ID)
-					return nil
-				}
-				return fmt.Errorf("agent processing failed: %w", result.err)
+			if errors.Is(runErr, context.Canceled) || errors.Is(runErr, agent.ErrRequestCancelled) {
+				slog.Info("Non-interactive: agent processing cancelled", "session_id", sess.ID)
+				runErr = nil
+			}
+			// the events are dropped when the subscribers are slow, catch up
+			// with what's stored
+			if err := app.catchUp(ctx, printer, sess.ID); err != nil {
+				slog.Error("Non-interactive: failed to load the session", "session_id", sess.ID, "error", err)
+			}
+			if runErr == nil && printer.stopReason == message.FinishReasonMaxTurns {
+				runErr = fmt.Errorf("stopped after %d turns", opts.MaxTurns)
+			}
+			printer.finish(runErr)
+			if runErr != nil {
+				return fmt.Errorf("agent processing failed: %w", runErr)
 			}
 			return nil
 
@@ -186,18 +232,48 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 			msg := event.Payload
 			if msg.SessionID == sess.ID && msg.Role == message.Assistant && len(msg.Parts) > 0 {
 				stopSpinner()
+			}
+			if err := printer.message(msg); err != nil {
+				slog.Error("Non-interactive: failed to print the message", "error", err)
+				return err
+			}
 
-				content := msg.Content().String()
-				readBytes := messageReadBytes[msg.ID]
+		case event := <-sessionEvents:
+			printer.session(event.Payload)
 
-				if len(content) < readBytes {
-					slog.Error("Non-interactive: message content is shorter than read bytes", "message_length", len(content), "read_bytes", readBytes)
-					return fmt.Errorf("message content is shorter than read bytes: %d < %d", len(content), readBytes)
-				}
+		case event := <-permissionEvents:
+			notification := event.Payload
+			if notification.Denied && outputFormat == OutputText {
+				stopSpinner()
+				fmt.Fprintf(os.Stderr, "\nDenied the %s tool, allow it with --allowed-tools %s\n", notification.ToolName, notification.ToolName)
+			}
+			printer.permission(notification)
+
+		case event := <-retryEvents:
+			retry := event.Payload
+			if retry.SessionID != sess.ID || quiet {
+				continue
+			}
+			if spinner != nil {
+				spinner.SetLabel(fmt.Sprintf("Generating, %s", retry.Status()))
+			} else {
+				fmt.Fprintf(os.Stderr, "\n%s: %s\n", retry.Model, retry.Status())
+			}
 
-				part := content[readBytes:]
-				fmt.Print(part)
-				messageReadBytes[msg.ID] = len(content)
+		case event := <-budgetEvents:
+			budget := event.Payload
+			if budget.SessionID != sess.ID {
+				continue
+			}
+			switch {
+			case budget.Exceeded:
+				stopSpinner()
+				fmt.Fprintf(os.Stderr, "\nStopped, %s\n", budget.Status())
+			case quiet:
+			case spinner != nil:
+				spinner.SetLabel(fmt.Sprintf("Generating, %s", budget.Status()))
+			default:
+				fmt.Fprintf(os.Stderr, "\nWarning: %s\n", budget.Status())
 			}
 
 		case <-ctx.Done():
@@ -207,6 +283,97 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 }
 
+// nonInteractiveSession returns the session a non-interactive run sends the
+// prompt to, creating it if needed.
+func (app *App) nonInteractiveSession(ctx context.Context, prompt string, opts NonInteractiveOptions) (session.Session, error) {
+	var (
+		sess session.Session
+		err  error
+	)
+	switch {
+	case opts.SessionID != "":
+		sess, err = app.Sessions.Get(ctx, opts.SessionID)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("session %s not found: %w", opts.SessionID, err)
+		}
+	case opts.Continue:
+		sessions, err := app.Sessions.List(ctx)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if len(sessions) == 0 {
+			return session.Session{}, errors.New("no session to continue")
+		}
+		sess = slices.MaxFunc(sessions, func(a, b session.Session) int {
+			return cmp.Compare(a.UpdatedAt, b.UpdatedAt)
+		})
+	default:
+		title := opts.Title
+		if title == "" {
+			const maxPromptLengthForTitle = 100
+			titlePrefix := "Non-interactive: "
+			var titleSuffix string
+
+			if len(prompt) > maxPromptLengthForTitle {
+				titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
+			} else {
+				titleSuffix = prompt
+			}
+			title = titlePrefix + titleSuffix
+		}
+		sess, err = app.Sessions.Create(ctx, title)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+		}
+		slog.Info("Created session for non-interactive run", "session_id", sess.ID)
+		return sess, nil
+	}
+
+	if app.Sessions.IsAgentToolSession(sess.ID) {
+		return session.Session{}, fmt.Errorf("session %s belongs to an agent tool call", sess.ID)
+	}
+	slog.Info("Resuming session for non-interactive run", "session_id", sess.ID)
+	if opts.Title != "" && opts.Title != sess.Title {
+		sess.Title = opts.Title
+		if sess, err = app.Sessions.Save(ctx, sess); err != nil {
+			return session.Session{}, fmt.Errorf("failed to rename session: %w", err)
+		}
+	}
+	return sess, nil
+}
+
+// sessionPrinter returns the printer of a run in the session, skipping the
+// messages the earlier runs of a resumed session printed.
+func (app *App) sessionPrinter(ctx context.Context, w io.Writer, format OutputFormat, sessionID string) (*runPrinter, error) {
+	msgs, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	printer := newRunPrinter(w, format, sessionID)
+	printer.skip(msgs)
+	return printer, nil
+}
+
+// catchUp prints what the printer missed of the session once the run is
+// over.
+func (app *App) catchUp(ctx context.Context, printer *runPrinter, sessionID string) error {
+	msgs, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if err := printer.message(msg); err != nil {
+			return err
+		}
+	}
+	sess, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	printer.session(sess)
+	return nil
+}
+
 func (app *App) UpdateAgentModel(ctx context.Context) error {
 	return app.AgentCoordinator.UpdateModels(ctx)
 }
@@ -216,11 +383,16 @@ func (app *App) setupEvents() {
 	app.eventsCtx = ctx
 	setupSubscriber(ctx, app.serviceEventsWG, "sessions", app.Sessions.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "messages", app.Messages.Subscribe, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "retries", app.Messages.SubscribeRetries, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "budgets", app.Sessions.SubscribeBudgets, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "permissions", app.Permissions.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "permissions-notifications", app.Permissions.SubscribeNotifications, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "mcp", tools.SubscribeMCPEvents, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "lsp", SubscribeLSPEvents, app.events)
+	if len(app.config.Hooks.PermissionRequest) > 0 {
+		app.runPermissionHooks(ctx)
+	}
 	cleanupFunc := func() error {
 		cancel()
 		app.serviceEventsWG.Wait()
@@ -319,6 +491,12 @@ func (app *App) Subscribe(program *tea.Program) {
 	}
 }
 
+// Events returns the channel the events of the services are fanned in to, for
+// a frontend other than the TUI to consume them.
+func (app *App) Events() <-chan tea.Msg {
+	return app.events
+}
+
 // Shutdown performs a graceful shutdown of the application.
 func (app *App) Shutdown() {
 	if app.AgentCoordinator != nil {
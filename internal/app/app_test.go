@@ -0,0 +1,89 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonInteractiveSession(t *testing.T) {
+	ctx := t.Context()
+	app := testApp(t)
+
+	t.Run("no session to continue", func(t *testing.T) {
+		_, err := app.nonInteractiveSession(ctx, "Hello", NonInteractiveOptions{Continue: true})
+		require.Error(t, err)
+	})
+
+	created, err := app.nonInteractiveSession(ctx, "Hello", NonInteractiveOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "Non-interactive: Hello", created.Title)
+
+	// the timestamps have a one second resolution
+	time.Sleep(time.Second)
+	titled, err := app.nonInteractiveSession(ctx, "Hello", NonInteractiveOptions{Title: "Release notes"})
+	require.NoError(t, err)
+	require.Equal(t, "Release notes", titled.Title)
+
+	t.Run("continue", func(t *testing.T) {
+		sess, err := app.nonInteractiveSession(ctx, "Again", NonInteractiveOptions{Continue: true})
+		require.NoError(t, err)
+		require.Equal(t, titled.ID, sess.ID)
+	})
+
+	t.Run("session", func(t *testing.T) {
+		sess, err := app.nonInteractiveSession(ctx, "Again", NonInteractiveOptions{SessionID: created.ID, Title: "Renamed"})
+		require.NoError(t, err)
+		require.Equal(t, created.ID, sess.ID)
+		require.Equal(t, "Renamed", sess.Title)
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		_, err := app.nonInteractiveSession(ctx, "Again", NonInteractiveOptions{SessionID: "nope"})
+		require.Error(t, err)
+	})
+}
+
+func TestSessionPrinter(t *testing.T) {
+	ctx := t.Context()
+	app := testApp(t)
+
+	s, err := app.Sessions.Create(ctx, "Resumed")
+	require.NoError(t, err)
+	for _, params := range []message.CreateMessageParams{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "List the files"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{
+			message.ReasoningContent{Thinking: "Let me look"},
+			message.TextContent{Text: "Listing them"},
+			message.ToolCall{ID: "call", Name: "ls", Input: `{}`, Finished: true},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call", Name: "ls", Content: "main.go"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: "There is main.go"}}},
+	} {
+		_, err := app.Messages.Create(ctx, s.ID, params)
+		require.NoError(t, err)
+	}
+
+	var out bytes.Buffer
+	printer, err := app.sessionPrinter(ctx, &out, OutputStreamJSON, s.ID)
+	require.NoError(t, err)
+	_, err = app.Messages.Create(ctx, s.ID, message.CreateMessageParams{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Read it"}}})
+	require.NoError(t, err)
+	_, err = app.Messages.Create(ctx, s.ID, message.CreateMessageParams{Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: "It's empty"}}})
+	require.NoError(t, err)
+	require.NoError(t, app.catchUp(ctx, printer, s.ID))
+
+	// only the answer of the run is printed, with the usage
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event TextEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event.Type+" "+event.Delta)
+	}
+	require.Equal(t, []string{"text It's empty", "usage "}, events)
+}
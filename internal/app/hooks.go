@@ -0,0 +1,28 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/charmbracelet/crush/internal/hooks"
+)
+
+// runPermissionHooks runs the permission request hooks for each tool call
+// waiting for the permission of the user.
+func (app *App) runPermissionHooks(ctx context.Context) {
+	runner := hooks.NewRunner(app.config.WorkingDir(), app.config.Hooks)
+	app.serviceEventsWG.Go(func() {
+		for event := range app.Permissions.Subscribe(ctx) {
+			request := event.Payload
+			err := runner.PermissionRequest(ctx, request.SessionID, hooks.Permission{
+				ToolName:    request.ToolName,
+				Action:      request.Action,
+				Path:        request.Path,
+				Description: request.Description,
+			})
+			if err != nil {
+				slog.Error("Permission request hook failed", "tool", request.ToolName, "error", err)
+			}
+		}
+	})
+}
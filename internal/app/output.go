@@ -0,0 +1,289 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// OutputFormat is the format of the output of the non-interactive runs.
+type OutputFormat string
+
+const (
+	// OutputText prints the text of the assistant as it comes.
+	OutputText OutputFormat = "text"
+	// OutputJSON prints a single JSON object with the result of the run.
+	OutputJSON OutputFormat = "json"
+	// OutputStreamJSON prints newline-delimited JSON events as the run goes,
+	// ending with the result of the run.
+	OutputStreamJSON OutputFormat = "stream-json"
+)
+
+// ParseOutputFormat returns the output format with the given name.
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch format := OutputFormat(name); format {
+	case OutputText, OutputJSON, OutputStreamJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q, use text, json or stream-json", name)
+	}
+}
+
+// The types of the events printed with the stream-json output format.
+const (
+	EventText       = "text"
+	EventReasoning  = "reasoning"
+	EventToolCall   = "tool_call"
+	EventToolResult = "tool_result"
+	EventPermission = "permission"
+	EventUsage      = "usage"
+	EventResult     = "result"
+)
+
+// TextEvent is a delta of the text or the reasoning of an assistant message.
+type TextEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id"`
+	Delta     string `json:"delta"`
+}
+
+// ToolCallEvent is a tool call of the assistant, sent once its input is
+// complete.
+type ToolCallEvent struct {
+	Type       string          `json:"type"`
+	SessionID  string          `json:"session_id"`
+	MessageID  string          `json:"message_id"`
+	ToolCallID string          `json:"tool_call_id"`
+	Name       string          `json:"name"`
+	Input      json.RawMessage `json:"input"`
+}
+
+// ToolResultEvent is the result of a tool call.
+type ToolResultEvent struct {
+	Type       string `json:"type"`
+	SessionID  string `json:"session_id"`
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Content    string `json:"content"`
+	IsError    bool   `json:"is_error"`
+}
+
+// PermissionEvent is the decision taken on the permission asked by a tool
+// call.
+type PermissionEvent struct {
+	Type       string `json:"type"`
+	SessionID  string `json:"session_id"`
+	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	Granted    bool   `json:"granted"`
+}
+
+// UsageEvent is the usage of the session, sent when it changes.
+type UsageEvent struct {
+	Type             string  `json:"type"`
+	SessionID        string  `json:"session_id"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// ResultEvent is the outcome of the run, the only output of the json output
+// format.
+type ResultEvent struct {
+	Type             string  `json:"type"`
+	SessionID        string  `json:"session_id"`
+	Result           string  `json:"result"`
+	IsError          bool    `json:"is_error"`
+	Error            string  `json:"error,omitempty"`
+	StopReason       string  `json:"stop_reason,omitempty"`
+	DurationMS       int64   `json:"duration_ms"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// runPrinter prints the progress of a non-interactive run from the events of
+// its session. The messages are whole on every event, so it keeps track of
+// what was already printed.
+type runPrinter struct {
+	w         io.Writer
+	format    OutputFormat
+	sessionID string
+	started   time.Time
+
+	textBytes      map[string]int
+	reasoningBytes map[string]int
+	toolCalls      map[string]bool
+	toolResults    map[string]bool
+	usage          UsageEvent
+	result         string
+	stopReason     message.FinishReason
+}
+
+func newRunPrinter(w io.Writer, format OutputFormat, sessionID string) *runPrinter {
+	return &runPrinter{
+		w:              w,
+		format:         format,
+		sessionID:      sessionID,
+		started:        time.Now(),
+		textBytes:      make(map[string]int),
+		reasoningBytes: make(map[string]int),
+		toolCalls:      make(map[string]bool),
+		toolResults:    make(map[string]bool),
+	}
+}
+
+// skip marks the messages as printed, for the printer to only print what the
+// run adds to them.
+func (p *runPrinter) skip(msgs []message.Message) {
+	for _, msg := range msgs {
+		if msg.SessionID != p.sessionID {
+			continue
+		}
+		switch msg.Role {
+		case message.Assistant:
+			p.textBytes[msg.ID] = len(msg.Content().Text)
+			p.reasoningBytes[msg.ID] = len(msg.ReasoningContent().Thinking)
+			for _, call := range msg.ToolCalls() {
+				if call.Finished {
+					p.toolCalls[call.ID] = true
+				}
+			}
+		case message.Tool:
+			for _, result := range msg.ToolResults() {
+				p.toolResults[result.ToolCallID] = true
+			}
+		}
+	}
+}
+
+func (p *runPrinter) message(msg message.Message) error {
+	if msg.SessionID != p.sessionID {
+		return nil
+	}
+	switch msg.Role {
+	case message.Assistant:
+		content := msg.Content().Text
+		readBytes := p.textBytes[msg.ID]
+		if len(content) < readBytes {
+			return fmt.Errorf("message content is shorter than read bytes: %d < %d", len(content), readBytes)
+		}
+		p.textBytes[msg.ID] = len(content)
+		p.result = content
+		if finish := msg.FinishPart(); finish != nil {
+			p.stopReason = finish.Reason
+		}
+		if delta := content[readBytes:]; delta != "" {
+			switch p.format {
+			case OutputText:
+				fmt.Fprint(p.w, delta)
+			case OutputStreamJSON:
+				p.emit(TextEvent{Type: EventText, SessionID: p.sessionID, MessageID: msg.ID, Delta: delta})
+			}
+		}
+		if p.format != OutputStreamJSON {
+			return nil
+		}
+
+		thinking := msg.ReasoningContent().Thinking
+		if readBytes := p.reasoningBytes[msg.ID]; len(thinking) > readBytes {
+			p.emit(TextEvent{Type: EventReasoning, SessionID: p.sessionID, MessageID: msg.ID, Delta: thinking[readBytes:]})
+			p.reasoningBytes[msg.ID] = len(thinking)
+		}
+		for _, call := range msg.ToolCalls() {
+			if !call.Finished || p.toolCalls[call.ID] {
+				continue
+			}
+			p.toolCalls[call.ID] = true
+			input := json.RawMessage(call.Input)
+			if !json.Valid(input) {
+				input, _ = json.Marshal(call.Input)
+			}
+			p.emit(ToolCallEvent{Type: EventToolCall, SessionID: p.sessionID, MessageID: msg.ID, ToolCallID: call.ID, Name: call.Name, Input: input})
+		}
+	case message.Tool:
+		if p.format != OutputStreamJSON {
+			return nil
+		}
+		for _, result := range msg.ToolResults() {
+			if p.toolResults[result.ToolCallID] {
+				continue
+			}
+			p.toolResults[result.ToolCallID] = true
+			p.emit(ToolResultEvent{Type: EventToolResult, SessionID: p.sessionID, ToolCallID: result.ToolCallID, Name: result.Name, Content: result.Content, IsError: result.IsError})
+		}
+	}
+	return nil
+}
+
+func (p *runPrinter) permission(notification permission.PermissionNotification) {
+	if p.format != OutputStreamJSON || notification.SessionID != p.sessionID {
+		return
+	}
+	// the first notification only says the permission was asked
+	if !notification.Granted && !notification.Denied {
+		return
+	}
+	p.emit(PermissionEvent{Type: EventPermission, SessionID: p.sessionID, ToolCallID: notification.ToolCallID, ToolName: notification.ToolName, Granted: notification.Granted})
+}
+
+func (p *runPrinter) session(s session.Session) {
+	if s.ID != p.sessionID {
+		return
+	}
+	usage := UsageEvent{
+		Type:             EventUsage,
+		SessionID:        s.ID,
+		PromptTokens:     s.PromptTokens,
+		CompletionTokens: s.CompletionTokens,
+		TotalTokens:      s.TotalTokens,
+		Cost:             s.Cost,
+	}
+	if usage == p.usage {
+		return
+	}
+	p.usage = usage
+	if p.format == OutputStreamJSON {
+		p.emit(usage)
+	}
+}
+
+// finish prints the result of the run, runErr being the error it failed
+// with, if any.
+func (p *runPrinter) finish(runErr error) {
+	if p.format == OutputText {
+		return
+	}
+	result := ResultEvent{
+		Type:             EventResult,
+		SessionID:        p.sessionID,
+		Result:           p.result,
+		StopReason:       string(p.stopReason),
+		DurationMS:       time.Since(p.started).Milliseconds(),
+		PromptTokens:     p.usage.PromptTokens,
+		CompletionTokens: p.usage.CompletionTokens,
+		TotalTokens:      p.usage.TotalTokens,
+		Cost:             p.usage.Cost,
+	}
+	if runErr != nil {
+		result.IsError = true
+		result.Error = runErr.Error()
+	}
+	p.emit(result)
+}
+
+func (p *runPrinter) emit(event any) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(p.w, "%s\n", data)
+}
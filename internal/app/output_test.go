@@ -0,0 +1,89 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	format, err := ParseOutputFormat("stream-json")
+	require.NoError(t, err)
+	require.Equal(t, OutputStreamJSON, format)
+
+	_, err = ParseOutputFormat("yaml")
+	require.Error(t, err)
+}
+
+func TestRunPrinter(t *testing.T) {
+	assistant := func(text string, calls ...message.ToolCall) message.Message {
+		parts := []message.ContentPart{
+			message.ReasoningContent{Thinking: "Let me look"},
+			message.TextContent{Text: text},
+		}
+		for _, call := range calls {
+			parts = append(parts, call)
+		}
+		return message.Message{ID: "assistant", SessionID: "session", Role: message.Assistant, Parts: parts}
+	}
+	feed := func(p *runPrinter) {
+		require.NoError(t, p.message(assistant("Hel")))
+		require.NoError(t, p.message(assistant("Hello", message.ToolCall{ID: "call", Name: "bash", Input: `{"command":`})))
+		require.NoError(t, p.message(assistant("Hello", message.ToolCall{ID: "call", Name: "bash", Input: `{"command":"ls"}`, Finished: true})))
+		// the same message again prints nothing new
+		require.NoError(t, p.message(assistant("Hello", message.ToolCall{ID: "call", Name: "bash", Input: `{"command":"ls"}`, Finished: true})))
+		p.permission(permission.PermissionNotification{SessionID: "session", ToolCallID: "call", ToolName: "bash"})
+		p.permission(permission.PermissionNotification{SessionID: "session", ToolCallID: "call", ToolName: "bash", Granted: true})
+		require.NoError(t, p.message(message.Message{ID: "tool", SessionID: "session", Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "call", Name: "bash", Content: "main.go"},
+		}}))
+		require.NoError(t, p.message(message.Message{ID: "other", SessionID: "other", Role: message.Assistant, Parts: []message.ContentPart{
+			message.TextContent{Text: "Not this session"},
+		}}))
+		p.session(session.Session{ID: "session", PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Cost: 0.01})
+		p.session(session.Session{ID: "session", PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Cost: 0.01})
+		p.finish(nil)
+	}
+
+	t.Run("text", func(t *testing.T) {
+		var out bytes.Buffer
+		feed(newRunPrinter(&out, OutputText, "session"))
+		require.Equal(t, "Hello", out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		feed(newRunPrinter(&out, OutputJSON, "session"))
+		var result ResultEvent
+		require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+		require.Equal(t, "Hello", result.Result)
+		require.False(t, result.IsError)
+		require.Equal(t, int64(15), result.TotalTokens)
+		require.Equal(t, 0.01, result.Cost)
+	})
+
+	t.Run("stream-json", func(t *testing.T) {
+		var out bytes.Buffer
+		feed(newRunPrinter(&out, OutputStreamJSON, "session"))
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		var types []string
+		for _, line := range lines {
+			var event struct {
+				Type string `json:"type"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(line), &event))
+			types = append(types, event.Type)
+		}
+		require.Equal(t, []string{
+			EventText, EventReasoning, EventText, EventToolCall, EventPermission, EventToolResult, EventUsage, EventResult,
+		}, types)
+		require.JSONEq(t, `{"type":"tool_call","session_id":"session","message_id":"assistant","tool_call_id":"call","name":"bash","input":{"command":"ls"}}`, lines[3])
+		require.JSONEq(t, `{"type":"permission","session_id":"session","tool_call_id":"call","tool_name":"bash","granted":true}`, lines[4])
+	})
+}
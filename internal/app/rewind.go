@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// Rewind rewinds the session to the checkpoint of the given user message: the
+// files changed since are restored and the message is deleted along with the
+// ones after it. With dryRun nothing changes and only the changes to the
+// files are returned.
+func (app *App) Rewind(ctx context.Context, sessionID, messageID string, dryRun bool) ([]history.FileChange, error) {
+	if app.AgentCoordinator != nil && app.AgentCoordinator.IsSessionBusy(sessionID) {
+		return nil, agent.ErrSessionBusy
+	}
+	msgs, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	idx := slices.IndexFunc(msgs, func(msg message.Message) bool {
+		return msg.ID == messageID
+	})
+	if idx < 0 {
+		return nil, fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	}
+	if msgs[idx].Role != message.User {
+		return nil, errors.New("can only rewind to a user message")
+	}
+	if dryRun {
+		return app.History.RewindChanges(ctx, sessionID, messageID)
+	}
+
+	changes, err := app.History.Rewind(ctx, sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.deleteMessages(ctx, sessionID, msgs[idx:]); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// EditPrompt makes room in the session to send an edited version of the given
+// user message: the message and the ones after it are deleted, or kept when
+// fork is set, in which case the session is forked right before the message
+// and the fork is returned. With restoreFiles, the files changed since the
+// message was sent are restored first.
+func (app *App) EditPrompt(ctx context.Context, sessionID, messageID string, fork, restoreFiles bool) (session.Session, []history.FileChange, error) {
+	if app.AgentCoordinator != nil && app.AgentCoordinator.IsSessionBusy(sessionID) {
+		return session.Session{}, nil, agent.ErrSessionBusy
+	}
+	msgs, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, nil, err
+	}
+	idx := slices.IndexFunc(msgs, func(msg message.Message) bool {
+		return msg.ID == messageID
+	})
+	if idx < 0 {
+		return session.Session{}, nil, fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	}
+	if msgs[idx].Role != message.User {
+		return session.Session{}, nil, errors.New("can only edit a user message")
+	}
+
+	var changes []history.FileChange
+	if restoreFiles {
+		changes, err = app.History.Rewind(ctx, sessionID, messageID)
+		if err != nil {
+			return session.Session{}, nil, err
+		}
+	}
+
+	if !fork {
+		if err := app.deleteMessages(ctx, sessionID, msgs[idx:]); err != nil {
+			return session.Session{}, nil, err
+		}
+		current, err := app.Sessions.Get(ctx, sessionID)
+		return current, changes, err
+	}
+
+	// the fork includes the edited message, drop its copy
+	forked, err := app.Sessions.Fork(ctx, sessionID, messageID)
+	if err != nil {
+		return session.Session{}, nil, err
+	}
+	forkMsgs, err := app.Messages.List(ctx, forked.ID)
+	if err != nil {
+		return session.Session{}, nil, err
+	}
+	if err := app.deleteMessages(ctx, forked.ID, forkMsgs[len(forkMsgs)-1:]); err != nil {
+		return session.Session{}, nil, err
+	}
+	return forked, changes, nil
+}
+
+// deleteMessages deletes the given messages of the session, along with the
+// summary of the session if it's one of them.
+func (app *App) deleteMessages(ctx context.Context, sessionID string, msgs []message.Message) error {
+	current, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if err := app.Messages.Delete(ctx, msg.ID); err != nil {
+			return fmt.Errorf("failed to delete message: %w", err)
+		}
+		// the summary goes away with the messages, so the ones it replaced
+		// are used again
+		if msg.ID == current.SummaryMessageID {
+			current.SummaryMessageID = ""
+			current.SummaryKeptMessageID = ""
+			if _, err := app.Sessions.Save(ctx, current); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditPrompt(t *testing.T) {
+	ctx := t.Context()
+	app := testApp(t)
+
+	newSession := func() (session.Session, []message.Message) {
+		t.Helper()
+		s, err := app.Sessions.Create(ctx, "Session")
+		require.NoError(t, err)
+		var msgs []message.Message
+		for _, role := range []message.MessageRole{message.User, message.Assistant, message.User, message.Assistant} {
+			msg, err := app.Messages.Create(ctx, s.ID, message.CreateMessageParams{
+				Role:  role,
+				Parts: []message.ContentPart{message.TextContent{Text: string(role)}},
+			})
+			require.NoError(t, err)
+			msgs = append(msgs, msg)
+		}
+		return s, msgs
+	}
+
+	t.Run("replace", func(t *testing.T) {
+		s, msgs := newSession()
+		edited, _, err := app.EditPrompt(ctx, s.ID, msgs[2].ID, false, false)
+		require.NoError(t, err)
+		require.Equal(t, s.ID, edited.ID)
+
+		left, err := app.Messages.List(ctx, s.ID)
+		require.NoError(t, err)
+		require.Len(t, left, 2)
+		require.Equal(t, msgs[1].ID, left[1].ID)
+	})
+
+	t.Run("fork", func(t *testing.T) {
+		s, msgs := newSession()
+		fork, _, err := app.EditPrompt(ctx, s.ID, msgs[2].ID, true, false)
+		require.NoError(t, err)
+		require.NotEqual(t, s.ID, fork.ID)
+		require.Equal(t, s.ID, fork.ParentSessionID)
+
+		kept, err := app.Messages.List(ctx, s.ID)
+		require.NoError(t, err)
+		require.Len(t, kept, 4)
+		copied, err := app.Messages.List(ctx, fork.ID)
+		require.NoError(t, err)
+		require.Len(t, copied, 2)
+		require.Equal(t, message.Assistant, copied[1].Role)
+	})
+
+	t.Run("assistant message", func(t *testing.T) {
+		s, msgs := newSession()
+		_, _, err := app.EditPrompt(ctx, s.ID, msgs[1].ID, false, false)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,350 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// TranscriptVersion is the version of the format of the exported sessions,
+// increased on incompatible changes.
+const TranscriptVersion = 1
+
+// Transcript is an exported session, with its messages.
+type Transcript struct {
+	Version  int                 `json:"version"`
+	Session  TranscriptSession   `json:"session"`
+	Messages []TranscriptMessage `json:"messages"`
+}
+
+// TranscriptSession is the exported session of a transcript.
+type TranscriptSession struct {
+	ID                   string  `json:"id"`
+	Title                string  `json:"title"`
+	PromptTokens         int64   `json:"prompt_tokens"`
+	CompletionTokens     int64   `json:"completion_tokens"`
+	TotalTokens          int64   `json:"total_tokens"`
+	Cost                 float64 `json:"cost"`
+	SummaryMessageID     string  `json:"summary_message_id,omitempty"`
+	SummaryKeptMessageID string  `json:"summary_kept_message_id,omitempty"`
+	CreatedAt            int64   `json:"created_at"`
+	UpdatedAt            int64   `json:"updated_at"`
+}
+
+// TranscriptMessage is an exported message of a transcript, its parts are
+// kept the way they are stored.
+type TranscriptMessage struct {
+	ID               string              `json:"id"`
+	Role             message.MessageRole `json:"role"`
+	Parts            json.RawMessage     `json:"parts"`
+	Model            string              `json:"model,omitempty"`
+	Provider         string              `json:"provider,omitempty"`
+	IsSummaryMessage bool                `json:"is_summary_message,omitempty"`
+	CreatedAt        int64               `json:"created_at"`
+	UpdatedAt        int64               `json:"updated_at"`
+}
+
+// ExportSession returns the transcript of the session.
+func (app *App) ExportSession(ctx context.Context, sessionID string) (Transcript, error) {
+	sess, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("session %s not found: %w", sessionID, err)
+	}
+	msgs, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return Transcript{}, err
+	}
+	t := Transcript{
+		Version: TranscriptVersion,
+		Session: TranscriptSession{
+			ID:                   sess.ID,
+			Title:                sess.Title,
+			PromptTokens:         sess.PromptTokens,
+			CompletionTokens:     sess.CompletionTokens,
+			TotalTokens:          sess.TotalTokens,
+			Cost:                 sess.Cost,
+			SummaryMessageID:     sess.SummaryMessageID,
+			SummaryKeptMessageID: sess.SummaryKeptMessageID,
+			CreatedAt:            sess.CreatedAt,
+			UpdatedAt:            sess.UpdatedAt,
+		},
+		Messages: make([]TranscriptMessage, len(msgs)),
+	}
+	for i, msg := range msgs {
+		parts, err := message.MarshalParts(msg.Parts)
+		if err != nil {
+			return Transcript{}, err
+		}
+		t.Messages[i] = TranscriptMessage{
+			ID:               msg.ID,
+			Role:             msg.Role,
+			Parts:            parts,
+			Model:            msg.Model,
+			Provider:         msg.Provider,
+			IsSummaryMessage: msg.IsSummaryMessage,
+			CreatedAt:        msg.CreatedAt,
+			UpdatedAt:        msg.UpdatedAt,
+		}
+	}
+	return t, nil
+}
+
+// ImportSession creates a new session with the messages of the transcript,
+// and returns it. The session and its messages get new IDs, so a transcript
+// can be imported more than once.
+func (app *App) ImportSession(ctx context.Context, t Transcript) (session.Session, error) {
+	switch {
+	case t.Version == 0:
+		return session.Session{}, errors.New("not a session transcript, the version is missing")
+	case t.Version > TranscriptVersion:
+		return session.Session{}, fmt.Errorf("unsupported transcript version %d, this version of Crush reads up to %d", t.Version, TranscriptVersion)
+	}
+	msgs, err := t.messages()
+	if err != nil {
+		return session.Session{}, err
+	}
+
+	sess, err := app.Sessions.Create(ctx, t.Session.Title)
+	if err != nil {
+		return session.Session{}, err
+	}
+	ids := make(map[string]string, len(msgs))
+	for _, msg := range msgs {
+		created, err := app.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+			Role:             msg.Role,
+			Parts:            msg.Parts,
+			Model:            msg.Model,
+			Provider:         msg.Provider,
+			IsSummaryMessage: msg.IsSummaryMessage,
+		})
+		if err != nil {
+			return session.Session{}, app.abortImport(ctx, sess.ID, err)
+		}
+		// keep the parts as they were, the finish part included
+		created.Parts = msg.Parts
+		if err := app.Messages.Update(ctx, created); err != nil {
+			return session.Session{}, app.abortImport(ctx, sess.ID, err)
+		}
+		ids[msg.ID] = created.ID
+	}
+
+	sess.PromptTokens = t.Session.PromptTokens
+	sess.CompletionTokens = t.Session.CompletionTokens
+	sess.TotalTokens = t.Session.TotalTokens
+	sess.Cost = t.Session.Cost
+	sess.SummaryMessageID = ids[t.Session.SummaryMessageID]
+	if sess.SummaryMessageID != "" {
+		sess.SummaryKeptMessageID = ids[t.Session.SummaryKeptMessageID]
+	}
+	saved, err := app.Sessions.Save(ctx, sess)
+	if err != nil {
+		return session.Session{}, app.abortImport(ctx, sess.ID, err)
+	}
+	return saved, nil
+}
+
+// abortImport deletes the session being imported after err.
+func (app *App) abortImport(ctx context.Context, sessionID string, err error) error {
+	err = fmt.Errorf("failed to import session: %w", err)
+	if deleteErr := app.Sessions.Delete(ctx, sessionID); deleteErr != nil {
+		return errors.Join(err, deleteErr)
+	}
+	return err
+}
+
+// messages returns the messages of the transcript, with their parts decoded.
+func (t Transcript) messages() ([]message.Message, error) {
+	msgs := make([]message.Message, len(t.Messages))
+	for i, msg := range t.Messages {
+		parts, err := message.UnmarshalParts(msg.Parts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parts of message %s: %w", msg.ID, err)
+		}
+		msgs[i] = message.Message{
+			ID:               msg.ID,
+			Role:             msg.Role,
+			SessionID:        t.Session.ID,
+			Parts:            parts,
+			Model:            msg.Model,
+			Provider:         msg.Provider,
+			CreatedAt:        msg.CreatedAt,
+			UpdatedAt:        msg.UpdatedAt,
+			IsSummaryMessage: msg.IsSummaryMessage,
+		}
+	}
+	return msgs, nil
+}
+
+// transcriptEntry is a message of a transcript as it is rendered, with the
+// results of its tool calls.
+type transcriptEntry struct {
+	Title     string
+	Reasoning string
+	Text      string
+	Files     []string
+	ToolCalls []*transcriptToolCall
+}
+
+type transcriptToolCall struct {
+	Name    string
+	Input   string
+	Result  string
+	IsError bool
+}
+
+// entries returns the messages of the transcript to render, the results of
+// the tool calls being attached to the calls.
+func (t Transcript) entries() ([]transcriptEntry, error) {
+	msgs, err := t.messages()
+	if err != nil {
+		return nil, err
+	}
+	var entries []transcriptEntry
+	calls := make(map[string]*transcriptToolCall)
+	for _, msg := range msgs {
+		if msg.Role == message.Tool {
+			for _, result := range msg.ToolResults() {
+				if call, ok := calls[result.ToolCallID]; ok {
+					call.Result = result.Content
+					call.IsError = result.IsError
+				}
+			}
+			continue
+		}
+
+		entry := transcriptEntry{
+			Title:     "User",
+			Reasoning: strings.TrimSpace(msg.ReasoningContent().Thinking),
+			Text:      strings.TrimSpace(msg.Content().Text),
+		}
+		switch {
+		case msg.IsSummaryMessage:
+			entry.Title = "Summary"
+		case msg.Role == message.Assistant:
+			entry.Title = "Assistant"
+			if msg.Model != "" {
+				entry.Title += " (" + msg.Model + ")"
+			}
+		}
+		for _, file := range msg.BinaryContent() {
+			entry.Files = append(entry.Files, file.Path)
+		}
+		for _, tc := range msg.ToolCalls() {
+			call := &transcriptToolCall{Name: tc.Name, Input: indentJSON(tc.Input)}
+			calls[tc.ID] = call
+			entry.ToolCalls = append(entry.ToolCalls, call)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func indentJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+// WriteMarkdown writes the transcript as Markdown.
+func (t Transcript) WriteMarkdown(w io.Writer) error {
+	entries, err := t.entries()
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", t.Session.Title)
+	fmt.Fprintf(&b, "- Session: `%s`\n", t.Session.ID)
+	fmt.Fprintf(&b, "- Created: %s\n", time.Unix(t.Session.CreatedAt, 0).Format(time.DateTime))
+	fmt.Fprintf(&b, "- Tokens: %d\n", t.Session.TotalTokens)
+	fmt.Fprintf(&b, "- Cost: $%.2f\n", t.Session.Cost)
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "\n## %s\n", entry.Title)
+		if entry.Reasoning != "" {
+			fmt.Fprintf(&b, "\n> %s\n", strings.ReplaceAll(entry.Reasoning, "\n", "\n> "))
+		}
+		if entry.Text != "" {
+			fmt.Fprintf(&b, "\n%s\n", entry.Text)
+		}
+		for _, file := range entry.Files {
+			fmt.Fprintf(&b, "\nAttached `%s`\n", file)
+		}
+		for _, call := range entry.ToolCalls {
+			fmt.Fprintf(&b, "\n**Tool: %s**\n\n%s\n", call.Name, codeBlock("json", call.Input))
+			if call.IsError {
+				b.WriteString("\nError:\n\n")
+			} else {
+				b.WriteString("\nResult:\n\n")
+			}
+			fmt.Fprintf(&b, "%s\n", codeBlock("", call.Result))
+		}
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// codeBlock returns a fenced code block of s, with a fence longer than the
+// backticks in s.
+func codeBlock(lang, s string) string {
+	fence := "```"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + fence
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Session.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; color: #222; }
+h2 { font-size: 1rem; margin-top: 2rem; color: #6b50ff; }
+.meta { color: #666; }
+.text { white-space: pre-wrap; }
+.reasoning { white-space: pre-wrap; color: #666; border-left: 3px solid #ddd; padding-left: 1rem; }
+pre { background: #f6f6f6; padding: 0.75rem; overflow-x: auto; }
+details { margin: 0.5rem 0; }
+summary { cursor: pointer; font-weight: bold; }
+.error { color: #c33; }
+</style>
+</head>
+<body>
+<h1>{{.Session.Title}}</h1>
+<p class="meta">Session <code>{{.Session.ID}}</code>, created {{.Created}}, {{.Session.TotalTokens}} tokens, ${{printf "%.2f" .Session.Cost}}</p>
+{{range .Entries}}<h2>{{.Title}}</h2>
+{{if .Reasoning}}<div class="reasoning">{{.Reasoning}}</div>
+{{end}}{{if .Text}}<div class="text">{{.Text}}</div>
+{{end}}{{range .Files}}<p>Attached <code>{{.}}</code></p>
+{{end}}{{range .ToolCalls}}<details>
+<summary{{if .IsError}} class="error"{{end}}>{{.Name}}</summary>
+<pre>{{.Input}}</pre>
+<pre{{if .IsError}} class="error"{{end}}>{{.Result}}</pre>
+</details>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes the transcript as a standalone HTML page.
+func (t Transcript) WriteHTML(w io.Writer) error {
+	entries, err := t.entries()
+	if err != nil {
+		return err
+	}
+	return transcriptTemplate.Execute(w, struct {
+		Transcript
+		Created string
+		Entries []transcriptEntry
+	}{t, time.Unix(t.Session.CreatedAt, 0).Format(time.DateTime), entries})
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript(t *testing.T) {
+	ctx := t.Context()
+	app := testApp(t)
+
+	s, err := app.Sessions.Create(ctx, "Rename the package")
+	require.NoError(t, err)
+	for _, params := range []message.CreateMessageParams{
+		{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: "Rename the package of main.go"}},
+		},
+		{
+			Role:     message.Assistant,
+			Model:    "gpt-5",
+			Provider: "openai",
+			Parts: []message.ContentPart{
+				message.ReasoningContent{Thinking: "Let me look at it"},
+				message.TextContent{Text: "Renaming it"},
+				message.ToolCall{ID: "call", Name: "write", Input: `{"file_path":"main.go","content":"<app>"}`, Finished: true},
+				message.Finish{Reason: message.FinishReasonToolUse, Time: 1700000000},
+			},
+		},
+		{
+			Role:  message.Tool,
+			Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call", Name: "write", Content: "File written"}},
+		},
+	} {
+		_, err := app.Messages.Create(ctx, s.ID, params)
+		require.NoError(t, err)
+	}
+	s.Cost = 0.25
+	s.TotalTokens = 1200
+	s, err = app.Sessions.Save(ctx, s)
+	require.NoError(t, err)
+
+	exported, err := app.ExportSession(ctx, s.ID)
+	require.NoError(t, err)
+	require.Equal(t, TranscriptVersion, exported.Version)
+	require.Len(t, exported.Messages, 3)
+
+	t.Run("round trip", func(t *testing.T) {
+		data, err := json.Marshal(exported)
+		require.NoError(t, err)
+		var transcript Transcript
+		require.NoError(t, json.Unmarshal(data, &transcript))
+
+		imported, err := app.ImportSession(ctx, transcript)
+		require.NoError(t, err)
+		require.NotEqual(t, s.ID, imported.ID)
+		require.Equal(t, "Rename the package", imported.Title)
+		require.Equal(t, 0.25, imported.Cost)
+		require.Equal(t, int64(1200), imported.TotalTokens)
+
+		original, err := app.Messages.List(ctx, s.ID)
+		require.NoError(t, err)
+		copied, err := app.Messages.List(ctx, imported.ID)
+		require.NoError(t, err)
+		require.Len(t, copied, len(original))
+		for i := range original {
+			require.NotEqual(t, original[i].ID, copied[i].ID)
+			require.Equal(t, original[i].Role, copied[i].Role)
+			require.Equal(t, original[i].Parts, copied[i].Parts)
+			require.Equal(t, original[i].Model, copied[i].Model)
+			require.Equal(t, original[i].Provider, copied[i].Provider)
+		}
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := app.ImportSession(ctx, Transcript{Version: TranscriptVersion + 1})
+		require.ErrorContains(t, err, "unsupported transcript version")
+		_, err = app.ImportSession(ctx, Transcript{})
+		require.ErrorContains(t, err, "not a session transcript")
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var b strings.Builder
+		require.NoError(t, exported.WriteMarkdown(&b))
+		md := b.String()
+		require.Contains(t, md, "# Rename the package\n")
+		require.Contains(t, md, "## User\n\nRename the package of main.go\n")
+		require.Contains(t, md, "## Assistant (gpt-5)\n\n> Let me look at it\n\nRenaming it\n")
+		require.Contains(t, md, "**Tool: write**\n\n```json\n{\n  \"file_path\": \"main.go\",\n  \"content\": \"<app>\"\n}\n```\n\nResult:\n\n```\nFile written\n```\n")
+	})
+
+	t.Run("html", func(t *testing.T) {
+		var b strings.Builder
+		require.NoError(t, exported.WriteHTML(&b))
+		page := b.String()
+		require.Contains(t, page, "<title>Rename the package</title>")
+		require.Contains(t, page, "<summary>write</summary>")
+		require.Contains(t, page, "&lt;app&gt;")
+		require.Contains(t, page, "<pre>File written</pre>")
+	})
+}
+
+func TestCodeBlock(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "```go\npackage main\n```", codeBlock("go", "package main\n"))
+	require.Equal(t, "````\n```\ncode\n```\n````", codeBlock("", "```\ncode\n```"))
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/charmbracelet/crush/internal/acp"
+	"github.com/spf13/cobra"
+)
+
+var acpCmd = &cobra.Command{
+	Use:   "acp",
+	Short: "Speak the Agent Client Protocol on stdio for editors",
+	Long: `Run Crush as an agent of the Agent Client Protocol, speaking JSON-RPC on
+the standard input and output, for the editors supporting it such as Zed.
+The editor shows the messages, asks for the permissions and serves the files
+of the project, including the unsaved ones.`,
+	Example: `
+# Zed settings
+{
+  "agent_servers": {
+    "Crush": {
+      "command": "crush",
+      "args": ["acp"]
+    }
+  }
+}
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		err = acp.New(app).Serve(cmd.Context(), stdio{Reader: cmd.InOrStdin(), Writer: cmd.OutOrStdout()})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	acpCmd.Flags().BoolP("yolo", "y", false, "Automatically accept all permissions (dangerous mode)")
+}
+
+// stdio joins the standard input and output into the connection with the
+// client.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+func (s stdio) Close() error {
+	var errs []error
+	for _, stream := range []any{s.Reader, s.Writer} {
+		if closer, ok := stream.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
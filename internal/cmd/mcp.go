@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/mcpserver"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Work with MCP",
+}
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the built-in tools over MCP",
+	Long: `Serve the built-in tools of Crush, such as edit, view and grep, over MCP for
+other agents to use them, on the standard input and output or over
+streamable HTTP with --http.
+Over HTTP the clients authenticate with a token, given with --token or the
+CRUSH_MCP_TOKEN environment variable, or generated and printed on start.
+The tools allowed in the permissions configuration run right away, the others
+are denied, or asked to the user of the client with --permissions ask. The
+changes of the files are recorded in a session of their own.`,
+	Example: `
+# Serve on stdio
+crush mcp serve
+
+# Serve some of the tools over HTTP on http://localhost:8788/mcp
+CRUSH_MCP_TOKEN=secret crush mcp serve --http --tools view,grep,glob,edit
+
+# Ask the user of the client for the permissions
+crush mcp serve --permissions ask
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useHTTP, _ := cmd.Flags().GetBool("http")
+		host, _ := cmd.Flags().GetString("host")
+		port, _ := cmd.Flags().GetInt("port")
+		names, _ := cmd.Flags().GetStringSlice("tools")
+		policy, _ := cmd.Flags().GetString("permissions")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("CRUSH_MCP_TOKEN")
+		}
+		if policy != string(mcpserver.PolicyDeny) && policy != string(mcpserver.PolicyAsk) {
+			return fmt.Errorf("invalid permissions policy %q, expected deny or ask", policy)
+		}
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		cfg := app.Config()
+		tools := agent.BuiltinTools(cfg, cfg.Agents[config.AgentCoder], app.Permissions, app.History, app.LSPClients)
+		if len(names) > 0 {
+			for _, name := range names {
+				if !slices.ContainsFunc(tools, func(tool fantasy.AgentTool) bool { return tool.Info().Name == name }) {
+					return fmt.Errorf("unknown or disabled tool: %s", name)
+				}
+			}
+			tools = slices.DeleteFunc(tools, func(tool fantasy.AgentTool) bool {
+				return !slices.Contains(names, tool.Info().Name)
+			})
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		sess, err := app.Sessions.Create(ctx, "MCP Server")
+		if err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		srv := mcpserver.New(tools, app.Permissions, sess.ID, mcpserver.Policy(policy))
+
+		if !useHTTP {
+			err := srv.Run(ctx, &mcp.StdioTransport{})
+			if err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return nil
+		}
+
+		if token == "" {
+			token, err = generateToken()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Token: %s\n", token)
+		}
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/mcp", srv.Handler(ctx, token, host))
+		httpServer := &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				slog.Error("Failed to shut down the MCP server", "error", err)
+			}
+		}()
+
+		slog.Info("Serving the tools over MCP", "address", listener.Addr().String())
+		fmt.Fprintf(cmd.ErrOrStderr(), "Listening on http://%s/mcp\n", listener.Addr())
+		if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	mcpServeCmd.Flags().Bool("http", false, "Serve over streamable HTTP instead of stdio")
+	mcpServeCmd.Flags().String("host", "127.0.0.1", "Host to listen on with --http, other hosts than localhost expose the tools to the network")
+	mcpServeCmd.Flags().IntP("port", "p", 8788, "Port to listen on with --http")
+	mcpServeCmd.Flags().String("token", "", "Token the clients authenticate with over HTTP, generated if empty")
+	mcpServeCmd.Flags().StringSlice("tools", nil, "Tools to serve, all the built-in ones if empty")
+	mcpServeCmd.Flags().String("permissions", string(mcpserver.PolicyDeny), "What to do with the tools asking for permission: deny, or ask the user of the client")
+	mcpServeCmd.Flags().BoolP("yolo", "y", false, "Automatically accept all permissions (dangerous mode)")
+	mcpCmd.AddCommand(mcpServeCmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Inspect the system prompts",
+}
+
+var promptRenderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the system prompt of an agent",
+	Long: `Print the system prompt of an agent as it is sent to the model, with the
+templates from .crush/prompts and the global prompts directory applied.`,
+	Example: `
+# Print the system prompt of the coder agent
+crush prompt render
+
+# Print the system prompt of the task agent
+crush prompt render --agent task
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		agentID, _ := cmd.Flags().GetString("agent")
+		debug, _ := cmd.Flags().GetBool("debug")
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Init(cwd, dataDir, debug)
+		if err != nil {
+			return err
+		}
+		systemPrompt, err := agent.SystemPrompt(cmd.Context(), cfg, agentID)
+		if err != nil {
+			return err
+		}
+		fmt.Println(systemPrompt)
+		return nil
+	},
+}
+
+func init() {
+	promptRenderCmd.Flags().StringP("agent", "a", config.AgentCoder, "Agent to render the system prompt of")
+	promptCmd.AddCommand(promptRenderCmd)
+}
@@ -42,6 +42,12 @@ func init() {
 		updateProvidersCmd,
 		logsCmd,
 		schemaCmd,
+		promptCmd,
+		sessionsCmd,
+		usageCmd,
+		serveCmd,
+		acpCmd,
+		mcpCmd,
 	)
 }
 
@@ -173,6 +179,20 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 	}
 	cfg.Permissions.SkipRequests = yolo
 
+	// the flags of crush run, they only apply to this invocation
+	model, _ := cmd.Flags().GetString("model")
+	smallModel, _ := cmd.Flags().GetString("small-model")
+	allowedTools, _ := cmd.Flags().GetStringSlice("allowed-tools")
+	disallowedTools, _ := cmd.Flags().GetStringSlice("disallowed-tools")
+	if err := cfg.ApplyOverrides(config.Overrides{
+		LargeModel:      model,
+		SmallModel:      smallModel,
+		AllowedTools:    allowedTools,
+		DisallowedTools: disallowedTools,
+	}); err != nil {
+		return nil, err
+	}
+
 	if err := createDotCrushDir(cfg.Options.DataDirectory); err != nil {
 		return nil, err
 	}
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/charmbracelet/crush/internal/app"
 	"github.com/spf13/cobra"
 )
 
@@ -22,9 +23,42 @@ echo "What is this code doing?" | crush run
 
 # Run with quiet mode (no spinner)
 crush run -q "Generate a README for this project"
+
+# Stream what the agent does as newline-delimited JSON
+crush run --output-format stream-json "Fix the failing tests"
+
+# Keep going in the most recent session
+crush run --continue "Now add tests for it"
+
+# Send the prompt to a given session
+crush run --session 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90 "Commit the changes"
+
+# Review with a given model, read-only tools and limits
+crush run --model anthropic/claude-sonnet-4 --disallowed-tools bash,edit,write --max-turns 20 --max-cost 2.00 "Review this branch"
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		format, err := app.ParseOutputFormat(outputFormat)
+		if err != nil {
+			return err
+		}
+		sessionID, _ := cmd.Flags().GetString("session")
+		continueLast, _ := cmd.Flags().GetBool("continue")
+		title, _ := cmd.Flags().GetString("title")
+		printSessionID, _ := cmd.Flags().GetBool("print-session-id")
+		maxTurns, _ := cmd.Flags().GetInt("max-turns")
+		maxCost, _ := cmd.Flags().GetFloat64("max-cost")
+		opts := app.NonInteractiveOptions{
+			Quiet:          quiet,
+			OutputFormat:   format,
+			SessionID:      sessionID,
+			Continue:       continueLast,
+			Title:          title,
+			PrintSessionID: printSessionID,
+			MaxTurns:       maxTurns,
+			MaxCost:        maxCost,
+		}
 
 		app, err := setupApp(cmd)
 		if err != nil {
@@ -49,10 +83,23 @@ crush run -q "Generate a README for this project"
 		}
 
 		// Run non-interactive flow using the App method
-		return app.RunNonInteractive(cmd.Context(), prompt, quiet)
+		return app.RunNonInteractive(cmd.Context(), prompt, opts)
 	},
 }
 
 func init() {
 	runCmd.Flags().BoolP("quiet", "q", false, "Hide spinner")
+	runCmd.Flags().String("output-format", string(app.OutputText), "Output format: text, json or stream-json")
+	runCmd.Flags().StringP("session", "s", "", "Send the prompt to the session with the given ID")
+	runCmd.Flags().BoolP("continue", "C", false, "Send the prompt to the most recently used session")
+	runCmd.Flags().String("title", "", "Title of the session")
+	runCmd.Flags().Bool("print-session-id", false, "Print the ID of the session on stderr")
+	runCmd.Flags().StringP("model", "m", "", "Large model to use, as provider/model")
+	runCmd.Flags().String("small-model", "", "Small model to use, as provider/model")
+	runCmd.Flags().StringSlice("allowed-tools", nil, "Tools allowed to run without asking, the others asking for permission are denied")
+	runCmd.Flags().StringSlice("disallowed-tools", nil, "Tools removed from the agents")
+	runCmd.Flags().Int("max-turns", 0, "Stop after that many requests to the model")
+	runCmd.Flags().Float64("max-cost", 0, "Stop once the run cost that much, in USD")
+	runCmd.Flags().BoolP("yolo", "y", false, "Automatically accept all permissions (dangerous mode)")
+	runCmd.MarkFlagsMutuallyExclusive("session", "continue")
 }
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API to drive Crush without the TUI",
+	Long: `Run Crush without the TUI and serve a REST API to manage the sessions, send
+prompts and answer the permission requests, along with a stream of server-sent
+events of everything happening in the app.
+The clients authenticate with a token, given with --token or the
+CRUSH_SERVER_TOKEN environment variable, or generated and printed on start.`,
+	Example: `
+# Serve on localhost with a generated token
+crush serve
+
+# Serve on another port with a known token
+CRUSH_SERVER_TOKEN=secret crush serve --port 9000
+
+# Follow the events
+curl -N -H "Authorization: Bearer secret" http://localhost:9000/v1/events
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host, _ := cmd.Flags().GetString("host")
+		port, _ := cmd.Flags().GetInt("port")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("CRUSH_SERVER_TOKEN")
+		}
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		if token == "" {
+			token, err = generateToken()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Token: %s\n", token)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		srv := server.New(ctx, app, token)
+		go srv.Forward(ctx)
+
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			return err
+		}
+		httpServer := &http.Server{
+			Handler:           srv,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				slog.Error("Failed to shut down the server", "error", err)
+			}
+		}()
+
+		slog.Info("Serving the API", "address", listener.Addr().String())
+		fmt.Fprintf(cmd.ErrOrStderr(), "Listening on http://%s\n", listener.Addr())
+		if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("host", "127.0.0.1", "Host to listen on, other hosts than localhost expose the API to the network")
+	serveCmd.Flags().IntP("port", "p", 8787, "Port to listen on")
+	serveCmd.Flags().String("token", "", "Token the clients authenticate with, generated if empty")
+	serveCmd.Flags().BoolP("yolo", "y", false, "Automatically accept all permissions (dangerous mode)")
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate a token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,456 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage sessions",
+}
+
+var sessionsRewindCmd = &cobra.Command{
+	Use:   "rewind <session> [message]",
+	Short: "Rewind a session to one of its prompts",
+	Long: `Rewind a session to the checkpoint recorded when one of its prompts was sent.
+The files changed since are restored, and the prompt is deleted along with the
+messages after it. Without a message, list the checkpoints of the session.`,
+	Example: `
+# List the checkpoints of a session
+crush sessions rewind 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90
+
+# Show the files that would be restored
+crush sessions rewind 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90 8f14e45f-ceea-467f-a0e6-1b9b9c3f1e2d --dry-run
+
+# Rewind the session
+crush sessions rewind 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90 8f14e45f-ceea-467f-a0e6-1b9b9c3f1e2d
+  `,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		ctx := cmd.Context()
+		out := cmd.OutOrStdout()
+		sessionID := args[0]
+		if _, err := app.Sessions.Get(ctx, sessionID); err != nil {
+			return fmt.Errorf("session %s not found: %w", sessionID, err)
+		}
+
+		if len(args) == 1 {
+			checkpoints, err := app.History.ListCheckpoints(ctx, sessionID)
+			if err != nil {
+				return err
+			}
+			if len(checkpoints) == 0 {
+				fmt.Fprintln(out, "No checkpoints for this session")
+				return nil
+			}
+			for _, checkpoint := range checkpoints {
+				msg, err := app.Messages.Get(ctx, checkpoint.MessageID)
+				if err != nil {
+					return err
+				}
+				prompt, _, _ := strings.Cut(strings.TrimSpace(msg.Content().Text), "\n")
+				fmt.Fprintf(out, "%s  %s  %s\n",
+					checkpoint.MessageID,
+					time.Unix(checkpoint.CreatedAt, 0).Format(time.DateTime),
+					prompt,
+				)
+			}
+			return nil
+		}
+
+		changes, err := app.Rewind(ctx, sessionID, args[1], dryRun)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			status := "M"
+			if change.Deleted {
+				status = "D"
+			}
+			fmt.Fprintf(out, "%s %s\n", status, fsext.PrettyPath(change.Path))
+		}
+		if dryRun {
+			fmt.Fprintf(out, "%d file(s) would be restored\n", len(changes))
+		} else {
+			fmt.Fprintf(out, "Rewound the session, %d file(s) restored\n", len(changes))
+		}
+		return nil
+	},
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sessions",
+	Example: `
+# List the sessions, the latest first
+crush sessions list
+
+# List the sessions as JSON
+crush sessions list --json
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		sessions, err := app.Sessions.List(cmd.Context())
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if asJSON {
+			type sessionJSON struct {
+				ID           string  `json:"id"`
+				Title        string  `json:"title"`
+				MessageCount int64   `json:"message_count"`
+				TotalTokens  int64   `json:"total_tokens"`
+				Cost         float64 `json:"cost"`
+				CreatedAt    int64   `json:"created_at"`
+				UpdatedAt    int64   `json:"updated_at"`
+			}
+			list := make([]sessionJSON, len(sessions))
+			for i, s := range sessions {
+				list[i] = sessionJSON{
+					ID:           s.ID,
+					Title:        s.Title,
+					MessageCount: s.MessageCount,
+					TotalTokens:  s.TotalTokens,
+					Cost:         s.Cost,
+					CreatedAt:    s.CreatedAt,
+					UpdatedAt:    s.UpdatedAt,
+				}
+			}
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(list)
+		}
+
+		if len(sessions) == 0 {
+			fmt.Fprintln(out, "No sessions")
+			return nil
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUPDATED\tMESSAGES\tTOKENS\tCOST\tTITLE")
+		for _, s := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t$%.2f\t%s\n",
+				s.ID,
+				time.Unix(s.UpdatedAt, 0).Format(time.DateTime),
+				s.MessageCount,
+				s.TotalTokens,
+				s.Cost,
+				s.Title,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+var sessionsSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the sessions",
+	Long: `Search the titles of the sessions and their messages, tool calls included, for
+all the words of the query, the best matches first.`,
+	Example: `
+# Find the session where the migration bug was fixed
+crush sessions search migration bug
+
+# Search as JSON
+crush sessions search migration bug --json
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		results, err := app.Sessions.Search(cmd.Context(), strings.Join(args, " "), limit)
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if asJSON {
+			type resultJSON struct {
+				SessionID string `json:"session_id"`
+				MessageID string `json:"message_id,omitempty"`
+				Title     string `json:"title"`
+				Snippet   string `json:"snippet"`
+			}
+			list := make([]resultJSON, len(results))
+			for i, result := range results {
+				list[i] = resultJSON{
+					SessionID: result.SessionID,
+					MessageID: result.MessageID,
+					Title:     result.Title,
+					Snippet:   result.Snippet,
+				}
+			}
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(list)
+		}
+
+		if len(results) == 0 {
+			fmt.Fprintln(out, "No matches")
+			return nil
+		}
+		highlight := lipgloss.NewStyle().Bold(true)
+		for _, result := range results {
+			snippet := result.Snippet
+			if term.IsTerminal(os.Stdout.Fd()) {
+				var b strings.Builder
+				last := 0
+				for _, match := range result.Matches {
+					b.WriteString(snippet[last:match[0]])
+					b.WriteString(highlight.Render(snippet[match[0]:match[1]]))
+					last = match[1]
+				}
+				b.WriteString(snippet[last:])
+				snippet = b.String()
+			}
+			fmt.Fprintf(out, "%s  %s\n  %s\n", result.SessionID, result.Title, snippet)
+		}
+		return nil
+	},
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <session>",
+	Short: "Show the transcript of a session",
+	Long: `Show the transcript of a session, with the tool calls and their results.
+The transcript is rendered when the output is a terminal, and printed as
+Markdown otherwise.`,
+	Example: `
+# Show a session
+crush sessions show 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90
+
+# Page through a long session
+crush sessions show 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90 | less
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		transcript, err := app.ExportSession(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if !term.IsTerminal(os.Stdout.Fd()) {
+			return transcript.WriteMarkdown(out)
+		}
+		var md strings.Builder
+		if err := transcript.WriteMarkdown(&md); err != nil {
+			return err
+		}
+		width, _, err := term.GetSize(os.Stdout.Fd())
+		if err != nil || width <= 0 {
+			width = 80
+		}
+		rendered, err := styles.GetMarkdownRenderer(width).Render(md.String())
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(out, rendered)
+		return err
+	},
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:   "delete <session>...",
+	Short: "Delete sessions",
+	Long: `Delete sessions along with their messages and the history of their files.
+The files themselves are left as they are.`,
+	Example: `
+# Delete a session
+crush sessions delete 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		for _, id := range args {
+			if err := app.Sessions.Delete(cmd.Context(), id); err != nil {
+				return fmt.Errorf("failed to delete session %s: %w", id, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted %s\n", id)
+		}
+		return nil
+	},
+}
+
+var sessionsRenameCmd = &cobra.Command{
+	Use:   "rename <session> <title>",
+	Short: "Rename a session",
+	Example: `
+# Rename a session
+crush sessions rename 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90 "Fix the login form"
+  `,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		title := strings.TrimSpace(args[1])
+		if title == "" {
+			return fmt.Errorf("the title can't be empty")
+		}
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		ctx := cmd.Context()
+		sess, err := app.Sessions.Get(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("session %s not found: %w", args[0], err)
+		}
+		sess.Title = title
+		_, err = app.Sessions.Save(ctx, sess)
+		return err
+	},
+}
+
+var sessionsExportCmd = &cobra.Command{
+	Use:   "export <session>",
+	Short: "Export a session",
+	Long: `Export a session to the standard output, as JSON to import it elsewhere with
+crush sessions import, or as Markdown or HTML to read it.`,
+	Example: `
+# Export a session to move it to another machine
+crush sessions export 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90 > session.json
+
+# Export a session as a web page
+crush sessions export 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90 --format html > session.html
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if format != "json" && format != "md" && format != "html" {
+			return fmt.Errorf("unknown format %q, use json, md or html", format)
+		}
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		transcript, err := app.ExportSession(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		switch format {
+		case "md":
+			return transcript.WriteMarkdown(out)
+		case "html":
+			return transcript.WriteHTML(out)
+		default:
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(transcript)
+		}
+	},
+}
+
+var sessionsImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a session",
+	Long: `Import a session exported as JSON with crush sessions export, from a file or
+from the standard input with -. The session is imported as a new one.`,
+	Example: `
+# Import a session
+crush sessions import session.json
+
+# Copy a session from another machine
+ssh remote crush sessions export 2b0e5d9c-1f4a-4c59-9d6e-3f1a2b7c8d90 | crush sessions import -
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			data []byte
+			err  error
+		)
+		if args[0] == "-" {
+			data, err = io.ReadAll(cmd.InOrStdin())
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		var transcript app.Transcript
+		if err := json.Unmarshal(data, &transcript); err != nil {
+			return fmt.Errorf("invalid session transcript: %w", err)
+		}
+
+		a, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer a.Shutdown()
+
+		sess, err := a.ImportSession(cmd.Context(), transcript)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Imported %s\n", sess.ID)
+		return nil
+	},
+}
+
+func init() {
+	sessionsRewindCmd.Flags().Bool("dry-run", false, "List the files that would be restored without changing anything")
+	sessionsListCmd.Flags().Bool("json", false, "Print the sessions as JSON")
+	sessionsSearchCmd.Flags().Bool("json", false, "Print the matches as JSON")
+	sessionsSearchCmd.Flags().IntP("limit", "n", 20, "Maximum number of matches")
+	sessionsExportCmd.Flags().String("format", "json", "Format of the export: json, md or html")
+	sessionsCmd.AddCommand(
+		sessionsListCmd,
+		sessionsSearchCmd,
+		sessionsShowCmd,
+		sessionsDeleteCmd,
+		sessionsRenameCmd,
+		sessionsExportCmd,
+		sessionsImportCmd,
+		sessionsRewindCmd,
+	)
+}
@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// usageGroups are the columns the usage can be grouped by.
+var usageGroups = []string{"day", "provider", "model", "session"}
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report the tokens and cost used",
+	Long: `Report the tokens, cost and latency of the requests made to the models,
+grouped by day, provider, model or session. The usage of the sub-agents and of
+the titles counts for the session they were run from, and the usage of deleted
+sessions is kept.`,
+	Example: `
+# Usage per day
+crush usage
+
+# Usage per provider and model for October, as CSV
+crush usage --by provider,model --since 2025-10-01 --until 2025-10-31 --format csv
+
+# Usage per session as JSON
+crush usage --by session --format json
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		by, _ := cmd.Flags().GetStringSlice("by")
+		format, _ := cmd.Flags().GetString("format")
+		for _, group := range by {
+			if !slices.Contains(usageGroups, group) {
+				return fmt.Errorf("unknown group %q, use %s", group, strings.Join(usageGroups, ", "))
+			}
+		}
+		if format != "table" && format != "csv" && format != "json" {
+			return fmt.Errorf("unknown format %q, use table, csv or json", format)
+		}
+		since, err := usageDateFlag(cmd, "since")
+		if err != nil {
+			return err
+		}
+		until, err := usageDateFlag(cmd, "until")
+		if err != nil {
+			return err
+		}
+		if !until.IsZero() {
+			// the whole day is included
+			until = until.AddDate(0, 0, 1)
+		}
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		rows, err := app.Sessions.ListUsage(cmd.Context(), since, until)
+		if err != nil {
+			return err
+		}
+		rows = groupUsage(rows, by)
+		out := cmd.OutOrStdout()
+		switch format {
+		case "csv":
+			return writeUsageCSV(out, rows, by)
+		case "json":
+			return writeUsageJSON(out, rows)
+		}
+		if len(rows) == 0 {
+			fmt.Fprintln(out, "No usage")
+			return nil
+		}
+		return writeUsageTable(out, rows, by)
+	},
+}
+
+// usageDateFlag returns the date of the flag at midnight, in local time, or
+// the zero time when it isn't set.
+func usageDateFlag(cmd *cobra.Command, name string) (time.Time, error) {
+	value, _ := cmd.Flags().GetString(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --%s date %q, use YYYY-MM-DD", name, value)
+	}
+	return date, nil
+}
+
+// groupUsage sums the usage of the rows with the same values for the given
+// groups, the other columns being left empty.
+func groupUsage(rows []session.UsageRow, by []string) []session.UsageRow {
+	var groups []session.UsageRow
+	index := make(map[session.UsageRow]int)
+	for _, row := range rows {
+		var key session.UsageRow
+		for _, group := range by {
+			switch group {
+			case "day":
+				key.Day = row.Day
+			case "provider":
+				key.Provider = row.Provider
+			case "model":
+				key.Model = row.Model
+			case "session":
+				key.SessionID = row.SessionID
+				key.Title = row.Title
+			}
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, key)
+		}
+		g := &groups[i]
+		g.Requests += row.Requests
+		g.InputTokens += row.InputTokens
+		g.OutputTokens += row.OutputTokens
+		g.CacheReadTokens += row.CacheReadTokens
+		g.CacheCreationTokens += row.CacheCreationTokens
+		g.Cost += row.Cost
+		g.Latency += row.Latency
+	}
+	slices.SortStableFunc(groups, func(a, b session.UsageRow) int {
+		for _, group := range by {
+			var c int
+			switch group {
+			case "day":
+				c = cmp.Compare(a.Day, b.Day)
+			case "provider":
+				c = cmp.Compare(a.Provider, b.Provider)
+			case "model":
+				c = cmp.Compare(a.Model, b.Model)
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return groups
+}
+
+// usageColumns returns the names of the columns of the groups, in the order
+// of the groups.
+func usageColumns(by []string) []string {
+	var columns []string
+	for _, group := range by {
+		if group == "session" {
+			columns = append(columns, "session_id", "title")
+		} else {
+			columns = append(columns, group)
+		}
+	}
+	return columns
+}
+
+// usageValues returns the values of the columns of the groups for the row.
+func usageValues(row session.UsageRow, by []string) []string {
+	var values []string
+	for _, group := range by {
+		switch group {
+		case "day":
+			values = append(values, row.Day)
+		case "provider":
+			values = append(values, row.Provider)
+		case "model":
+			values = append(values, row.Model)
+		case "session":
+			values = append(values, row.SessionID, row.Title)
+		}
+	}
+	return values
+}
+
+// averageLatency returns the average latency of the requests of the row.
+func averageLatency(row session.UsageRow) time.Duration {
+	if row.Requests == 0 {
+		return 0
+	}
+	return row.Latency / time.Duration(row.Requests)
+}
+
+func writeUsageTable(w io.Writer, rows []session.UsageRow, by []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := append(usageColumns(by), "requests", "input", "output", "cache read", "cache write", "cost", "avg latency")
+	fmt.Fprintln(tw, strings.ToUpper(strings.ReplaceAll(strings.Join(header, "\t"), "_", " ")))
+	write := func(keys []string, row session.UsageRow) {
+		values := append(keys,
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.InputTokens, 10),
+			strconv.FormatInt(row.OutputTokens, 10),
+			strconv.FormatInt(row.CacheReadTokens, 10),
+			strconv.FormatInt(row.CacheCreationTokens, 10),
+			fmt.Sprintf("$%.2f", row.Cost),
+			averageLatency(row).Round(100*time.Millisecond).String(),
+		)
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	for _, row := range rows {
+		write(usageValues(row, by), row)
+	}
+	if len(rows) > 1 {
+		keys := make([]string, len(usageColumns(by)))
+		keys[0] = "TOTAL"
+		write(keys, groupUsage(rows, nil)[0])
+	}
+	return tw.Flush()
+}
+
+func writeUsageCSV(w io.Writer, rows []session.UsageRow, by []string) error {
+	cw := csv.NewWriter(w)
+	header := append(usageColumns(by), "requests", "input_tokens", "output_tokens", "cache_read_tokens", "cache_creation_tokens", "cost", "avg_latency_ms")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := append(usageValues(row, by),
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.InputTokens, 10),
+			strconv.FormatInt(row.OutputTokens, 10),
+			strconv.FormatInt(row.CacheReadTokens, 10),
+			strconv.FormatInt(row.CacheCreationTokens, 10),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+			strconv.FormatInt(averageLatency(row).Milliseconds(), 10),
+		)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeUsageJSON(w io.Writer, rows []session.UsageRow) error {
+	type usageJSON struct {
+		Day                 string  `json:"day,omitempty"`
+		Provider            string  `json:"provider,omitempty"`
+		Model               string  `json:"model,omitempty"`
+		SessionID           string  `json:"session_id,omitempty"`
+		Title               string  `json:"title,omitempty"`
+		Requests            int64   `json:"requests"`
+		InputTokens         int64   `json:"input_tokens"`
+		OutputTokens        int64   `json:"output_tokens"`
+		CacheReadTokens     int64   `json:"cache_read_tokens"`
+		CacheCreationTokens int64   `json:"cache_creation_tokens"`
+		Cost                float64 `json:"cost"`
+		AvgLatencyMs        int64   `json:"avg_latency_ms"`
+	}
+	list := make([]usageJSON, len(rows))
+	for i, row := range rows {
+		list[i] = usageJSON{
+			Day:                 row.Day,
+			Provider:            row.Provider,
+			Model:               row.Model,
+			SessionID:           row.SessionID,
+			Title:               row.Title,
+			Requests:            row.Requests,
+			InputTokens:         row.InputTokens,
+			OutputTokens:        row.OutputTokens,
+			CacheReadTokens:     row.CacheReadTokens,
+			CacheCreationTokens: row.CacheCreationTokens,
+			Cost:                row.Cost,
+			AvgLatencyMs:        averageLatency(row).Milliseconds(),
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
+
+func init() {
+	usageCmd.Flags().StringSlice("by", []string{"day"}, "Group the usage by day, provider, model and/or session")
+	usageCmd.Flags().String("since", "", "First day of the report, as YYYY-MM-DD")
+	usageCmd.Flags().String("until", "", "Last day of the report, as YYYY-MM-DD")
+	usageCmd.Flags().String("format", "table", "Format of the report: table, csv or json")
+}
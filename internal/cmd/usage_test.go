@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupUsage(t *testing.T) {
+	t.Parallel()
+
+	rows := []session.UsageRow{
+		{Day: "2025-10-01", SessionID: "a", Title: "A", Provider: "openai", Model: "gpt-5", Requests: 2, InputTokens: 100, Cost: 1, Latency: 4 * time.Second},
+		{Day: "2025-10-01", SessionID: "b", Title: "B", Provider: "anthropic", Model: "claude", Requests: 1, InputTokens: 10, Cost: 0.5, Latency: time.Second},
+		{Day: "2025-10-02", SessionID: "a", Title: "A", Provider: "openai", Model: "gpt-5", Requests: 2, InputTokens: 100, Cost: 1, Latency: 2 * time.Second},
+	}
+
+	days := groupUsage(rows, []string{"day"})
+	require.Len(t, days, 2)
+	require.Equal(t, "2025-10-01", days[0].Day)
+	require.Empty(t, days[0].Model)
+	require.Equal(t, int64(3), days[0].Requests)
+	require.Equal(t, 1.5, days[0].Cost)
+
+	models := groupUsage(rows, []string{"provider", "model"})
+	require.Len(t, models, 2)
+	require.Equal(t, "anthropic", models[0].Provider)
+	require.Equal(t, "openai", models[1].Provider)
+	require.Equal(t, int64(200), models[1].InputTokens)
+	require.Equal(t, 1500*time.Millisecond, averageLatency(models[1]))
+
+	var b bytes.Buffer
+	require.NoError(t, writeUsageCSV(&b, groupUsage(rows, []string{"session"}), []string{"session"}))
+	require.Equal(t, `session_id,title,requests,input_tokens,output_tokens,cache_read_tokens,cache_creation_tokens,cost,avg_latency_ms
+a,A,4,200,0,0,0,2.000000,1500
+b,B,1,10,0,0,0,0.500000,1000
+`, b.String())
+}
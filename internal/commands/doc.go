@@ -185,10 +185,10 @@
 //   - Invalid tool names are logged as warnings and filtered out
 //   - Tool filtering is case-sensitive
 //
-// Note: Currently, tool restrictions are noted but full enforcement requires
-// coordinator extension to support per-command agent configs. The executor passes
-// the command to the coordinator with all tools available, but the structure is
-// in place for future tool restriction enforcement.
+// Tool restrictions are enforced at execution time: the executor passes the
+// filtered tool list to the coordinator through agent.RunOptions, and the agent
+// only gives those tools to the model for that run. A read-only command that
+// only allows `view`, `grep` and `glob` can't call `edit`, `write` or `bash`.
 //
 // ## Executor Usage Examples
 //
@@ -144,13 +144,12 @@ func (e *executor) Execute(ctx context.Context, sessionID string, commandName st
 	processedContent = "Execute this directly - do not analyze or search:\n\n" + processedContent
 
 	// 5. Filter tools based on allowed-tools frontmatter
-	// Note: Tool restrictions are handled at the agent level through AllowedTools.
-	// The coordinator uses the default agent config, so tool restrictions will
-	// be applied when the agent is built. For now, we execute with the default
-	// agent. Future enhancements may allow per-command agent configs.
-	//
-	// The buildRestrictedAgentConfig function is available for future use when
-	// the coordinator supports dynamic agent configs per Run call.
+	// The restriction only applies to this run, the agent won't be given
+	// any tool that is not in the list.
+	var opts agent.RunOptions
+	if len(cmd.AllowedTools) > 0 {
+		opts.AllowedTools = buildFilteredTools(cmd.AllowedTools)
+	}
 
 	// 6. Execute through coordinator with processed content and attachments
 	slog.Info("Executing command",
@@ -158,9 +157,10 @@ func (e *executor) Execute(ctx context.Context, sessionID string, commandName st
 		"session_id", sessionID,
 		"args_count", len(args),
 		"attachments_count", len(attachments),
+		"allowed_tools", opts.AllowedTools,
 	)
 
-	_, err = e.coordinator.Run(ctx, sessionID, processedContent, attachments...)
+	_, err = e.coordinator.RunWithOptions(ctx, sessionID, processedContent, opts, attachments...)
 	if err != nil {
 		slog.Error("Command execution failed",
 			"command", commandName,
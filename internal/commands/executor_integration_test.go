@@ -24,9 +24,10 @@ type mockCoordinator struct {
 }
 
 type coordinatorCall struct {
-	SessionID   string
-	Prompt      string
-	Attachments []message.Attachment
+	SessionID    string
+	Prompt       string
+	Attachments  []message.Attachment
+	AllowedTools []string
 }
 
 func newMockCoordinator() *mockCoordinator {
@@ -36,13 +37,18 @@ func newMockCoordinator() *mockCoordinator {
 }
 
 func (m *mockCoordinator) Run(ctx context.Context, sessionID string, prompt string, attachments ...message.Attachment) (*fantasy.AgentResult, error) {
+	return m.RunWithOptions(ctx, sessionID, prompt, agent.RunOptions{}, attachments...)
+}
+
+func (m *mockCoordinator) RunWithOptions(ctx context.Context, sessionID string, prompt string, opts agent.RunOptions, attachments ...message.Attachment) (*fantasy.AgentResult, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.calls = append(m.calls, coordinatorCall{
-		SessionID:   sessionID,
-		Prompt:      prompt,
-		Attachments: attachments,
+		SessionID:    sessionID,
+		Prompt:       prompt,
+		Attachments:  attachments,
+		AllowedTools: opts.AllowedTools,
 	})
 
 	if m.runShouldError {
@@ -61,6 +67,10 @@ func (m *mockCoordinator) ClearQueue(sessionID string)
 func (m *mockCoordinator) Summarize(ctx context.Context, sessionID string) error { return nil }
 func (m *mockCoordinator) Model() agent.Model                                     { return agent.Model{} }
 func (m *mockCoordinator) UpdateModels(ctx context.Context) error                 { return nil }
+func (m *mockCoordinator) SetSessionAgent(sessionID, agentID string) error       { return nil }
+func (m *mockCoordinator) SessionAgentID(sessionID string) string                 { return "" }
+func (m *mockCoordinator) SetPlanMode(sessionID string, enabled bool) error        { return nil }
+func (m *mockCoordinator) IsPlanMode(sessionID string) bool                        { return false }
 
 func (m *mockCoordinator) GetCalls() []coordinatorCall {
 	m.mu.Lock()
@@ -133,6 +143,12 @@ func (m *mockMessageService) Subscribe(ctx context.Context) <-chan pubsub.Event[
 	close(ch)
 	return ch
 }
+func (m *mockMessageService) PublishRetry(event message.RetryEvent) {}
+func (m *mockMessageService) SubscribeRetries(ctx context.Context) <-chan pubsub.Event[message.RetryEvent] {
+	ch := make(chan pubsub.Event[message.RetryEvent])
+	close(ch)
+	return ch
+}
 
 func TestIntegration_FullCommandExecution(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -205,6 +221,9 @@ All arguments: $ARGS
 
 	// Verify file reference is preserved in prompt (not removed)
 	assert.Contains(t, call.Prompt, "@test-file.txt", "File reference should be preserved in prompt")
+
+	// Verify the allowed tools were passed to the coordinator
+	assert.Equal(t, []string{"edit", "view"}, call.AllowedTools, "Only the allowed tools should be available")
 }
 
 func TestIntegration_CommandExecutionWithMultipleFiles(t *testing.T) {
@@ -300,9 +319,55 @@ Simple command without tool restrictions.
 	err = executor.Execute(ctx, "session-1", "no-tools-restriction", []string{})
 	require.NoError(t, err)
 
-	// Verify coordinator was called (tool filtering is noted but not enforced in current implementation)
+	// Verify coordinator was called without tool restrictions
+	calls := mockCoord.GetCalls()
+	require.Len(t, calls, 1)
+	assert.Nil(t, calls[0].AllowedTools, "All tools should be available")
+}
+
+func TestIntegration_ReadOnlyCommandExecution(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := tmpDir
+	workingDir := tmpDir
+
+	// Create commands directory
+	commandsDir := filepath.Join(projectDir, ".crush", "commands")
+	require.NoError(t, os.MkdirAll(commandsDir, 0o755))
+
+	// Create a read-only review command
+	cmdFile := filepath.Join(commandsDir, "review.md")
+	cmdContent := `---
+description: Review the current changes
+allowed-tools: ["view", "grep", "glob", "ls"]
+---
+Review the current changes without modifying anything.
+`
+	require.NoError(t, os.WriteFile(cmdFile, []byte(cmdContent), 0o644))
+
+	// Create registry and load commands
+	registry := NewRegistry(projectDir)
+	_, err := registry.LoadCommands()
+	require.NoError(t, err)
+
+	// Create mock coordinator and message service
+	mockCoord := newMockCoordinator()
+	mockMessages := newMockMessageService()
+
+	// Create executor
+	executor := NewExecutor(registry, mockCoord, mockMessages, workingDir)
+
+	// Execute command
+	ctx := context.Background()
+	err = executor.Execute(ctx, "session-1", "review", []string{})
+	require.NoError(t, err)
+
+	// Verify only the read-only tools reach the coordinator
 	calls := mockCoord.GetCalls()
 	require.Len(t, calls, 1)
+	assert.Equal(t, []string{"glob", "grep", "ls", "view"}, calls[0].AllowedTools)
+	for _, tool := range []string{"edit", "multiedit", "write", "bash"} {
+		assert.NotContains(t, calls[0].AllowedTools, tool, "Read-only command should not allow %s", tool)
+	}
 }
 
 func TestIntegration_HelpCommandExecution(t *testing.T) {
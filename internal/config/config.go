@@ -1,9 +1,11 @@
 package config
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
@@ -77,6 +79,10 @@ type SelectedModel struct {
 
 	// Override provider specific options.
 	ProviderOptions map[string]any `json:"provider_options,omitempty" jsonschema:"description=Additional provider-specific options for the model"`
+
+	// Models tried in order when the provider of this model fails with a
+	// retryable error (rate limits, overload or server errors).
+	Fallbacks []SelectedModel `json:"fallbacks,omitempty" jsonschema:"description=Ordered list of models to fall back to when the provider returns rate limit or server errors"`
 }
 
 type ProviderConfig struct {
@@ -103,6 +109,9 @@ type ProviderConfig struct {
 
 	ProviderOptions map[string]any `json:"provider_options,omitempty" jsonschema:"description=Additional provider-specific options for this provider"`
 
+	// Retry policy for rate limits, overload and server errors.
+	Retry *RetryConfig `json:"retry,omitempty" jsonschema:"description=Retry policy for rate limit and server errors returned by the provider"`
+
 	// Used to pass extra parameters to the provider.
 	ExtraParams map[string]string `json:"-"`
 
@@ -110,6 +119,15 @@ type ProviderConfig struct {
 	Models []catwalk.Model `json:"models,omitempty" jsonschema:"description=List of models available from this provider"`
 }
 
+type RetryConfig struct {
+	// Maximum number of retries, 0 disables retrying.
+	MaxRetries *int `json:"max_retries,omitempty" jsonschema:"description=Maximum number of retries for a request (0 disables retries),default=2,minimum=0,example=5"`
+	// Delay before the first retry, in seconds.
+	InitialDelay float64 `json:"initial_delay,omitempty" jsonschema:"description=Delay in seconds before the first retry,default=2,minimum=0,example=1"`
+	// Factor the delay is multiplied by after each retry.
+	BackoffFactor float64 `json:"backoff_factor,omitempty" jsonschema:"description=Factor the delay is multiplied by after each retry,default=2,minimum=1,example=1.5"`
+}
+
 type MCPType string
 
 const (
@@ -182,6 +200,25 @@ type Options struct {
 	DisableProviderAutoUpdate bool         `json:"disable_provider_auto_update,omitempty" jsonschema:"description=Disable providers auto-update,default=false"`
 	Attribution               *Attribution `json:"attribution,omitempty" jsonschema:"description=Attribution settings for generated content"`
 	DisableMetrics            bool         `json:"disable_metrics,omitempty" jsonschema:"description=Disable sending metrics,default=false"`
+	Budget                    *Budget      `json:"budget,omitempty" jsonschema:"description=Cost and token budgets stopping the agent once reached"`
+}
+
+// Budget limits the cost, in USD, and the tokens spent by the agent, a zero
+// limit means no limit.
+type Budget struct {
+	SessionCost   float64 `json:"session_cost,omitempty" jsonschema:"description=Maximum cost in USD of a session,minimum=0,example=5"`
+	SessionTokens int64   `json:"session_tokens,omitempty" jsonschema:"description=Maximum number of tokens used by a session,minimum=0,example=2000000"`
+	RunCost       float64 `json:"run_cost,omitempty" jsonschema:"description=Maximum cost in USD of a crush run invocation,minimum=0,example=1"`
+	RunTokens     int64   `json:"run_tokens,omitempty" jsonschema:"description=Maximum number of tokens used by a crush run invocation,minimum=0,example=500000"`
+	DailyCost     float64 `json:"daily_cost,omitempty" jsonschema:"description=Maximum cost in USD of all the sessions over the last 24 hours,minimum=0,example=20"`
+	DailyTokens   int64   `json:"daily_tokens,omitempty" jsonschema:"description=Maximum number of tokens used by all the sessions over the last 24 hours,minimum=0,example=10000000"`
+	// Fraction of a budget at which the user is warned.
+	WarningThreshold *float64 `json:"warning_threshold,omitempty" jsonschema:"description=Fraction of a budget at which a warning is shown (0 disables the warnings),default=0.8,minimum=0,maximum=1"`
+}
+
+// Warning returns the fraction of a budget at which the user is warned.
+func (b Budget) Warning() float64 {
+	return ptrValOr(b.WarningThreshold, 0.8)
 }
 
 type MCPs map[string]MCPConfig
@@ -248,26 +285,65 @@ func (m MCPConfig) ResolvedHeaders() map[string]string {
 }
 
 type Agent struct {
-	ID          string `json:"id,omitempty"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
+	// The agent id, this is the key used in the agents config.
+	ID          string `json:"id,omitempty" jsonschema:"description=Unique identifier for the agent (defaults to the key in the agents config),example=reviewer"`
+	Name        string `json:"name,omitempty" jsonschema:"description=Human-readable name of the agent,example=Reviewer"`
+	Description string `json:"description,omitempty" jsonschema:"description=Description of what the agent does"`
 	// This is the id of the system prompt used by the agent
-	Disabled bool `json:"disabled,omitempty"`
+	Disabled bool `json:"disabled,omitempty" jsonschema:"description=Whether this agent is disabled,default=false"`
+
+	// Path to a system prompt template for the agent, if empty the built-in
+	// coder prompt is used.
+	Prompt string `json:"prompt,omitempty" jsonschema:"description=Path to a system prompt template file for the agent (relative to the working directory),example=.crush/agents/reviewer.md"`
 
-	Model SelectedModelType `json:"model" jsonschema:"required,description=The model type to use for this agent,enum=large,enum=small,default=large"`
+	Model SelectedModelType `json:"model,omitempty" jsonschema:"description=The model type to use for this agent,enum=large,enum=small,default=large"`
+
+	// Overrides the selected models for this agent, either with a different
+	// provider/model pair or with different options for the same model.
+	Models map[SelectedModelType]SelectedModel `json:"models,omitempty" jsonschema:"description=Model overrides for this agent keyed by model type"`
 
 	// The available tools for the agent
 	//  if this is nil, all tools are available
-	AllowedTools []string `json:"allowed_tools,omitempty"`
+	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=Tools available to the agent (all tools if omitted),example=view,example=grep"`
 
 	// this tells us which MCPs are available for this agent
 	//  if this is empty all mcps are available
 	//  the string array is the list of tools from the AllowedMCP the agent has available
 	//  if the string array is nil, all tools from the AllowedMCP are available
-	AllowedMCP map[string][]string `json:"allowed_mcp,omitempty"`
+	AllowedMCP map[string][]string `json:"allowed_mcp,omitempty" jsonschema:"description=MCP servers and their tools available to the agent (all MCPs if omitted)"`
 
 	// Overrides the context paths for this agent
-	ContextPaths []string `json:"context_paths,omitempty"`
+	ContextPaths []string `json:"context_paths,omitempty" jsonschema:"description=Context paths for this agent that override the global context paths"`
+
+	// How the context is compacted when the conversation gets too long
+	Compaction *Compaction `json:"compaction,omitempty" jsonschema:"description=How the context of the agent is compacted when the conversation gets close to the context window"`
+}
+
+type CompactionStrategy string
+
+const (
+	// CompactionSummarize replaces the whole conversation with a summary.
+	CompactionSummarize CompactionStrategy = "summarize"
+	// CompactionPruneToolResults replaces the large results of the older
+	// tool calls with stubs, and summarizes when there is nothing left to
+	// prune.
+	CompactionPruneToolResults CompactionStrategy = "prune_tool_results"
+	// CompactionKeepRecent summarizes the conversation except for the last
+	// turns, which are kept verbatim.
+	CompactionKeepRecent CompactionStrategy = "keep_recent"
+)
+
+type Compaction struct {
+	Strategy CompactionStrategy `json:"strategy,omitempty" jsonschema:"description=The compaction strategy,enum=summarize,enum=prune_tool_results,enum=keep_recent,default=summarize"`
+	// The context is compacted when the tokens left in the context window
+	// go under the threshold.
+	Threshold      float64 `json:"threshold,omitempty" jsonschema:"description=Fraction of the context window left that triggers the compaction (defaults to 0.2 or 20000 tokens for context windows over 200k),minimum=0,maximum=1,example=0.3"`
+	ReservedTokens int64   `json:"reserved_tokens,omitempty" jsonschema:"description=Number of tokens left in the context window that triggers the compaction (overrides threshold),minimum=0,example=30000"`
+	// Used by keep_recent.
+	KeepTurns int `json:"keep_turns,omitempty" jsonschema:"description=Number of recent turns kept verbatim by the keep_recent strategy,default=2,minimum=1"`
+	// Used by prune_tool_results.
+	KeepToolResults *int `json:"keep_tool_results,omitempty" jsonschema:"description=Number of recent tool results never pruned by the prune_tool_results strategy,default=10,minimum=0"`
+	MinPruneSize    int  `json:"min_prune_size,omitempty" jsonschema:"description=Size in bytes over which a tool result is pruned by the prune_tool_results strategy,default=2000,minimum=0"`
 }
 
 type Tools struct {
@@ -283,6 +359,31 @@ func (t ToolLs) Limits() (depth, items int) {
 	return ptrValOr(t.MaxDepth, 0), ptrValOr(t.MaxItems, 0)
 }
 
+// Hooks are shell commands run around the tool calls of the agents and at
+// the points of the life of the sessions.
+type Hooks struct {
+	PreToolUse        []ToolHook `json:"pre_tool_use,omitempty" jsonschema:"description=Commands run before the matching tool calls, a command exiting with status 2 blocks the call"`
+	PostToolUse       []ToolHook `json:"post_tool_use,omitempty" jsonschema:"description=Commands run after the matching tool calls, their output is added to the tool result"`
+	SessionStart      []Hook     `json:"session_start,omitempty" jsonschema:"description=Commands run when the first prompt of a session is sent, their output is added to the prompt as context"`
+	PromptSubmit      []Hook     `json:"prompt_submit,omitempty" jsonschema:"description=Commands run when a prompt is sent, a command exiting with status 2 blocks the prompt and one printing a JSON object with a prompt field rewrites it, any other output is added to the prompt as context"`
+	TurnEnd           []Hook     `json:"turn_end,omitempty" jsonschema:"description=Commands run when the agent ends its turn, the output of a command exiting with status 2 is sent back to the agent as a new prompt"`
+	PermissionRequest []Hook     `json:"permission_request,omitempty" jsonschema:"description=Commands run when a tool call waits for the permission of the user, e.g. to send a notification"`
+}
+
+type Hook struct {
+	Command string `json:"command" jsonschema:"required,description=Shell command to run, it gets the event as JSON on stdin,example=./scripts/notify.sh"`
+	Timeout int    `json:"timeout,omitempty" jsonschema:"description=Timeout of the command in seconds,default=60,minimum=1"`
+}
+
+type ToolHook struct {
+	// Tool names, glob patterns like "mcp_*" are supported.
+	Tools []string `json:"tools,omitempty" jsonschema:"description=Tools the command runs for (all the tools when empty),example=edit,example=write,example=mcp_*"`
+	// Regular expression matched against the JSON parameters of the call.
+	Pattern string `json:"pattern,omitempty" jsonschema:"description=Regular expression the JSON parameters of the tool call must match,example=\\.go"`
+	Command string `json:"command" jsonschema:"required,description=Shell command to run, it gets the tool call as JSON on stdin,example=gofmt -l ."`
+	Timeout int    `json:"timeout,omitempty" jsonschema:"description=Timeout of the command in seconds,default=60,minimum=1"`
+}
+
 // Config holds the configuration for crush.
 type Config struct {
 	Schema string `json:"$schema,omitempty"`
@@ -303,7 +404,9 @@ type Config struct {
 
 	Tools Tools `json:"tools,omitzero" jsonschema:"description=Tool configurations"`
 
-	Agents map[string]Agent `json:"-"`
+	Agents map[string]Agent `json:"agents,omitempty" jsonschema:"description=Agent configurations that add new agents or override the built-in coder and task agents"`
+
+	Hooks Hooks `json:"hooks,omitzero" jsonschema:"description=Shell commands run around the tool calls of the agents"`
 
 	// Internal
 	workingDir string `json:"-"`
@@ -394,6 +497,10 @@ func (c *Config) Resolve(key string) (string, error) {
 }
 
 func (c *Config) UpdatePreferredModel(modelType SelectedModelType, model SelectedModel) error {
+	// the fallback chain belongs to the slot, keep it when switching models
+	if model.Fallbacks == nil {
+		model.Fallbacks = c.Models[modelType].Fallbacks
+	}
 	c.Models[modelType] = model
 	if err := c.SetConfigField(fmt.Sprintf("models.%s", modelType), model); err != nil {
 		return fmt.Errorf("failed to update preferred model: %w", err)
@@ -524,7 +631,7 @@ func (c *Config) SetupAgents() {
 		},
 
 		AgentTask: {
-			ID:           AgentCoder,
+			ID:           AgentTask,
 			Name:         "Task",
 			Description:  "An agent that helps with searching for context and finding implementation details.",
 			Model:        SelectedModelTypeLarge,
@@ -534,9 +641,136 @@ func (c *Config) SetupAgents() {
 			AllowedMCP: map[string][]string{},
 		},
 	}
+
+	// merge the user defined agents on top of the built-in ones
+	for id, userAgent := range c.Agents {
+		agent, ok := agents[id]
+		if !ok {
+			agent = Agent{
+				Name:         id,
+				Model:        SelectedModelTypeLarge,
+				ContextPaths: c.Options.ContextPaths,
+				AllowedTools: allowedTools,
+			}
+		}
+		agent.ID = id
+		agent.Disabled = userAgent.Disabled
+		if userAgent.Name != "" {
+			agent.Name = userAgent.Name
+		}
+		if userAgent.Description != "" {
+			agent.Description = userAgent.Description
+		}
+		if userAgent.Prompt != "" {
+			agent.Prompt = userAgent.Prompt
+		}
+		if userAgent.Model != "" {
+			agent.Model = userAgent.Model
+		}
+		if userAgent.Models != nil {
+			agent.Models = userAgent.Models
+		}
+		if userAgent.AllowedTools != nil {
+			// globally disabled tools can't be enabled per agent
+			agent.AllowedTools = resolveAllowedTools(userAgent.AllowedTools, c.Options.DisabledTools)
+		}
+		if userAgent.AllowedMCP != nil {
+			agent.AllowedMCP = userAgent.AllowedMCP
+		}
+		if userAgent.ContextPaths != nil {
+			agent.ContextPaths = userAgent.ContextPaths
+		}
+		if userAgent.Compaction != nil {
+			agent.Compaction = userAgent.Compaction
+		}
+		agents[id] = agent
+	}
 	c.Agents = agents
 }
 
+// AgentModel returns the model config used by the agent for the given model
+// type, with the agent overrides applied on top of the selected model.
+func (c *Config) AgentModel(agent Agent, modelType SelectedModelType) (SelectedModel, bool) {
+	selected, ok := c.Models[modelType]
+	override, hasOverride := agent.Models[modelType]
+	if !hasOverride {
+		return selected, ok
+	}
+
+	// a different model, only use the agent options
+	if override.Model != "" {
+		override.Provider = cmp.Or(override.Provider, selected.Provider)
+		if c.GetModel(override.Provider, override.Model) == nil {
+			slog.Warn("Agent model not found, using the selected model", "agent", agent.ID, "provider", override.Provider, "model", override.Model)
+			return selected, ok
+		}
+		return override, true
+	}
+	if !ok {
+		return SelectedModel{}, false
+	}
+
+	if override.ReasoningEffort != "" {
+		selected.ReasoningEffort = override.ReasoningEffort
+	}
+	if override.Think {
+		selected.Think = true
+	}
+	if override.MaxTokens > 0 {
+		selected.MaxTokens = override.MaxTokens
+	}
+	if override.Temperature != nil {
+		selected.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		selected.TopP = override.TopP
+	}
+	if override.TopK != nil {
+		selected.TopK = override.TopK
+	}
+	if override.FrequencyPenalty != nil {
+		selected.FrequencyPenalty = override.FrequencyPenalty
+	}
+	if override.PresencePenalty != nil {
+		selected.PresencePenalty = override.PresencePenalty
+	}
+	if override.Fallbacks != nil {
+		selected.Fallbacks = override.Fallbacks
+	}
+	if override.ProviderOptions != nil {
+		providerOptions := maps.Clone(selected.ProviderOptions)
+		if providerOptions == nil {
+			providerOptions = make(map[string]any, len(override.ProviderOptions))
+		}
+		maps.Copy(providerOptions, override.ProviderOptions)
+		selected.ProviderOptions = providerOptions
+	}
+	return selected, true
+}
+
+// EnabledAgents returns the agents that can be selected as the main agent of
+// a session, sorted by id with the coder agent first.
+func (c *Config) EnabledAgents() []Agent {
+	var enabled []Agent
+	for id, agent := range c.Agents {
+		// the task agent is only used as a sub-agent
+		if id == AgentTask || (agent.Disabled && id != AgentCoder) {
+			continue
+		}
+		enabled = append(enabled, agent)
+	}
+	slices.SortFunc(enabled, func(a, b Agent) int {
+		switch {
+		case a.ID == AgentCoder:
+			return -1
+		case b.ID == AgentCoder:
+			return 1
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return enabled
+}
+
 func (c *Config) Resolver() VariableResolver {
 	return c.resolver
 }
@@ -190,6 +190,7 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			SystemPromptPrefix: config.SystemPromptPrefix,
 			ExtraHeaders:       headers,
 			ExtraBody:          config.ExtraBody,
+			Retry:              config.Retry,
 			ExtraParams:        make(map[string]string),
 			Models:             p.Models,
 		}
@@ -515,6 +516,7 @@ func (c *Config) configureSelectedModels(knownProviders []catwalk.Provider) erro
 			if largeModelSelected.PresencePenalty != nil {
 				large.PresencePenalty = largeModelSelected.PresencePenalty
 			}
+			large.Fallbacks = largeModelSelected.Fallbacks
 		}
 	}
 	smallModelSelected, smallModelConfigured := c.Models[SelectedModelTypeSmall]
@@ -559,6 +561,7 @@ func (c *Config) configureSelectedModels(knownProviders []catwalk.Provider) erro
 				small.PresencePenalty = smallModelSelected.PresencePenalty
 			}
 			small.Think = smallModelSelected.Think
+			small.Fallbacks = smallModelSelected.Fallbacks
 		}
 	}
 	c.Models[SelectedModelTypeLarge] = large
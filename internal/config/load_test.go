@@ -515,6 +515,178 @@ func TestConfig_setupAgentsWithEveryReadOnlyToolDisabled(t *testing.T) {
 	assert.Equal(t, []string{}, taskAgent.AllowedTools)
 }
 
+func TestConfig_setupAgentsWithUserDefinedAgents(t *testing.T) {
+	cfg := &Config{
+		Options: &Options{
+			DisabledTools: []string{"bash"},
+			ContextPaths:  []string{"CRUSH.md"},
+		},
+		Agents: map[string]Agent{
+			"reviewer": {
+				Description:  "Reviews code",
+				Prompt:       ".crush/agents/reviewer.md",
+				AllowedTools: []string{"view", "grep", "bash"},
+				AllowedMCP:   map[string][]string{"github": nil},
+			},
+			"disabled": {
+				Disabled: true,
+			},
+			AgentTask: {
+				Model:        SelectedModelTypeSmall,
+				AllowedTools: []string{"view"},
+				Compaction:   &Compaction{Strategy: CompactionKeepRecent, KeepTurns: 3},
+			},
+		},
+	}
+
+	cfg.SetupAgents()
+
+	reviewer, ok := cfg.Agents["reviewer"]
+	require.True(t, ok)
+	assert.Equal(t, "reviewer", reviewer.ID)
+	assert.Equal(t, "reviewer", reviewer.Name)
+	assert.Equal(t, "Reviews code", reviewer.Description)
+	assert.Equal(t, ".crush/agents/reviewer.md", reviewer.Prompt)
+	assert.Equal(t, SelectedModelTypeLarge, reviewer.Model)
+	assert.Equal(t, []string{"view", "grep"}, reviewer.AllowedTools)
+	assert.Equal(t, map[string][]string{"github": nil}, reviewer.AllowedMCP)
+	assert.Equal(t, []string{"CRUSH.md"}, reviewer.ContextPaths)
+
+	taskAgent, ok := cfg.Agents[AgentTask]
+	require.True(t, ok)
+	assert.Equal(t, AgentTask, taskAgent.ID)
+	assert.Equal(t, "Task", taskAgent.Name)
+	assert.Equal(t, SelectedModelTypeSmall, taskAgent.Model)
+	assert.Equal(t, []string{"view"}, taskAgent.AllowedTools)
+	assert.Equal(t, &Compaction{Strategy: CompactionKeepRecent, KeepTurns: 3}, taskAgent.Compaction)
+
+	coderAgent, ok := cfg.Agents[AgentCoder]
+	require.True(t, ok)
+	assert.NotContains(t, coderAgent.AllowedTools, "bash")
+	assert.Nil(t, coderAgent.Compaction)
+
+	var enabled []string
+	for _, agent := range cfg.EnabledAgents() {
+		enabled = append(enabled, agent.ID)
+	}
+	assert.Equal(t, []string{AgentCoder, "reviewer"}, enabled)
+
+	// setting up the agents again should not change them
+	cfg.SetupAgents()
+	assert.Equal(t, reviewer, cfg.Agents["reviewer"])
+	assert.Equal(t, taskAgent, cfg.Agents[AgentTask])
+}
+
+func TestConfig_AgentModel(t *testing.T) {
+	newConfig := func(agentModels map[SelectedModelType]SelectedModel) (*Config, Agent) {
+		temperature := 0.7
+		cfg := &Config{
+			Models: map[SelectedModelType]SelectedModel{
+				SelectedModelTypeLarge: {
+					Model:           "large-model",
+					Provider:        "openai",
+					ReasoningEffort: "high",
+					MaxTokens:       4096,
+					Temperature:     &temperature,
+					ProviderOptions: map[string]any{"store": true},
+				},
+				SelectedModelTypeSmall: {
+					Model:    "small-model",
+					Provider: "openai",
+				},
+			},
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"openai": {
+					ID:     "openai",
+					Models: []catwalk.Model{{ID: "large-model"}, {ID: "small-model"}},
+				},
+				"anthropic": {
+					ID:     "anthropic",
+					Models: []catwalk.Model{{ID: "fast-model"}},
+				},
+			}),
+		}
+		return cfg, Agent{ID: AgentTask, Models: agentModels}
+	}
+
+	t.Run("no overrides", func(t *testing.T) {
+		cfg, agent := newConfig(nil)
+		model, ok := cfg.AgentModel(agent, SelectedModelTypeLarge)
+		require.True(t, ok)
+		require.Equal(t, cfg.Models[SelectedModelTypeLarge], model)
+	})
+
+	t.Run("different model", func(t *testing.T) {
+		temperature := 0.2
+		cfg, agent := newConfig(map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {
+				Model:       "fast-model",
+				Provider:    "anthropic",
+				Temperature: &temperature,
+			},
+		})
+		model, ok := cfg.AgentModel(agent, SelectedModelTypeLarge)
+		require.True(t, ok)
+		require.Equal(t, SelectedModel{
+			Model:       "fast-model",
+			Provider:    "anthropic",
+			Temperature: &temperature,
+		}, model)
+
+		small, ok := cfg.AgentModel(agent, SelectedModelTypeSmall)
+		require.True(t, ok)
+		require.Equal(t, cfg.Models[SelectedModelTypeSmall], small)
+	})
+
+	t.Run("unknown model falls back to the selected model", func(t *testing.T) {
+		cfg, agent := newConfig(map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {
+				Model:    "missing-model",
+				Provider: "anthropic",
+			},
+		})
+		model, ok := cfg.AgentModel(agent, SelectedModelTypeLarge)
+		require.True(t, ok)
+		require.Equal(t, cfg.Models[SelectedModelTypeLarge], model)
+	})
+
+	t.Run("options only", func(t *testing.T) {
+		temperature := 0.1
+		cfg, agent := newConfig(map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {
+				ReasoningEffort: "low",
+				Temperature:     &temperature,
+				ProviderOptions: map[string]any{"parallel_tool_calls": false},
+			},
+		})
+		model, ok := cfg.AgentModel(agent, SelectedModelTypeLarge)
+		require.True(t, ok)
+		require.Equal(t, "large-model", model.Model)
+		require.Equal(t, "openai", model.Provider)
+		require.Equal(t, "low", model.ReasoningEffort)
+		require.Equal(t, int64(4096), model.MaxTokens)
+		require.Equal(t, 0.1, *model.Temperature)
+		require.Equal(t, map[string]any{"store": true, "parallel_tool_calls": false}, model.ProviderOptions)
+
+		// the selected model should not be modified
+		require.Equal(t, map[string]any{"store": true}, cfg.Models[SelectedModelTypeLarge].ProviderOptions)
+	})
+
+	t.Run("fallbacks", func(t *testing.T) {
+		fallbacks := []SelectedModel{{Model: "fast-model", Provider: "anthropic"}}
+		cfg, agent := newConfig(map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {
+				Fallbacks: fallbacks,
+			},
+		})
+		model, ok := cfg.AgentModel(agent, SelectedModelTypeLarge)
+		require.True(t, ok)
+		require.Equal(t, "large-model", model.Model)
+		require.Equal(t, fallbacks, model.Fallbacks)
+		require.Nil(t, cfg.Models[SelectedModelTypeLarge].Fallbacks)
+	})
+}
+
 func TestConfig_configureProvidersWithDisabledProvider(t *testing.T) {
 	knownProviders := []catwalk.Provider{
 		{
@@ -1142,6 +1314,40 @@ func TestConfig_configureSelectedModels(t *testing.T) {
 		require.Equal(t, "openai", small.Provider)
 		require.Equal(t, int64(500), small.MaxTokens)
 	})
+	t.Run("should keep the fallback models", func(t *testing.T) {
+		knownProviders := []catwalk.Provider{
+			{
+				ID:                  "openai",
+				APIKey:              "abc",
+				DefaultLargeModelID: "large-model",
+				DefaultSmallModelID: "small-model",
+				Models: []catwalk.Model{
+					{ID: "large-model", DefaultMaxTokens: 1000},
+					{ID: "small-model", DefaultMaxTokens: 500},
+				},
+			},
+		}
+
+		fallbacks := []SelectedModel{{Model: "small-model", Provider: "openai"}}
+		cfg := &Config{
+			Models: map[SelectedModelType]SelectedModel{
+				"large": {
+					Model:     "large-model",
+					Fallbacks: fallbacks,
+				},
+			},
+		}
+		cfg.setDefaults("/tmp", "")
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, knownProviders)
+		require.NoError(t, err)
+
+		err = cfg.configureSelectedModels(knownProviders)
+		require.NoError(t, err)
+		require.Equal(t, fallbacks, cfg.Models[SelectedModelTypeLarge].Fallbacks)
+		require.Empty(t, cfg.Models[SelectedModelTypeSmall].Fallbacks)
+	})
 	t.Run("should be possible to use multiple providers", func(t *testing.T) {
 		knownProviders := []catwalk.Provider{
 			{
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+)
+
+// Overrides are settings given on the command line for a single invocation,
+// applied on top of the configuration without being saved.
+type Overrides struct {
+	// LargeModel and SmallModel select the models, as provider/model or just
+	// the ID of the model.
+	LargeModel string
+	SmallModel string
+	// AllowedTools are added to the tools that don't require permission
+	// prompts.
+	AllowedTools []string
+	// DisallowedTools are removed from the tools of all the agents.
+	DisallowedTools []string
+}
+
+// ApplyOverrides applies the overrides to the configuration, it must be
+// called before the agents are built.
+func (c *Config) ApplyOverrides(o Overrides) error {
+	for modelType, name := range map[SelectedModelType]string{
+		SelectedModelTypeLarge: o.LargeModel,
+		SelectedModelTypeSmall: o.SmallModel,
+	} {
+		if name == "" {
+			continue
+		}
+		model, err := c.findModel(name)
+		if err != nil {
+			return fmt.Errorf("invalid %s model: %w", modelType, err)
+		}
+		// the fallback chain belongs to the slot, keep it
+		model.Fallbacks = c.Models[modelType].Fallbacks
+		if c.Models == nil {
+			c.Models = make(map[SelectedModelType]SelectedModel)
+		}
+		c.Models[modelType] = model
+	}
+
+	if len(o.AllowedTools) > 0 {
+		if c.Permissions == nil {
+			c.Permissions = &Permissions{}
+		}
+		c.Permissions.AllowedTools = append(slices.Clone(c.Permissions.AllowedTools), o.AllowedTools...)
+	}
+
+	if len(o.DisallowedTools) > 0 {
+		c.Options.DisabledTools = append(slices.Clone(c.Options.DisabledTools), o.DisallowedTools...)
+		for id, agent := range c.Agents {
+			agent.AllowedTools = resolveAllowedTools(agent.AllowedTools, o.DisallowedTools)
+			c.Agents[id] = agent
+		}
+	}
+	return nil
+}
+
+// findModel returns the selection of the model with the given name, either
+// provider/model or the ID of a model of any enabled provider.
+func (c *Config) findModel(name string) (SelectedModel, error) {
+	selected := func(provider string, model *catwalk.Model) SelectedModel {
+		return SelectedModel{
+			Provider:        provider,
+			Model:           model.ID,
+			MaxTokens:       model.DefaultMaxTokens,
+			ReasoningEffort: model.DefaultReasoningEffort,
+		}
+	}
+	if provider, id, ok := strings.Cut(name, "/"); ok {
+		if p, ok := c.Providers.Get(provider); ok && !p.Disable {
+			if model := c.GetModel(provider, id); model != nil {
+				return selected(provider, model), nil
+			}
+		}
+	}
+	// the IDs of some models have slashes, e.g. on OpenRouter
+	for _, provider := range c.EnabledProviders() {
+		if model := c.GetModel(provider.ID, name); model != nil {
+			return selected(provider.ID, model), nil
+		}
+	}
+	return SelectedModel{}, fmt.Errorf("model %q not found", name)
+}
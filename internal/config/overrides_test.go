@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ApplyOverrides(t *testing.T) {
+	newConfig := func() *Config {
+		cfg := &Config{
+			Models: map[SelectedModelType]SelectedModel{
+				SelectedModelTypeLarge: {Provider: "anthropic", Model: "claude-opus", Fallbacks: []SelectedModel{{Provider: "openai", Model: "gpt-5"}}},
+				SelectedModelTypeSmall: {Provider: "anthropic", Model: "claude-haiku"},
+			},
+			Options: &Options{},
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"anthropic":  {ID: "anthropic", Models: []catwalk.Model{{ID: "claude-opus"}, {ID: "claude-sonnet", DefaultMaxTokens: 5000}, {ID: "claude-haiku"}}},
+				"openrouter": {ID: "openrouter", Models: []catwalk.Model{{ID: "moonshotai/kimi-k2"}}},
+				"openai":     {ID: "openai", Disable: true, Models: []catwalk.Model{{ID: "gpt-5"}}},
+			}),
+		}
+		cfg.SetupAgents()
+		return cfg
+	}
+
+	t.Run("models", func(t *testing.T) {
+		cfg := newConfig()
+		require.NoError(t, cfg.ApplyOverrides(Overrides{LargeModel: "anthropic/claude-sonnet", SmallModel: "moonshotai/kimi-k2"}))
+
+		large := cfg.Models[SelectedModelTypeLarge]
+		require.Equal(t, "anthropic", large.Provider)
+		require.Equal(t, "claude-sonnet", large.Model)
+		require.Equal(t, int64(5000), large.MaxTokens)
+		require.Len(t, large.Fallbacks, 1)
+
+		small := cfg.Models[SelectedModelTypeSmall]
+		require.Equal(t, "openrouter", small.Provider)
+		require.Equal(t, "moonshotai/kimi-k2", small.Model)
+	})
+
+	t.Run("unknown model", func(t *testing.T) {
+		cfg := newConfig()
+		require.Error(t, cfg.ApplyOverrides(Overrides{LargeModel: "anthropic/gpt-5"}))
+		require.Error(t, cfg.ApplyOverrides(Overrides{LargeModel: "openai/gpt-5"}))
+		require.Equal(t, "claude-opus", cfg.Models[SelectedModelTypeLarge].Model)
+	})
+
+	t.Run("tools", func(t *testing.T) {
+		cfg := newConfig()
+		require.NoError(t, cfg.ApplyOverrides(Overrides{AllowedTools: []string{"view", "grep"}, DisallowedTools: []string{"bash", "grep"}}))
+		require.Equal(t, []string{"view", "grep"}, cfg.Permissions.AllowedTools)
+		require.NotContains(t, cfg.Agents[AgentCoder].AllowedTools, "bash")
+		require.NotContains(t, cfg.Agents[AgentTask].AllowedTools, "grep")
+		require.Contains(t, cfg.Agents[AgentCoder].AllowedTools, "view")
+	})
+}
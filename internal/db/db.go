@@ -24,6 +24,21 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.copyCheckpointStmt, err = db.PrepareContext(ctx, copyCheckpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query CopyCheckpoint: %w", err)
+	}
+	if q.copyCheckpointFilesStmt, err = db.PrepareContext(ctx, copyCheckpointFiles); err != nil {
+		return nil, fmt.Errorf("error preparing query CopyCheckpointFiles: %w", err)
+	}
+	if q.copySearchDocumentStmt, err = db.PrepareContext(ctx, copySearchDocument); err != nil {
+		return nil, fmt.Errorf("error preparing query CopySearchDocument: %w", err)
+	}
+	if q.createCheckpointStmt, err = db.PrepareContext(ctx, createCheckpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateCheckpoint: %w", err)
+	}
+	if q.createCheckpointFilesStmt, err = db.PrepareContext(ctx, createCheckpointFiles); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateCheckpointFiles: %w", err)
+	}
 	if q.createFileStmt, err = db.PrepareContext(ctx, createFile); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateFile: %w", err)
 	}
@@ -33,6 +48,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.createSessionStmt, err = db.PrepareContext(ctx, createSession); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateSession: %w", err)
 	}
+	if q.createUsageStmt, err = db.PrepareContext(ctx, createUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateUsage: %w", err)
+	}
 	if q.deleteFileStmt, err = db.PrepareContext(ctx, deleteFile); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteFile: %w", err)
 	}
@@ -48,6 +66,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.deleteSessionMessagesStmt, err = db.PrepareContext(ctx, deleteSessionMessages); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteSessionMessages: %w", err)
 	}
+	if q.getCheckpointByMessageStmt, err = db.PrepareContext(ctx, getCheckpointByMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query GetCheckpointByMessage: %w", err)
+	}
 	if q.getFileStmt, err = db.PrepareContext(ctx, getFile); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFile: %w", err)
 	}
@@ -60,6 +81,15 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getSessionByIDStmt, err = db.PrepareContext(ctx, getSessionByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSessionByID: %w", err)
 	}
+	if q.getUsageSinceStmt, err = db.PrepareContext(ctx, getUsageSince); err != nil {
+		return nil, fmt.Errorf("error preparing query GetUsageSince: %w", err)
+	}
+	if q.listCheckpointFilesStmt, err = db.PrepareContext(ctx, listCheckpointFiles); err != nil {
+		return nil, fmt.Errorf("error preparing query ListCheckpointFiles: %w", err)
+	}
+	if q.listCheckpointsBySessionStmt, err = db.PrepareContext(ctx, listCheckpointsBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListCheckpointsBySession: %w", err)
+	}
 	if q.listFilesByPathStmt, err = db.PrepareContext(ctx, listFilesByPath); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesByPath: %w", err)
 	}
@@ -78,17 +108,51 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listSessionsStmt, err = db.PrepareContext(ctx, listSessions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessions: %w", err)
 	}
+	if q.listUsageStmt, err = db.PrepareContext(ctx, listUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUsage: %w", err)
+	}
+	if q.searchSessionsStmt, err = db.PrepareContext(ctx, searchSessions); err != nil {
+		return nil, fmt.Errorf("error preparing query SearchSessions: %w", err)
+	}
 	if q.updateMessageStmt, err = db.PrepareContext(ctx, updateMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateMessage: %w", err)
 	}
 	if q.updateSessionStmt, err = db.PrepareContext(ctx, updateSession); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateSession: %w", err)
 	}
+	if q.upsertSearchDocumentStmt, err = db.PrepareContext(ctx, upsertSearchDocument); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertSearchDocument: %w", err)
+	}
 	return &q, nil
 }
 
 func (q *Queries) Close() error {
 	var err error
+	if q.copyCheckpointStmt != nil {
+		if cerr := q.copyCheckpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copyCheckpointStmt: %w", cerr)
+		}
+	}
+	if q.copyCheckpointFilesStmt != nil {
+		if cerr := q.copyCheckpointFilesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copyCheckpointFilesStmt: %w", cerr)
+		}
+	}
+	if q.copySearchDocumentStmt != nil {
+		if cerr := q.copySearchDocumentStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copySearchDocumentStmt: %w", cerr)
+		}
+	}
+	if q.createCheckpointStmt != nil {
+		if cerr := q.createCheckpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createCheckpointStmt: %w", cerr)
+		}
+	}
+	if q.createCheckpointFilesStmt != nil {
+		if cerr := q.createCheckpointFilesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createCheckpointFilesStmt: %w", cerr)
+		}
+	}
 	if q.createFileStmt != nil {
 		if cerr := q.createFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createFileStmt: %w", cerr)
@@ -104,6 +168,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing createSessionStmt: %w", cerr)
 		}
 	}
+	if q.createUsageStmt != nil {
+		if cerr := q.createUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createUsageStmt: %w", cerr)
+		}
+	}
 	if q.deleteFileStmt != nil {
 		if cerr := q.deleteFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteFileStmt: %w", cerr)
@@ -129,6 +198,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteSessionMessagesStmt: %w", cerr)
 		}
 	}
+	if q.getCheckpointByMessageStmt != nil {
+		if cerr := q.getCheckpointByMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getCheckpointByMessageStmt: %w", cerr)
+		}
+	}
 	if q.getFileStmt != nil {
 		if cerr := q.getFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getFileStmt: %w", cerr)
@@ -149,6 +223,21 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getSessionByIDStmt: %w", cerr)
 		}
 	}
+	if q.getUsageSinceStmt != nil {
+		if cerr := q.getUsageSinceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getUsageSinceStmt: %w", cerr)
+		}
+	}
+	if q.listCheckpointFilesStmt != nil {
+		if cerr := q.listCheckpointFilesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listCheckpointFilesStmt: %w", cerr)
+		}
+	}
+	if q.listCheckpointsBySessionStmt != nil {
+		if cerr := q.listCheckpointsBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listCheckpointsBySessionStmt: %w", cerr)
+		}
+	}
 	if q.listFilesByPathStmt != nil {
 		if cerr := q.listFilesByPathStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listFilesByPathStmt: %w", cerr)
@@ -179,6 +268,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listSessionsStmt: %w", cerr)
 		}
 	}
+	if q.listUsageStmt != nil {
+		if cerr := q.listUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUsageStmt: %w", cerr)
+		}
+	}
+	if q.searchSessionsStmt != nil {
+		if cerr := q.searchSessionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing searchSessionsStmt: %w", cerr)
+		}
+	}
 	if q.updateMessageStmt != nil {
 		if cerr := q.updateMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateMessageStmt: %w", cerr)
@@ -189,6 +288,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing updateSessionStmt: %w", cerr)
 		}
 	}
+	if q.upsertSearchDocumentStmt != nil {
+		if cerr := q.upsertSearchDocumentStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertSearchDocumentStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -226,53 +330,79 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                          DBTX
-	tx                          *sql.Tx
-	createFileStmt              *sql.Stmt
-	createMessageStmt           *sql.Stmt
-	createSessionStmt           *sql.Stmt
-	deleteFileStmt              *sql.Stmt
-	deleteMessageStmt           *sql.Stmt
-	deleteSessionStmt           *sql.Stmt
-	deleteSessionFilesStmt      *sql.Stmt
-	deleteSessionMessagesStmt   *sql.Stmt
-	getFileStmt                 *sql.Stmt
-	getFileByPathAndSessionStmt *sql.Stmt
-	getMessageStmt              *sql.Stmt
-	getSessionByIDStmt          *sql.Stmt
-	listFilesByPathStmt         *sql.Stmt
-	listFilesBySessionStmt      *sql.Stmt
-	listLatestSessionFilesStmt  *sql.Stmt
-	listMessagesBySessionStmt   *sql.Stmt
-	listNewFilesStmt            *sql.Stmt
-	listSessionsStmt            *sql.Stmt
-	updateMessageStmt           *sql.Stmt
-	updateSessionStmt           *sql.Stmt
+	db                           DBTX
+	tx                           *sql.Tx
+	copyCheckpointStmt           *sql.Stmt
+	copyCheckpointFilesStmt      *sql.Stmt
+	copySearchDocumentStmt       *sql.Stmt
+	createCheckpointStmt         *sql.Stmt
+	createCheckpointFilesStmt    *sql.Stmt
+	createFileStmt               *sql.Stmt
+	createMessageStmt            *sql.Stmt
+	createSessionStmt            *sql.Stmt
+	createUsageStmt              *sql.Stmt
+	deleteFileStmt               *sql.Stmt
+	deleteMessageStmt            *sql.Stmt
+	deleteSessionStmt            *sql.Stmt
+	deleteSessionFilesStmt       *sql.Stmt
+	deleteSessionMessagesStmt    *sql.Stmt
+	getCheckpointByMessageStmt   *sql.Stmt
+	getFileStmt                  *sql.Stmt
+	getFileByPathAndSessionStmt  *sql.Stmt
+	getMessageStmt               *sql.Stmt
+	getSessionByIDStmt           *sql.Stmt
+	getUsageSinceStmt            *sql.Stmt
+	listCheckpointFilesStmt      *sql.Stmt
+	listCheckpointsBySessionStmt *sql.Stmt
+	listFilesByPathStmt          *sql.Stmt
+	listFilesBySessionStmt       *sql.Stmt
+	listLatestSessionFilesStmt   *sql.Stmt
+	listMessagesBySessionStmt    *sql.Stmt
+	listNewFilesStmt             *sql.Stmt
+	listSessionsStmt             *sql.Stmt
+	listUsageStmt                *sql.Stmt
+	searchSessionsStmt           *sql.Stmt
+	updateMessageStmt            *sql.Stmt
+	updateSessionStmt            *sql.Stmt
+	upsertSearchDocumentStmt     *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                          tx,
-		tx:                          tx,
-		createFileStmt:              q.createFileStmt,
-		createMessageStmt:           q.createMessageStmt,
-		createSessionStmt:           q.createSessionStmt,
-		deleteFileStmt:              q.deleteFileStmt,
-		deleteMessageStmt:           q.deleteMessageStmt,
-		deleteSessionStmt:           q.deleteSessionStmt,
-		deleteSessionFilesStmt:      q.deleteSessionFilesStmt,
-		deleteSessionMessagesStmt:   q.deleteSessionMessagesStmt,
-		getFileStmt:                 q.getFileStmt,
-		getFileByPathAndSessionStmt: q.getFileByPathAndSessionStmt,
-		getMessageStmt:              q.getMessageStmt,
-		getSessionByIDStmt:          q.getSessionByIDStmt,
-		listFilesByPathStmt:         q.listFilesByPathStmt,
-		listFilesBySessionStmt:      q.listFilesBySessionStmt,
-		listLatestSessionFilesStmt:  q.listLatestSessionFilesStmt,
-		listMessagesBySessionStmt:   q.listMessagesBySessionStmt,
-		listNewFilesStmt:            q.listNewFilesStmt,
-		listSessionsStmt:            q.listSessionsStmt,
-		updateMessageStmt:           q.updateMessageStmt,
-		updateSessionStmt:           q.updateSessionStmt,
+		db:                           tx,
+		tx:                           tx,
+		copyCheckpointStmt:           q.copyCheckpointStmt,
+		copyCheckpointFilesStmt:      q.copyCheckpointFilesStmt,
+		copySearchDocumentStmt:       q.copySearchDocumentStmt,
+		createCheckpointStmt:         q.createCheckpointStmt,
+		createCheckpointFilesStmt:    q.createCheckpointFilesStmt,
+		createFileStmt:               q.createFileStmt,
+		createMessageStmt:            q.createMessageStmt,
+		createSessionStmt:            q.createSessionStmt,
+		createUsageStmt:              q.createUsageStmt,
+		deleteFileStmt:               q.deleteFileStmt,
+		deleteMessageStmt:            q.deleteMessageStmt,
+		deleteSessionStmt:            q.deleteSessionStmt,
+		deleteSessionFilesStmt:       q.deleteSessionFilesStmt,
+		deleteSessionMessagesStmt:    q.deleteSessionMessagesStmt,
+		getCheckpointByMessageStmt:   q.getCheckpointByMessageStmt,
+		getFileStmt:                  q.getFileStmt,
+		getFileByPathAndSessionStmt:  q.getFileByPathAndSessionStmt,
+		getMessageStmt:               q.getMessageStmt,
+		getSessionByIDStmt:           q.getSessionByIDStmt,
+		getUsageSinceStmt:            q.getUsageSinceStmt,
+		listCheckpointFilesStmt:      q.listCheckpointFilesStmt,
+		listCheckpointsBySessionStmt: q.listCheckpointsBySessionStmt,
+		listFilesByPathStmt:          q.listFilesByPathStmt,
+		listFilesBySessionStmt:       q.listFilesBySessionStmt,
+		listLatestSessionFilesStmt:   q.listLatestSessionFilesStmt,
+		listMessagesBySessionStmt:    q.listMessagesBySessionStmt,
+		listNewFilesStmt:             q.listNewFilesStmt,
+		listSessionsStmt:             q.listSessionsStmt,
+		listUsageStmt:                q.listUsageStmt,
+		searchSessionsStmt:           q.searchSessionsStmt,
+		updateMessageStmt:            q.updateMessageStmt,
+		updateSessionStmt:            q.updateSessionStmt,
+		upsertSearchDocumentStmt:     q.upsertSearchDocumentStmt,
 	}
 }
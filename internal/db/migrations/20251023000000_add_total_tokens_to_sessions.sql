@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE sessions ADD COLUMN total_tokens INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN total_tokens;
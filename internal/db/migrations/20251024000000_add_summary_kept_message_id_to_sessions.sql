@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE sessions ADD COLUMN summary_kept_message_id TEXT;
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN summary_kept_message_id;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE sessions ADD COLUMN forked_from_message_id TEXT;
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN forked_from_message_id;
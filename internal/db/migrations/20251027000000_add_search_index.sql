@@ -0,0 +1,85 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The text searched in, the title of a session has no message
+CREATE TABLE IF NOT EXISTS search_documents (
+    id INTEGER PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    message_id TEXT UNIQUE,
+    content TEXT NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_documents_session_id ON search_documents (session_id);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5 (
+    content,
+    content = 'search_documents',
+    content_rowid = 'id',
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS search_documents_insert
+AFTER INSERT ON search_documents
+BEGIN
+INSERT INTO search_index (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_documents_delete
+AFTER DELETE ON search_documents
+BEGIN
+INSERT INTO search_index (search_index, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_documents_update
+AFTER UPDATE ON search_documents
+BEGIN
+INSERT INTO search_index (search_index, rowid, content) VALUES ('delete', old.id, old.content);
+INSERT INTO search_index (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_documents_session_insert
+AFTER INSERT ON sessions
+BEGIN
+INSERT INTO search_documents (session_id, content) VALUES (new.id, new.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_documents_session_title
+AFTER UPDATE OF title ON sessions
+WHEN old.title <> new.title
+BEGIN
+UPDATE search_documents SET content = new.title
+WHERE session_id = new.id AND message_id IS NULL;
+END;
+
+-- Index the sessions and messages already there, the messages the same way
+-- the message service does: their text, and the input and output of their
+-- tool calls
+INSERT INTO search_documents (session_id, content)
+SELECT id, title FROM sessions;
+
+INSERT INTO search_documents (session_id, message_id, content)
+SELECT m.session_id, m.id, group_concat(
+    CASE json_extract(p.value, '$.type')
+        WHEN 'text' THEN json_extract(p.value, '$.data.text')
+        WHEN 'tool_call' THEN json_extract(p.value, '$.data.input')
+        WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+    END,
+    char(10)
+) AS content
+FROM messages m, json_each(m.parts) p
+WHERE json_valid(m.parts)
+GROUP BY m.id
+HAVING content <> '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS search_documents_session_title;
+DROP TRIGGER IF EXISTS search_documents_session_insert;
+DROP TRIGGER IF EXISTS search_documents_update;
+DROP TRIGGER IF EXISTS search_documents_delete;
+DROP TRIGGER IF EXISTS search_documents_insert;
+DROP TABLE IF EXISTS search_index;
+DROP TABLE IF EXISTS search_documents;
+-- +goose StatementEnd
@@ -8,6 +8,18 @@ import (
 	"database/sql"
 )
 
+type Checkpoint struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type CheckpointFile struct {
+	CheckpointID string `json:"checkpoint_id"`
+	FileID       string `json:"file_id"`
+}
+
 type File struct {
 	ID        string `json:"id"`
 	SessionID string `json:"session_id"`
@@ -31,15 +43,41 @@ type Message struct {
 	IsSummaryMessage int64          `json:"is_summary_message"`
 }
 
+type SearchDocument struct {
+	ID        int64          `json:"id"`
+	SessionID string         `json:"session_id"`
+	MessageID sql.NullString `json:"message_id"`
+	Content   string         `json:"content"`
+}
+
 type Session struct {
-	ID               string         `json:"id"`
-	ParentSessionID  sql.NullString `json:"parent_session_id"`
-	Title            string         `json:"title"`
-	MessageCount     int64          `json:"message_count"`
-	PromptTokens     int64          `json:"prompt_tokens"`
-	CompletionTokens int64          `json:"completion_tokens"`
-	Cost             float64        `json:"cost"`
-	UpdatedAt        int64          `json:"updated_at"`
-	CreatedAt        int64          `json:"created_at"`
-	SummaryMessageID sql.NullString `json:"summary_message_id"`
+	ID                   string         `json:"id"`
+	ParentSessionID      sql.NullString `json:"parent_session_id"`
+	Title                string         `json:"title"`
+	MessageCount         int64          `json:"message_count"`
+	PromptTokens         int64          `json:"prompt_tokens"`
+	CompletionTokens     int64          `json:"completion_tokens"`
+	Cost                 float64        `json:"cost"`
+	UpdatedAt            int64          `json:"updated_at"`
+	CreatedAt            int64          `json:"created_at"`
+	SummaryMessageID     sql.NullString `json:"summary_message_id"`
+	RetryCount           int64          `json:"retry_count"`
+	TotalTokens          int64          `json:"total_tokens"`
+	SummaryKeptMessageID sql.NullString `json:"summary_kept_message_id"`
+	ForkedFromMessageID  sql.NullString `json:"forked_from_message_id"`
+}
+
+type Usage struct {
+	ID                  string         `json:"id"`
+	SessionID           string         `json:"session_id"`
+	MessageID           sql.NullString `json:"message_id"`
+	Provider            string         `json:"provider"`
+	Model               string         `json:"model"`
+	InputTokens         int64          `json:"input_tokens"`
+	OutputTokens        int64          `json:"output_tokens"`
+	CacheReadTokens     int64          `json:"cache_read_tokens"`
+	CacheCreationTokens int64          `json:"cache_creation_tokens"`
+	Cost                float64        `json:"cost"`
+	LatencyMs           int64          `json:"latency_ms"`
+	CreatedAt           int64          `json:"created_at"`
 }
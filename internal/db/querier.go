@@ -9,26 +9,41 @@ import (
 )
 
 type Querier interface {
+	CopyCheckpoint(ctx context.Context, arg CopyCheckpointParams) error
+	CopyCheckpointFiles(ctx context.Context, arg CopyCheckpointFilesParams) error
+	CopySearchDocument(ctx context.Context, arg CopySearchDocumentParams) error
+	CreateCheckpoint(ctx context.Context, arg CreateCheckpointParams) (Checkpoint, error)
+	CreateCheckpointFiles(ctx context.Context, arg CreateCheckpointFilesParams) error
 	CreateFile(ctx context.Context, arg CreateFileParams) (File, error)
 	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateUsage(ctx context.Context, arg CreateUsageParams) error
 	DeleteFile(ctx context.Context, id string) error
 	DeleteMessage(ctx context.Context, id string) error
 	DeleteSession(ctx context.Context, id string) error
 	DeleteSessionFiles(ctx context.Context, sessionID string) error
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
+	GetCheckpointByMessage(ctx context.Context, messageID string) (Checkpoint, error)
 	GetFile(ctx context.Context, id string) (File, error)
 	GetFileByPathAndSession(ctx context.Context, arg GetFileByPathAndSessionParams) (File, error)
 	GetMessage(ctx context.Context, id string) (Message, error)
 	GetSessionByID(ctx context.Context, id string) (Session, error)
+	GetUsageSince(ctx context.Context, createdAt int64) (GetUsageSinceRow, error)
+	ListCheckpointFiles(ctx context.Context, checkpointID string) ([]File, error)
+	ListCheckpointsBySession(ctx context.Context, sessionID string) ([]Checkpoint, error)
 	ListFilesByPath(ctx context.Context, path string) ([]File, error)
 	ListFilesBySession(ctx context.Context, sessionID string) ([]File, error)
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
 	ListNewFiles(ctx context.Context) ([]File, error)
 	ListSessions(ctx context.Context) ([]Session, error)
+	// The usage per day, session, provider and model. The usage of the sub-agents
+	// and of the titles counts for the session they were run from.
+	ListUsage(ctx context.Context, arg ListUsageParams) ([]ListUsageRow, error)
+	SearchSessions(ctx context.Context, arg SearchSessionsParams) ([]SearchSessionsRow, error)
 	UpdateMessage(ctx context.Context, arg UpdateMessageParams) error
 	UpdateSession(ctx context.Context, arg UpdateSessionParams) (Session, error)
+	UpsertSearchDocument(ctx context.Context, arg UpsertSearchDocumentParams) error
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,58 @@
+-- name: CopyCheckpoint :exec
+INSERT INTO checkpoints (
+    id,
+    session_id,
+    message_id,
+    created_at
+) VALUES (
+    ?, ?, ?, ?
+);
+
+-- name: CopyCheckpointFiles :exec
+INSERT INTO checkpoint_files (checkpoint_id, file_id)
+SELECT sqlc.arg(checkpoint_id), f.id
+FROM checkpoint_files cf
+INNER JOIN files copied ON copied.id = cf.file_id
+INNER JOIN files f ON f.path = copied.path AND f.version = copied.version
+WHERE cf.checkpoint_id = sqlc.arg(from_checkpoint_id)
+AND f.session_id = sqlc.arg(session_id);
+
+-- name: CreateCheckpoint :one
+INSERT INTO checkpoints (
+    id,
+    session_id,
+    message_id,
+    created_at
+) VALUES (
+    ?, ?, ?, strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: CreateCheckpointFiles :exec
+INSERT INTO checkpoint_files (checkpoint_id, file_id)
+SELECT sqlc.arg(checkpoint_id), f.id
+FROM files f
+WHERE f.session_id = sqlc.arg(session_id)
+AND f.version = (
+    SELECT MAX(version)
+    FROM files
+    WHERE session_id = f.session_id AND path = f.path
+);
+
+-- name: GetCheckpointByMessage :one
+SELECT *
+FROM checkpoints
+WHERE message_id = ? LIMIT 1;
+
+-- name: ListCheckpointsBySession :many
+SELECT *
+FROM checkpoints
+WHERE session_id = ?
+ORDER BY created_at ASC, rowid ASC;
+
+-- name: ListCheckpointFiles :many
+SELECT f.*
+FROM files f
+INNER JOIN checkpoint_files cf ON cf.file_id = f.id
+WHERE cf.checkpoint_id = ?
+ORDER BY f.path;
@@ -0,0 +1,30 @@
+-- name: CopySearchDocument :exec
+INSERT INTO search_documents (session_id, message_id, content)
+SELECT sqlc.arg(session_id), sqlc.arg(message_id), content
+FROM search_documents
+WHERE search_documents.message_id = sqlc.arg(from_message_id);
+
+-- name: UpsertSearchDocument :exec
+INSERT INTO search_documents (
+    session_id,
+    message_id,
+    content
+) VALUES (
+    ?, ?, ?
+)
+ON CONFLICT (message_id) DO UPDATE SET content = excluded.content
+WHERE content <> excluded.content;
+
+-- name: SearchSessions :many
+SELECT
+    d.session_id,
+    CAST(COALESCE(d.message_id, '') AS TEXT) AS message_id,
+    s.title,
+    CAST(snippet(search_index, 0, char(2), char(3), '…', 16) AS TEXT) AS snippet
+FROM search_index
+JOIN search_documents d ON d.id = search_index.rowid
+JOIN sessions s ON s.id = d.session_id
+WHERE search_index MATCH sqlc.arg(query)
+AND (s.parent_session_id IS NULL OR s.forked_from_message_id IS NOT NULL)
+ORDER BY rank
+LIMIT ?;
@@ -0,0 +1,50 @@
+-- name: CreateUsage :exec
+INSERT INTO usage (
+    id,
+    session_id,
+    message_id,
+    provider,
+    model,
+    input_tokens,
+    output_tokens,
+    cache_read_tokens,
+    cache_creation_tokens,
+    cost,
+    latency_ms,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now')
+);
+
+-- name: GetUsageSince :one
+SELECT
+    CAST(COALESCE(SUM(cost), 0.0) AS REAL) AS cost,
+    CAST(COALESCE(SUM(input_tokens + output_tokens + cache_read_tokens + cache_creation_tokens), 0) AS INTEGER) AS total_tokens
+FROM usage
+WHERE created_at >= ?;
+
+-- name: ListUsage :many
+-- The usage per day, session, provider and model. The usage of the sub-agents
+-- and of the titles counts for the session they were run from.
+SELECT
+    CAST(date(u.created_at, 'unixepoch', 'localtime') AS TEXT) AS day,
+    CAST(COALESCE(root.id, u.session_id) AS TEXT) AS session_id,
+    CAST(COALESCE(root.title, '') AS TEXT) AS title,
+    u.provider,
+    u.model,
+    COUNT(*) AS requests,
+    CAST(SUM(u.input_tokens) AS INTEGER) AS input_tokens,
+    CAST(SUM(u.output_tokens) AS INTEGER) AS output_tokens,
+    CAST(SUM(u.cache_read_tokens) AS INTEGER) AS cache_read_tokens,
+    CAST(SUM(u.cache_creation_tokens) AS INTEGER) AS cache_creation_tokens,
+    CAST(SUM(u.cost) AS REAL) AS cost,
+    CAST(SUM(u.latency_ms) AS INTEGER) AS latency_ms
+FROM usage u
+LEFT JOIN sessions s ON s.id = u.session_id
+LEFT JOIN sessions root ON root.id = CASE
+    WHEN s.forked_from_message_id IS NULL THEN COALESCE(s.parent_session_id, s.id)
+    ELSE s.id
+END
+WHERE u.created_at >= sqlc.arg(since) AND u.created_at < sqlc.arg(until)
+GROUP BY day, COALESCE(root.id, u.session_id), u.provider, u.model
+ORDER BY day, session_id, u.provider, u.model;
@@ -23,6 +23,9 @@ type model struct {
 	anim   *anim.Anim
 }
 
+// labelMsg changes the label of the spinner.
+type labelMsg string
+
 func (m model) Init() tea.Cmd  { return m.anim.Init() }
 func (m model) View() tea.View { return tea.NewView(m.anim.View()) }
 
@@ -35,6 +38,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.cancel()
 			return m, tea.Quit
 		}
+	case labelMsg:
+		m.anim.SetLabel(string(msg))
+		return m, nil
 	}
 	mm, cmd := m.anim.Update(msg)
 	m.anim = mm.(*anim.Anim)
@@ -81,6 +87,11 @@ func (s *Spinner) Start() {
 	}()
 }
 
+// SetLabel changes the message shown next to the spinner
+func (s *Spinner) SetLabel(label string) {
+	s.prog.Send(labelMsg(label))
+}
+
 // Stop ends the spinner animation
 func (s *Spinner) Stop() {
 	s.prog.Quit()
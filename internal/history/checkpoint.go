@@ -0,0 +1,179 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/google/uuid"
+)
+
+// ErrNoCheckpoint is returned when rewinding to a message without a
+// checkpoint, e.g. one sent before checkpoints were recorded.
+var ErrNoCheckpoint = errors.New("no checkpoint for this message")
+
+// Checkpoint records the files of a session when a user message was sent, so
+// the session can be rewound to that point.
+type Checkpoint struct {
+	ID        string
+	SessionID string
+	MessageID string
+	CreatedAt int64
+}
+
+// FileChange is a change made to a file on disk when rewinding to a
+// checkpoint.
+type FileChange struct {
+	Path string
+	// Content is the content the file is restored to.
+	Content string
+	// Deleted is set when the file didn't exist at the checkpoint and is
+	// removed.
+	Deleted bool
+}
+
+func (s *service) CreateCheckpoint(ctx context.Context, sessionID, messageID string) (Checkpoint, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	qtx := s.q.WithTx(tx)
+	dbCheckpoint, err := qtx.CreateCheckpoint(ctx, db.CreateCheckpointParams{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		MessageID: messageID,
+	})
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	if err := qtx.CreateCheckpointFiles(ctx, db.CreateCheckpointFilesParams{
+		CheckpointID: dbCheckpoint.ID,
+		SessionID:    sessionID,
+	}); err != nil {
+		return Checkpoint{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return checkpointFromDBItem(dbCheckpoint), nil
+}
+
+func (s *service) ListCheckpoints(ctx context.Context, sessionID string) ([]Checkpoint, error) {
+	dbCheckpoints, err := s.q.ListCheckpointsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make([]Checkpoint, len(dbCheckpoints))
+	for i, dbCheckpoint := range dbCheckpoints {
+		checkpoints[i] = checkpointFromDBItem(dbCheckpoint)
+	}
+	return checkpoints, nil
+}
+
+// RewindChanges returns the changes rewinding the files of the session to the
+// checkpoint of the given message would make on disk.
+func (s *service) RewindChanges(ctx context.Context, sessionID, messageID string) ([]FileChange, error) {
+	checkpoint, err := s.q.GetCheckpointByMessage(ctx, messageID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && checkpoint.SessionID != sessionID) {
+		return nil, ErrNoCheckpoint
+	}
+	if err != nil {
+		return nil, err
+	}
+	atCheckpoint, err := s.q.ListCheckpointFiles(ctx, checkpoint.ID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.ListBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// restore the versions at the checkpoint, and the versions before the
+	// session first changed them for the files changed afterwards
+	targets := make(map[string]File)
+	for _, file := range atCheckpoint {
+		targets[file.Path] = s.fromDBItem(file)
+	}
+	initial := make(map[string]File)
+	var paths []string
+	for _, file := range files {
+		// the files are sorted by version, the first one is the initial one
+		if _, ok := initial[file.Path]; ok {
+			continue
+		}
+		initial[file.Path] = file
+		paths = append(paths, file.Path)
+		if _, ok := targets[file.Path]; !ok {
+			targets[file.Path] = file
+		}
+	}
+	slices.Sort(paths)
+
+	var changes []FileChange
+	for _, path := range paths {
+		target := targets[path]
+		// the edit tools record new files with an empty initial version
+		deleted := target.Content == "" && target.ID == initial[path].ID
+		current, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			if deleted {
+				continue
+			}
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		case !deleted && string(current) == target.Content:
+			continue
+		}
+		changes = append(changes, FileChange{
+			Path:    path,
+			Content: target.Content,
+			Deleted: deleted,
+		})
+	}
+	return changes, nil
+}
+
+// Rewind restores the files of the session on disk to the checkpoint of the
+// given message, and records the restored content as new versions.
+func (s *service) Rewind(ctx context.Context, sessionID, messageID string) ([]FileChange, error) {
+	changes, err := s.RewindChanges(ctx, sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		if change.Deleted {
+			if err := os.Remove(change.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to remove %s: %w", change.Path, err)
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(change.Path), 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create parent directories: %w", err)
+			}
+			if err := os.WriteFile(change.Path, []byte(change.Content), 0o644); err != nil {
+				return nil, fmt.Errorf("failed to restore %s: %w", change.Path, err)
+			}
+		}
+		if _, err := s.CreateVersion(ctx, sessionID, change.Path, change.Content); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+func checkpointFromDBItem(item db.Checkpoint) Checkpoint {
+	return Checkpoint{
+		ID:        item.ID,
+		SessionID: item.SessionID,
+		MessageID: item.MessageID,
+		CreatedAt: item.CreatedAt,
+	}
+}
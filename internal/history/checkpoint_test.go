@@ -0,0 +1,48 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCheckpoints(t *testing.T) {
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+
+	sess, err := session.NewService(q).Create(ctx, "Session")
+	require.NoError(t, err)
+	messages := message.NewService(q)
+	var ids []string
+	for _, prompt := range []string{"First", "Second", "Third"} {
+		msg, err := messages.Create(ctx, sess.ID, message.CreateMessageParams{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: prompt}},
+		})
+		require.NoError(t, err)
+		ids = append(ids, msg.ID)
+	}
+
+	// the checkpoints of queued prompts are created in the same second, they
+	// keep the order they were created in
+	for i, id := range []string{"c", "b", "a"} {
+		require.NoError(t, q.CopyCheckpoint(ctx, db.CopyCheckpointParams{
+			ID:        id,
+			SessionID: sess.ID,
+			MessageID: ids[i],
+			CreatedAt: 1_700_000_000,
+		}))
+	}
+	checkpoints, err := NewService(q, conn).ListCheckpoints(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 3)
+	for i, checkpoint := range checkpoints {
+		require.Equal(t, ids[i], checkpoint.MessageID)
+	}
+}
@@ -35,6 +35,11 @@ type Service interface {
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionFiles(ctx context.Context, sessionID string) error
+
+	CreateCheckpoint(ctx context.Context, sessionID, messageID string) (Checkpoint, error)
+	ListCheckpoints(ctx context.Context, sessionID string) ([]Checkpoint, error)
+	RewindChanges(ctx context.Context, sessionID, messageID string) ([]FileChange, error)
+	Rewind(ctx context.Context, sessionID, messageID string) ([]FileChange, error)
 }
 
 type service struct {
@@ -0,0 +1,138 @@
+// Package hooks runs the shell commands configured to run around the tool
+// calls of the agents and at the points of the life of the sessions.
+package hooks
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/shell"
+	"mvdan.cc/sh/v3/interp"
+)
+
+const (
+	PreToolUse  = "pre_tool_use"
+	PostToolUse = "post_tool_use"
+
+	// BlockExitCode is the exit status of a pre tool use hook blocking the
+	// tool call.
+	BlockExitCode = 2
+
+	defaultTimeout = 60 * time.Second
+)
+
+// Input is the JSON written to the standard input of the hooks.
+type Input struct {
+	Event        string          `json:"event"`
+	SessionID    string          `json:"session_id"`
+	ToolCallID   string          `json:"tool_call_id,omitempty"`
+	ToolName     string          `json:"tool_name,omitempty"`
+	ToolInput    json.RawMessage `json:"tool_input,omitempty"`
+	ToolResponse *ToolResponse   `json:"tool_response,omitempty"`
+	Prompt       string          `json:"prompt,omitempty"`
+	Permission   *Permission     `json:"permission,omitempty"`
+	// TurnEndHookActive is set when the turn was started by the feedback of
+	// a turn end hook, to avoid sending feedback forever.
+	TurnEndHookActive bool `json:"turn_end_hook_active,omitempty"`
+}
+
+// ToolResponse is the result of the tool call given to the post tool use
+// hooks.
+type ToolResponse struct {
+	Content string `json:"content"`
+	IsError bool   `json:"is_error"`
+}
+
+// Result is the outcome of a hook command.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Blocked reports whether the hook blocked the tool call.
+func (r Result) Blocked() bool {
+	return r.ExitCode == BlockExitCode
+}
+
+// Output returns the output of the command, standard output first.
+func (r Result) Output() string {
+	var parts []string
+	for _, out := range []string{r.Stdout, r.Stderr} {
+		if out = strings.TrimSpace(out); out != "" {
+			parts = append(parts, out)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Reason returns the reason given by a hook blocking the tool call, its
+// standard error or else its standard output.
+func (r Result) Reason() string {
+	return cmp.Or(strings.TrimSpace(r.Stderr), strings.TrimSpace(r.Stdout), "no reason given")
+}
+
+// Run runs the command of the hook in the working directory with the input
+// on its standard input. A command exiting with a non-zero status isn't an
+// error, its exit code is part of the result.
+func Run(ctx context.Context, workingDir, command string, timeout int, input Input) (Result, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return Result{}, err
+	}
+
+	limit := cmp.Or(time.Duration(timeout)*time.Second, defaultTimeout)
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	sh := shell.NewShell(&shell.Options{WorkingDir: workingDir})
+	stdout, stderr, err := sh.ExecStdin(ctx, command, bytes.NewReader(data))
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return Result{}, fmt.Errorf("hook %q timed out after %s", command, limit)
+	}
+	var status interp.ExitStatus
+	if err != nil && !errors.As(err, &status) {
+		return Result{}, fmt.Errorf("failed to run hook %q: %w", command, err)
+	}
+	return Result{
+		Stdout:   stdout,
+		Stderr:   stderr,
+		ExitCode: int(status),
+	}, nil
+}
+
+// MatchTool reports whether the hook runs for the call of the tool with the
+// given JSON parameters.
+func MatchTool(hook config.ToolHook, toolName, params string) bool {
+	if len(hook.Tools) > 0 {
+		matched := false
+		for _, pattern := range hook.Tools {
+			if ok, _ := filepath.Match(pattern, toolName); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if hook.Pattern == "" {
+		return true
+	}
+	re, err := regexp.Compile(hook.Pattern)
+	if err != nil {
+		slog.Warn("Invalid hook pattern", "pattern", hook.Pattern, "error", err)
+		return false
+	}
+	return re.MatchString(params)
+}
@@ -0,0 +1,62 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	input := Input{
+		Event:     PreToolUse,
+		SessionID: "session",
+		ToolName:  "bash",
+		ToolInput: []byte(`{"command":"rm -rf /"}`),
+	}
+
+	t.Run("reads the input", func(t *testing.T) {
+		result, err := Run(t.Context(), t.TempDir(), "cat", 0, input)
+		require.NoError(t, err)
+		require.Equal(t, 0, result.ExitCode)
+		require.JSONEq(t, `{"event":"pre_tool_use","session_id":"session","tool_name":"bash","tool_input":{"command":"rm -rf /"}}`, result.Stdout)
+	})
+
+	t.Run("blocks", func(t *testing.T) {
+		result, err := Run(t.Context(), t.TempDir(), "echo 'not allowed' >&2; exit 2", 0, input)
+		require.NoError(t, err)
+		require.True(t, result.Blocked())
+		require.Equal(t, "not allowed", result.Reason())
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		result, err := Run(t.Context(), t.TempDir(), "echo out; echo err >&2; exit 1", 0, input)
+		require.NoError(t, err)
+		require.False(t, result.Blocked())
+		require.Equal(t, 1, result.ExitCode)
+		require.Equal(t, "out\nerr", result.Output())
+	})
+}
+
+func TestMatchTool(t *testing.T) {
+	tests := []struct {
+		name   string
+		hook   config.ToolHook
+		tool   string
+		params string
+		want   bool
+	}{
+		{"all tools", config.ToolHook{}, "edit", `{}`, true},
+		{"tool name", config.ToolHook{Tools: []string{"edit", "write"}}, "write", `{}`, true},
+		{"other tool", config.ToolHook{Tools: []string{"edit"}}, "bash", `{}`, false},
+		{"glob", config.ToolHook{Tools: []string{"mcp_*"}}, "mcp_github_search", `{}`, true},
+		{"pattern", config.ToolHook{Tools: []string{"edit"}, Pattern: `\.go"`}, "edit", `{"file_path":"main.go"}`, true},
+		{"pattern mismatch", config.ToolHook{Pattern: `\.go"`}, "edit", `{"file_path":"README.md"}`, false},
+		{"invalid pattern", config.ToolHook{Pattern: `(`}, "edit", `{}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, MatchTool(tt.hook, tt.tool, tt.params))
+		})
+	}
+}
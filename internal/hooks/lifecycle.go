@@ -0,0 +1,169 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+const (
+	SessionStart      = "session_start"
+	PromptSubmit      = "prompt_submit"
+	TurnEnd           = "turn_end"
+	PermissionRequest = "permission_request"
+)
+
+// ErrPromptBlocked is returned when a prompt submit hook blocks the prompt.
+var ErrPromptBlocked = errors.New("prompt blocked by a hook")
+
+// Permission is the pending permission request given to the permission
+// request hooks.
+type Permission struct {
+	ToolName    string `json:"tool_name"`
+	Action      string `json:"action"`
+	Path        string `json:"path"`
+	Description string `json:"description"`
+}
+
+// Runner runs the hooks configured for the points of the life of the
+// sessions. A nil Runner runs nothing.
+type Runner struct {
+	workingDir string
+	cfg        config.Hooks
+}
+
+func NewRunner(workingDir string, cfg config.Hooks) *Runner {
+	return &Runner{
+		workingDir: workingDir,
+		cfg:        cfg,
+	}
+}
+
+// SessionStart runs the session start hooks and returns the context they
+// printed, to add to the first prompt of the session.
+func (r *Runner) SessionStart(ctx context.Context, sessionID, prompt string) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	var contexts []string
+	for _, hook := range r.cfg.SessionStart {
+		result, err := r.run(ctx, SessionStart, hook, Input{
+			Event:     SessionStart,
+			SessionID: sessionID,
+			Prompt:    prompt,
+		})
+		if err != nil {
+			return "", err
+		}
+		if out := strings.TrimSpace(result.Stdout); out != "" {
+			contexts = append(contexts, out)
+		}
+	}
+	return strings.Join(contexts, "\n\n"), nil
+}
+
+// PromptSubmit runs the prompt submit hooks and returns the prompt to send,
+// rewritten or with the context the hooks printed. It returns an error
+// wrapping ErrPromptBlocked when a hook blocks the prompt.
+func (r *Runner) PromptSubmit(ctx context.Context, sessionID, prompt string) (string, error) {
+	if r == nil {
+		return prompt, nil
+	}
+	var contexts []string
+	for _, hook := range r.cfg.PromptSubmit {
+		result, err := r.run(ctx, PromptSubmit, hook, Input{
+			Event:     PromptSubmit,
+			SessionID: sessionID,
+			Prompt:    prompt,
+		})
+		if err != nil {
+			return "", err
+		}
+		if result.Blocked() {
+			return "", fmt.Errorf("%w: %s", ErrPromptBlocked, result.Reason())
+		}
+		out := strings.TrimSpace(result.Stdout)
+		if out == "" {
+			continue
+		}
+		var rewrite struct {
+			Prompt *string `json:"prompt"`
+		}
+		if err := json.Unmarshal([]byte(out), &rewrite); err == nil && rewrite.Prompt != nil {
+			prompt = *rewrite.Prompt
+			continue
+		}
+		contexts = append(contexts, out)
+	}
+	return AddContext(prompt, strings.Join(contexts, "\n\n")), nil
+}
+
+// TurnEnd runs the turn end hooks and returns the feedback of the ones
+// exiting with status 2, to send back to the agent. active is set when the
+// turn was started by the feedback of a turn end hook.
+func (r *Runner) TurnEnd(ctx context.Context, sessionID string, active bool) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	var (
+		feedback []string
+		errs     []error
+	)
+	for _, hook := range r.cfg.TurnEnd {
+		result, err := r.run(ctx, TurnEnd, hook, Input{
+			Event:             TurnEnd,
+			SessionID:         sessionID,
+			TurnEndHookActive: active,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if result.Blocked() {
+			feedback = append(feedback, result.Output())
+		}
+	}
+	return strings.Join(feedback, "\n\n"), errors.Join(errs...)
+}
+
+// PermissionRequest runs the permission request hooks.
+func (r *Runner) PermissionRequest(ctx context.Context, sessionID string, permission Permission) error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	for _, hook := range r.cfg.PermissionRequest {
+		_, err := r.run(ctx, PermissionRequest, hook, Input{
+			Event:      PermissionRequest,
+			SessionID:  sessionID,
+			Permission: &permission,
+		})
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// run runs the hook, a command exiting with a status other than 0, or 2 to
+// block, is an error.
+func (r *Runner) run(ctx context.Context, event string, hook config.Hook, input Input) (Result, error) {
+	result, err := Run(ctx, r.workingDir, hook.Command, hook.Timeout, input)
+	if err != nil {
+		return Result{}, fmt.Errorf("%s hook: %w", event, err)
+	}
+	if result.ExitCode != 0 && !result.Blocked() {
+		return Result{}, fmt.Errorf("%s hook %q exited with status %d: %s", event, hook.Command, result.ExitCode, result.Output())
+	}
+	return result, nil
+}
+
+// AddContext adds the context printed by the hooks to the prompt.
+func AddContext(prompt, context string) string {
+	if context == "" {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n\n<hook_context>\n%s\n</hook_context>", prompt, context)
+}
@@ -0,0 +1,83 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner(t *testing.T) {
+	newRunner := func(cfg config.Hooks) *Runner {
+		return NewRunner(t.TempDir(), cfg)
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var r *Runner
+		prompt, err := r.PromptSubmit(t.Context(), "session", "hello")
+		require.NoError(t, err)
+		require.Equal(t, "hello", prompt)
+	})
+
+	t.Run("session start context", func(t *testing.T) {
+		r := newRunner(config.Hooks{SessionStart: []config.Hook{
+			{Command: "echo 'branch: main'"},
+			{Command: "true"},
+		}})
+		context, err := r.SessionStart(t.Context(), "session", "hello")
+		require.NoError(t, err)
+		require.Equal(t, "branch: main", context)
+	})
+
+	t.Run("prompt submit context", func(t *testing.T) {
+		r := newRunner(config.Hooks{PromptSubmit: []config.Hook{{Command: "echo 'ticket #42'"}}})
+		prompt, err := r.PromptSubmit(t.Context(), "session", "hello")
+		require.NoError(t, err)
+		require.Equal(t, "hello\n\n<hook_context>\nticket #42\n</hook_context>", prompt)
+	})
+
+	t.Run("prompt submit rewrite", func(t *testing.T) {
+		r := newRunner(config.Hooks{PromptSubmit: []config.Hook{{Command: `echo '{"prompt":"bonjour"}'`}}})
+		prompt, err := r.PromptSubmit(t.Context(), "session", "hello")
+		require.NoError(t, err)
+		require.Equal(t, "bonjour", prompt)
+	})
+
+	t.Run("prompt submit block", func(t *testing.T) {
+		r := newRunner(config.Hooks{PromptSubmit: []config.Hook{{Command: "echo 'no secrets' >&2; exit 2"}}})
+		_, err := r.PromptSubmit(t.Context(), "session", "hello")
+		require.ErrorIs(t, err, ErrPromptBlocked)
+		require.ErrorContains(t, err, "no secrets")
+	})
+
+	t.Run("turn end feedback", func(t *testing.T) {
+		r := newRunner(config.Hooks{TurnEnd: []config.Hook{
+			{Command: "echo 'tests fail' >&2; exit 2"},
+			{Command: "true"},
+		}})
+		feedback, err := r.TurnEnd(t.Context(), "session", false)
+		require.NoError(t, err)
+		require.Equal(t, "tests fail", feedback)
+	})
+
+	t.Run("turn end active", func(t *testing.T) {
+		r := newRunner(config.Hooks{TurnEnd: []config.Hook{
+			{Command: `grep -q '"turn_end_hook_active":true' && exit 0; exit 2`},
+		}})
+		feedback, err := r.TurnEnd(t.Context(), "session", true)
+		require.NoError(t, err)
+		require.Empty(t, feedback)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		r := newRunner(config.Hooks{PermissionRequest: []config.Hook{{Command: "echo oops >&2; exit 1"}}})
+		err := r.PermissionRequest(t.Context(), "session", Permission{ToolName: "bash"})
+		require.EqualError(t, err, `permission_request hook "echo oops >&2; exit 1" exited with status 1: oops`)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		r := newRunner(config.Hooks{SessionStart: []config.Hook{{Command: "sleep 5", Timeout: 1}}})
+		_, err := r.SessionStart(t.Context(), "session", "hello")
+		require.ErrorContains(t, err, "timed out after 1s")
+	})
+}
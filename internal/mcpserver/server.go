@@ -0,0 +1,251 @@
+// Package mcpserver serves the built-in tools of the agent over MCP, for
+// other agents to use them.
+package mcpserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/version"
+	"github.com/google/uuid"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Policy is what happens to the tool calls needing a permission that isn't
+// granted in the configuration.
+type Policy string
+
+const (
+	// PolicyDeny denies them.
+	PolicyDeny Policy = "deny"
+	// PolicyAsk asks the user of the client, through an elicitation, and
+	// denies them when the client can't ask.
+	PolicyAsk Policy = "ask"
+)
+
+// readOnlyTools are the tools not changing anything.
+var readOnlyTools = []string{
+	tools.DiagnosticsToolName,
+	tools.GlobToolName,
+	tools.GrepToolName,
+	tools.LSToolName,
+	tools.ReferencesToolName,
+	tools.SourcegraphToolName,
+	tools.ViewToolName,
+}
+
+// Server serves the tools over MCP.
+type Server struct {
+	server      *mcp.Server
+	permissions permission.Service
+	policy      Policy
+	// sessionID is the session the changes of the files are recorded in.
+	sessionID string
+	// calls are the MCP sessions of the tool calls in progress by tool call
+	// ID, for their clients to be asked for the permissions.
+	calls *csync.Map[string, *mcp.ServerSession]
+}
+
+// New returns a server for the tools, recording the changes of the files in
+// the session.
+func New(agentTools []fantasy.AgentTool, permissions permission.Service, sessionID string, policy Policy) *Server {
+	s := &Server{
+		server:      mcp.NewServer(&mcp.Implementation{Name: "crush", Version: version.Version}, nil),
+		permissions: permissions,
+		policy:      policy,
+		sessionID:   sessionID,
+		calls:       csync.NewMap[string, *mcp.ServerSession](),
+	}
+	if policy == PolicyDeny {
+		permissions.SetDenyRequests(true)
+	}
+	for _, tool := range agentTools {
+		info := tool.Info()
+		schema := map[string]any{
+			"type":       "object",
+			"properties": info.Parameters,
+		}
+		if len(info.Required) > 0 {
+			schema["required"] = info.Required
+		}
+		s.server.AddTool(&mcp.Tool{
+			Name:        info.Name,
+			Description: info.Description,
+			InputSchema: schema,
+			Annotations: &mcp.ToolAnnotations{ReadOnlyHint: slices.Contains(readOnlyTools, info.Name)},
+		}, s.handler(tool))
+	}
+	return s
+}
+
+// Run serves the tools on the transport, such as the standard input and
+// output, until the client goes away or ctx is done.
+func (s *Server) Run(ctx context.Context, transport mcp.Transport) error {
+	s.askPermissions(ctx)
+	return s.server.Run(ctx, transport)
+}
+
+// Handler returns the handler of the streamable HTTP transport, the
+// permissions are asked until ctx is done. The clients must send the token,
+// and the requests for another host than localhost or host, or coming from
+// the web pages of another host, are rejected against DNS rebinding.
+func (s *Server) Handler(ctx context.Context, token, host string) http.Handler {
+	s.askPermissions(ctx)
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+		return s.server
+	}, nil)
+	hosts := append(slices.Clone(localHosts), host)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedHost(r, hosts) {
+			http.Error(w, "forbidden host or origin", http.StatusForbidden)
+			return
+		}
+		bearer, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// localHosts are the names of the local host.
+var localHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// allowedHost reports whether the Host header of the request, and its Origin
+// header when it has one, are among the hosts.
+func allowedHost(r *http.Request, hosts []string) bool {
+	if !slices.Contains(hosts, hostname(r.Host)) {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return slices.Contains(hosts, u.Hostname())
+}
+
+// hostname returns the host without its port and brackets.
+func hostname(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return strings.Trim(host, "[]")
+}
+
+func (s *Server) handler(tool fantasy.AgentTool) mcp.ToolHandler {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		call := fantasy.ToolCall{
+			ID:    uuid.NewString(),
+			Name:  req.Params.Name,
+			Input: string(req.Params.Arguments),
+		}
+		if call.Input == "" {
+			call.Input = "{}"
+		}
+		s.calls.Set(call.ID, req.Session)
+		defer s.calls.Del(call.ID)
+
+		ctx = context.WithValue(ctx, tools.SessionIDContextKey, s.sessionID)
+		response, err := tool.Run(ctx, call)
+		if errors.Is(err, permission.ErrorPermissionDenied) {
+			return errorResult(fmt.Sprintf("Permission denied to run %s, allow it in permissions.allowed_tools of the Crush configuration", call.Name)), nil
+		}
+		if err != nil {
+			return errorResult(err.Error()), nil
+		}
+		return toolResult(response), nil
+	}
+}
+
+func errorResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+		IsError: true,
+	}
+}
+
+func toolResult(response fantasy.ToolResponse) *mcp.CallToolResult {
+	media, ok := tools.MediaFromResponse(response)
+	if !ok {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{&mcp.TextContent{Text: response.Content}},
+			IsError: response.IsError,
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(media.Data)
+	if err != nil {
+		return errorResult(fmt.Sprintf("invalid media: %v", err))
+	}
+	content := []mcp.Content{&mcp.TextContent{Text: media.Text}}
+	if media.IsImage() {
+		content = append(content, &mcp.ImageContent{Data: data, MIMEType: media.MIMEType})
+	} else {
+		content = append(content, &mcp.AudioContent{Data: data, MIMEType: media.MIMEType})
+	}
+	return &mcp.CallToolResult{Content: content}
+}
+
+// askPermissions starts asking the clients for the permissions the tool
+// calls need, until ctx is done.
+func (s *Server) askPermissions(ctx context.Context) {
+	if s.policy != PolicyAsk {
+		return
+	}
+	requests := s.permissions.Subscribe(ctx)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-requests:
+				if !ok {
+					return
+				}
+				go s.ask(ctx, event.Payload)
+			}
+		}
+	}()
+}
+
+func (s *Server) ask(ctx context.Context, req permission.PermissionRequest) {
+	session, ok := s.calls.Get(req.ToolCallID)
+	if !ok || session.InitializeParams() == nil || session.InitializeParams().Capabilities.Elicitation == nil {
+		slog.Warn("Denying permission, the client can't ask for it", "tool", req.ToolName)
+		s.permissions.Deny(req)
+		return
+	}
+	result, err := session.Elicit(ctx, &mcp.ElicitParams{
+		Message: fmt.Sprintf("Allow %s? %s", req.ToolName, req.Description),
+		RequestedSchema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{},
+		},
+	})
+	switch {
+	case err != nil:
+		slog.Error("Failed to ask for permission", "tool", req.ToolName, "error", err)
+		s.permissions.Deny(req)
+	case result.Action == "accept":
+		s.permissions.Grant(req)
+	default:
+		s.permissions.Deny(req)
+	}
+}
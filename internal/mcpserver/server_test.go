@@ -0,0 +1,186 @@
+package mcpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+type env struct {
+	dir       string
+	history   history.Service
+	sessionID string
+}
+
+// connect serves the view and write tools of a temporary directory and
+// returns a client of the server, answering the elicitations with action.
+func connect(t *testing.T, policy Policy, action string) (*mcp.ClientSession, env) {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+
+	dir := t.TempDir()
+	sess, err := session.NewService(q).Create(t.Context(), "MCP Server")
+	require.NoError(t, err)
+	files := history.NewService(q, conn)
+	permissions := permission.NewPermissionService(dir, false, nil)
+	lspClients := csync.NewMap[string, *lsp.Client]()
+	srv := New([]fantasy.AgentTool{
+		tools.NewViewTool(lspClients, permissions, dir, nil),
+		tools.NewWriteTool(lspClients, permissions, files, dir, nil),
+	}, permissions, sess.ID, policy)
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	go srv.Run(t.Context(), serverTransport)
+
+	var opts *mcp.ClientOptions
+	if action != "" {
+		opts = &mcp.ClientOptions{
+			ElicitationHandler: func(context.Context, *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+				return &mcp.ElicitResult{Action: action}, nil
+			},
+		}
+	}
+	client, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, opts).Connect(t.Context(), clientTransport, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client, env{dir: dir, history: files, sessionID: sess.ID}
+}
+
+func text(t *testing.T, result *mcp.CallToolResult) string {
+	t.Helper()
+	require.Len(t, result.Content, 1)
+	content, ok := result.Content[0].(*mcp.TextContent)
+	require.True(t, ok)
+	return content.Text
+}
+
+func TestListTools(t *testing.T) {
+	client, _ := connect(t, PolicyDeny, "")
+
+	result, err := client.ListTools(t.Context(), nil)
+	require.NoError(t, err)
+	require.Len(t, result.Tools, 2)
+	require.Equal(t, tools.ViewToolName, result.Tools[0].Name)
+	require.True(t, result.Tools[0].Annotations.ReadOnlyHint)
+	require.Equal(t, tools.WriteToolName, result.Tools[1].Name)
+	require.False(t, result.Tools[1].Annotations.ReadOnlyHint)
+}
+
+func TestCallTool(t *testing.T) {
+	t.Run("deny", func(t *testing.T) {
+		client, env := connect(t, PolicyDeny, "")
+		require.NoError(t, os.WriteFile(filepath.Join(env.dir, "main.go"), []byte("package main\n"), 0o644))
+
+		result, err := client.CallTool(t.Context(), &mcp.CallToolParams{Name: tools.ViewToolName, Arguments: map[string]any{"file_path": "main.go"}})
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+		require.Contains(t, text(t, result), "package main")
+
+		result, err = client.CallTool(t.Context(), &mcp.CallToolParams{Name: tools.WriteToolName, Arguments: map[string]any{"file_path": "app.go", "content": "package app\n"}})
+		require.NoError(t, err)
+		require.True(t, result.IsError)
+		require.Contains(t, text(t, result), "Permission denied to run write")
+		require.NoFileExists(t, filepath.Join(env.dir, "app.go"))
+	})
+
+	t.Run("ask without elicitation", func(t *testing.T) {
+		client, env := connect(t, PolicyAsk, "")
+
+		result, err := client.CallTool(t.Context(), &mcp.CallToolParams{Name: tools.WriteToolName, Arguments: map[string]any{"file_path": "app.go", "content": "package app\n"}})
+		require.NoError(t, err)
+		require.True(t, result.IsError)
+		require.NoFileExists(t, filepath.Join(env.dir, "app.go"))
+	})
+
+	t.Run("ask and decline", func(t *testing.T) {
+		client, env := connect(t, PolicyAsk, "decline")
+
+		result, err := client.CallTool(t.Context(), &mcp.CallToolParams{Name: tools.WriteToolName, Arguments: map[string]any{"file_path": "app.go", "content": "package app\n"}})
+		require.NoError(t, err)
+		require.True(t, result.IsError)
+		require.NoFileExists(t, filepath.Join(env.dir, "app.go"))
+	})
+
+	t.Run("ask and accept", func(t *testing.T) {
+		client, env := connect(t, PolicyAsk, "accept")
+
+		result, err := client.CallTool(t.Context(), &mcp.CallToolParams{Name: tools.WriteToolName, Arguments: map[string]any{"file_path": "app.go", "content": "package app\n"}})
+		require.NoError(t, err)
+		require.False(t, result.IsError, text(t, result))
+		require.FileExists(t, filepath.Join(env.dir, "app.go"))
+
+		files, err := env.history.ListBySession(t.Context(), env.sessionID)
+		require.NoError(t, err)
+		require.NotEmpty(t, files)
+		require.Equal(t, filepath.Join(env.dir, "app.go"), files[0].Path)
+	})
+}
+
+// bearer adds the token to the requests.
+type bearer string
+
+func (b bearer) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+string(b))
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestHandler(t *testing.T) {
+	permissions := permission.NewPermissionService(t.TempDir(), false, nil)
+	srv := New([]fantasy.AgentTool{
+		tools.NewViewTool(csync.NewMap[string, *lsp.Client](), permissions, t.TempDir(), nil),
+	}, permissions, "session", PolicyDeny)
+	httpServer := httptest.NewServer(srv.Handler(t.Context(), "secret", "127.0.0.1"))
+	t.Cleanup(httpServer.Close)
+
+	post := func(token, host, origin string) int {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, httpServer.URL, strings.NewReader("{}"))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if host != "" {
+			req.Host = host
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusUnauthorized, post("", "", ""))
+	require.Equal(t, http.StatusUnauthorized, post("wrong", "", ""))
+	require.Equal(t, http.StatusForbidden, post("secret", "", "http://evil.example"))
+	require.Equal(t, http.StatusForbidden, post("secret", "", "null"))
+	require.Equal(t, http.StatusForbidden, post("secret", "evil.example:8788", ""))
+
+	client, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil).Connect(t.Context(), &mcp.StreamableClientTransport{
+		Endpoint:   httpServer.URL,
+		HTTPClient: &http.Client{Transport: bearer("secret")},
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	result, err := client.ListTools(t.Context(), nil)
+	require.NoError(t, err)
+	require.Len(t, result.Tools, 1)
+}
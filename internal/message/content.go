@@ -3,6 +3,7 @@ package message
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"slices"
 	"strings"
 	"time"
@@ -32,6 +33,8 @@ const (
 	FinishReasonCanceled         FinishReason = "canceled"
 	FinishReasonError            FinishReason = "error"
 	FinishReasonPermissionDenied FinishReason = "permission_denied"
+	FinishReasonBudgetExceeded   FinishReason = "budget_exceeded"
+	FinishReasonMaxTurns         FinishReason = "max_turns"
 
 	// Should never happen
 	FinishReasonUnknown FinishReason = "unknown"
@@ -110,10 +113,24 @@ type ToolResult struct {
 	MIMEType   string `json:"mime_type"`
 	Metadata   string `json:"metadata"`
 	IsError    bool   `json:"is_error"`
+	// Pruned results are replaced with a stub when sent to the model, to
+	// save context.
+	Pruned bool `json:"pruned,omitempty"`
 }
 
 func (ToolResult) isPart() {}
 
+// Size returns the size of the result content, in bytes.
+func (r ToolResult) Size() int {
+	return len(r.Content) + len(r.Data)
+}
+
+// PrunedContent returns the stub sent to the model in place of a pruned
+// result.
+func (r ToolResult) PrunedContent() string {
+	return fmt.Sprintf("[The result (%d bytes) was pruned to save context, run the tool again if you need it]", r.Size())
+}
+
 type Finish struct {
 	Reason  FinishReason `json:"reason"`
 	Time    int64        `json:"time"`
@@ -484,7 +501,11 @@ func (m *Message) ToAIMessage() []fantasy.Message {
 		var parts []fantasy.MessagePart
 		for _, result := range m.ToolResults() {
 			var content fantasy.ToolResultOutputContent
-			if result.IsError {
+			if result.Pruned {
+				content = fantasy.ToolResultOutputContentText{
+					Text: result.PrunedContent(),
+				}
+			} else if result.IsError {
 				content = fantasy.ToolResultOutputContentError{
 					Error: errors.New(result.Content),
 				}
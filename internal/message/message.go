@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/crush/internal/db"
@@ -20,6 +22,29 @@ type CreateMessageParams struct {
 	IsSummaryMessage bool
 }
 
+// RetryEvent is published when a request to the provider failed with a
+// retryable error and is going to be retried.
+type RetryEvent struct {
+	SessionID  string        `json:"session_id"`
+	MessageID  string        `json:"message_id,omitempty"`
+	Provider   string        `json:"provider"`
+	Model      string        `json:"model"`
+	Attempt    int           `json:"attempt"`
+	MaxRetries int           `json:"max_retries"`
+	Delay      time.Duration `json:"delay"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error"`
+}
+
+// Status returns a short description of the retry, e.g. "retrying in 8s (429)".
+func (e RetryEvent) Status() string {
+	status := fmt.Sprintf("retrying in %s", e.Delay.Round(time.Second))
+	if e.StatusCode != 0 {
+		status += fmt.Sprintf(" (%d)", e.StatusCode)
+	}
+	return status
+}
+
 type Service interface {
 	pubsub.Suscriber[Message]
 	Create(ctx context.Context, sessionID string, params CreateMessageParams) (Message, error)
@@ -28,20 +53,32 @@ type Service interface {
 	List(ctx context.Context, sessionID string) ([]Message, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
+	PublishRetry(event RetryEvent)
+	SubscribeRetries(ctx context.Context) <-chan pubsub.Event[RetryEvent]
 }
 
 type service struct {
 	*pubsub.Broker[Message]
-	q db.Querier
+	retryBroker *pubsub.Broker[RetryEvent]
+	q           db.Querier
 }
 
 func NewService(q db.Querier) Service {
 	return &service{
-		Broker: pubsub.NewBroker[Message](),
-		q:      q,
+		Broker:      pubsub.NewBroker[Message](),
+		retryBroker: pubsub.NewBroker[RetryEvent](),
+		q:           q,
 	}
 }
 
+func (s *service) PublishRetry(event RetryEvent) {
+	s.retryBroker.Publish(pubsub.CreatedEvent, event)
+}
+
+func (s *service) SubscribeRetries(ctx context.Context) <-chan pubsub.Event[RetryEvent] {
+	return s.retryBroker.Subscribe(ctx)
+}
+
 func (s *service) Delete(ctx context.Context, id string) error {
 	message, err := s.Get(ctx, id)
 	if err != nil {
@@ -85,6 +122,7 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 	if err != nil {
 		return Message{}, err
 	}
+	s.index(ctx, message)
 	s.Publish(pubsub.CreatedEvent, message)
 	return message, nil
 }
@@ -118,16 +156,50 @@ func (s *service) Update(ctx context.Context, message Message) error {
 	err = s.q.UpdateMessage(ctx, db.UpdateMessageParams{
 		ID:         message.ID,
 		Parts:      string(parts),
+		Model:      sql.NullString{String: message.Model, Valid: message.Model != ""},
+		Provider:   sql.NullString{String: message.Provider, Valid: message.Provider != ""},
 		FinishedAt: finishedAt,
 	})
 	if err != nil {
 		return err
 	}
+	// the assistant messages are indexed once finished rather than on
+	// every delta
+	if message.Role != Assistant || message.IsFinished() {
+		s.index(ctx, message)
+	}
 	message.UpdatedAt = time.Now().Unix()
 	s.Publish(pubsub.UpdatedEvent, message)
 	return nil
 }
 
+// index updates the text of the message searched in by the full-text
+// search: its text, and the input and output of its tool calls.
+func (s *service) index(ctx context.Context, message Message) {
+	var texts []string
+	for _, part := range message.Parts {
+		switch part := part.(type) {
+		case TextContent:
+			texts = append(texts, part.Text)
+		case ToolCall:
+			texts = append(texts, part.Input)
+		case ToolResult:
+			texts = append(texts, part.Content)
+		}
+	}
+	content := strings.Join(texts, "\n")
+	if content == "" {
+		return
+	}
+	if err := s.q.UpsertSearchDocument(ctx, db.UpsertSearchDocumentParams{
+		SessionID: message.SessionID,
+		MessageID: sql.NullString{String: message.ID, Valid: true},
+		Content:   content,
+	}); err != nil {
+		slog.Error("Failed to index message", "message_id", message.ID, "error", err)
+	}
+}
+
 func (s *service) Get(ctx context.Context, id string) (Message, error) {
 	dbMessage, err := s.q.GetMessage(ctx, id)
 	if err != nil {
@@ -186,6 +258,12 @@ type partWrapper struct {
 	Data ContentPart `json:"data"`
 }
 
+// MarshalParts returns the JSON of the parts, each tagged with its type, the
+// way they are stored.
+func MarshalParts(parts []ContentPart) ([]byte, error) {
+	return marshallParts(parts)
+}
+
 func marshallParts(parts []ContentPart) ([]byte, error) {
 	wrappedParts := make([]partWrapper, len(parts))
 
@@ -219,6 +297,11 @@ func marshallParts(parts []ContentPart) ([]byte, error) {
 	return json.Marshal(wrappedParts)
 }
 
+// UnmarshalParts returns the parts of the JSON returned by MarshalParts.
+func UnmarshalParts(data []byte) ([]ContentPart, error) {
+	return unmarshallParts(data)
+}
+
 func unmarshallParts(data []byte) ([]ContentPart, error) {
 	temp := []json.RawMessage{}
 
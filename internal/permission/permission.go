@@ -26,7 +26,9 @@ type CreatePermissionRequest struct {
 }
 
 type PermissionNotification struct {
+	SessionID  string `json:"session_id"`
 	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
 	Granted    bool   `json:"granted"`
 	Denied     bool   `json:"denied"`
 }
@@ -51,6 +53,10 @@ type Service interface {
 	AutoApproveSession(sessionID string)
 	SetSkipRequests(skip bool)
 	SkipRequests() bool
+	// SetDenyRequests denies the requests not granted by the allowlist, the
+	// auto approved sessions or an earlier grant instead of asking, for when
+	// nobody is there to answer.
+	SetDenyRequests(deny bool)
 	SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification]
 }
 
@@ -65,6 +71,7 @@ type permissionService struct {
 	autoApproveSessions   map[string]bool
 	autoApproveSessionsMu sync.RWMutex
 	skip                  bool
+	deny                  bool
 	allowedTools          []string
 
 	// used to make sure we only process one request at a time
@@ -74,7 +81,9 @@ type permissionService struct {
 
 func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  permission.SessionID,
 		ToolCallID: permission.ToolCallID,
+		ToolName:   permission.ToolName,
 		Granted:    true,
 	})
 	respCh, ok := s.pendingRequests.Get(permission.ID)
@@ -93,7 +102,9 @@ func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 
 func (s *permissionService) Grant(permission PermissionRequest) {
 	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  permission.SessionID,
 		ToolCallID: permission.ToolCallID,
+		ToolName:   permission.ToolName,
 		Granted:    true,
 	})
 	respCh, ok := s.pendingRequests.Get(permission.ID)
@@ -108,7 +119,9 @@ func (s *permissionService) Grant(permission PermissionRequest) {
 
 func (s *permissionService) Deny(permission PermissionRequest) {
 	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  permission.SessionID,
 		ToolCallID: permission.ToolCallID,
+		ToolName:   permission.ToolName,
 		Granted:    false,
 		Denied:     true,
 	})
@@ -124,12 +137,15 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	if s.skip {
+		s.notifyGranted(opts)
 		return true
 	}
 
 	// tell the UI that a permission was requested
 	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  opts.SessionID,
 		ToolCallID: opts.ToolCallID,
+		ToolName:   opts.ToolName,
 	})
 	s.requestMu.Lock()
 	defer s.requestMu.Unlock()
@@ -137,6 +153,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	// Check if the tool/action combination is in the allowlist
 	commandKey := opts.ToolName + ":" + opts.Action
 	if slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName) {
+		s.notifyGranted(opts)
 		return true
 	}
 
@@ -145,6 +162,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	s.autoApproveSessionsMu.RUnlock()
 
 	if autoApprove {
+		s.notifyGranted(opts)
 		return true
 	}
 
@@ -176,6 +194,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	for _, p := range s.sessionPermissions {
 		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
 			s.sessionPermissionsMu.RUnlock()
+			s.notifyGranted(opts)
 			return true
 		}
 	}
@@ -185,11 +204,22 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	for _, p := range s.sessionPermissions {
 		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
 			s.sessionPermissionsMu.RUnlock()
+			s.notifyGranted(opts)
 			return true
 		}
 	}
 	s.sessionPermissionsMu.RUnlock()
 
+	if s.deny {
+		s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+			SessionID:  opts.SessionID,
+			ToolCallID: opts.ToolCallID,
+			ToolName:   opts.ToolName,
+			Denied:     true,
+		})
+		return false
+	}
+
 	s.activeRequest = &permission
 
 	respCh := make(chan bool, 1)
@@ -202,6 +232,17 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	return <-respCh
 }
 
+// notifyGranted tells the subscribers that the request was granted without
+// asking.
+func (s *permissionService) notifyGranted(opts CreatePermissionRequest) {
+	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		SessionID:  opts.SessionID,
+		ToolCallID: opts.ToolCallID,
+		ToolName:   opts.ToolName,
+		Granted:    true,
+	})
+}
+
 func (s *permissionService) AutoApproveSession(sessionID string) {
 	s.autoApproveSessionsMu.Lock()
 	s.autoApproveSessions[sessionID] = true
@@ -220,6 +261,10 @@ func (s *permissionService) SkipRequests() bool {
 	return s.skip
 }
 
+func (s *permissionService) SetDenyRequests(deny bool) {
+	s.deny = deny
+}
+
 func NewPermissionService(workingDir string, skip bool, allowedTools []string) Service {
 	return &permissionService{
 		Broker:              pubsub.NewBroker[PermissionRequest](),
@@ -94,6 +94,35 @@ func TestPermissionService_SkipMode(t *testing.T) {
 	}
 }
 
+func TestPermissionService_DenyMode(t *testing.T) {
+	service := NewPermissionService("/tmp", false, []string{"view"})
+	service.SetDenyRequests(true)
+	notifications := service.SubscribeNotifications(t.Context())
+
+	allowed := service.Request(CreatePermissionRequest{
+		SessionID: "test-session",
+		ToolName:  "view",
+		Action:    "read",
+		Path:      "/etc/hosts",
+	})
+	assert.True(t, allowed, "expected allowlisted tool to be granted in deny mode")
+
+	denied := service.Request(CreatePermissionRequest{
+		SessionID:  "test-session",
+		ToolCallID: "call-1",
+		ToolName:   "bash",
+		Action:     "execute",
+		Path:       "/tmp",
+	})
+	assert.False(t, denied, "expected other tools to be denied in deny mode")
+
+	var last PermissionNotification
+	for range 4 {
+		last = (<-notifications).Payload
+	}
+	assert.Equal(t, PermissionNotification{SessionID: "test-session", ToolCallID: "call-1", ToolName: "bash", Denied: true}, last)
+}
+
 func TestPermissionService_SequentialProperties(t *testing.T) {
 	t.Run("Sequential permission requests with persistent grants", func(t *testing.T) {
 		service := NewPermissionService("/tmp", false, []string{})
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// The names of the events of the stream, after the services they come from.
+const (
+	EventSessions                = "sessions"
+	EventMessages                = "messages"
+	EventRetries                 = "retries"
+	EventBudgets                 = "budgets"
+	EventPermissions             = "permissions"
+	EventPermissionNotifications = "permission_notifications"
+	EventHistory                 = "history"
+	EventMCP                     = "mcp"
+	EventLSP                     = "lsp"
+	// EventRuns is sent by the server when the run of a prompt is over.
+	EventRuns = "runs"
+)
+
+// RunFinished is the type of the events of the runs.
+const RunFinished = "finished"
+
+// Event is an event of the stream.
+type Event struct {
+	Name string `json:"-"`
+	// Type is created, updated or deleted for the events of the services.
+	Type    string `json:"type"`
+	Payload any    `json:"payload"`
+}
+
+// Session is the JSON view of a session.
+type Session struct {
+	ID                  string  `json:"id"`
+	ParentSessionID     string  `json:"parent_session_id,omitempty"`
+	ForkedFromMessageID string  `json:"forked_from_message_id,omitempty"`
+	Title               string  `json:"title"`
+	MessageCount        int64   `json:"message_count"`
+	PromptTokens        int64   `json:"prompt_tokens"`
+	CompletionTokens    int64   `json:"completion_tokens"`
+	TotalTokens         int64   `json:"total_tokens"`
+	Cost                float64 `json:"cost"`
+	CreatedAt           int64   `json:"created_at"`
+	UpdatedAt           int64   `json:"updated_at"`
+}
+
+func newSession(s session.Session) Session {
+	return Session{
+		ID:                  s.ID,
+		ParentSessionID:     s.ParentSessionID,
+		ForkedFromMessageID: s.ForkedFromMessageID,
+		Title:               s.Title,
+		MessageCount:        s.MessageCount,
+		PromptTokens:        s.PromptTokens,
+		CompletionTokens:    s.CompletionTokens,
+		TotalTokens:         s.TotalTokens,
+		Cost:                s.Cost,
+		CreatedAt:           s.CreatedAt,
+		UpdatedAt:           s.UpdatedAt,
+	}
+}
+
+// Message is the JSON view of a message, its parts are tagged with their
+// type.
+type Message struct {
+	ID        string          `json:"id"`
+	SessionID string          `json:"session_id"`
+	Role      string          `json:"role"`
+	Parts     json.RawMessage `json:"parts"`
+	Model     string          `json:"model,omitempty"`
+	Provider  string          `json:"provider,omitempty"`
+	CreatedAt int64           `json:"created_at"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+func newMessage(msg message.Message) (Message, error) {
+	parts, err := message.MarshalParts(msg.Parts)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		ID:        msg.ID,
+		SessionID: msg.SessionID,
+		Role:      string(msg.Role),
+		Parts:     parts,
+		Model:     msg.Model,
+		Provider:  msg.Provider,
+		CreatedAt: msg.CreatedAt,
+		UpdatedAt: msg.UpdatedAt,
+	}, nil
+}
+
+// File is the JSON view of a version of a file, without its content.
+type File struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Path      string `json:"path"`
+	Version   int64  `json:"version"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Client is the JSON view of the state of an MCP or LSP client.
+type Client struct {
+	Type            string `json:"type"`
+	Name            string `json:"name"`
+	State           string `json:"state"`
+	Error           string `json:"error,omitempty"`
+	ToolCount       int    `json:"tool_count,omitempty"`
+	DiagnosticCount int    `json:"diagnostic_count,omitempty"`
+}
+
+// Run is the outcome of the run of a prompt.
+type Run struct {
+	SessionID string `json:"session_id"`
+	Error     string `json:"error,omitempty"`
+}
+
+var lspStates = map[lsp.ServerState]string{
+	lsp.StateStarting: "starting",
+	lsp.StateReady:    "ready",
+	lsp.StateError:    "error",
+	lsp.StateDisabled: "disabled",
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// newEvent returns the event of the stream for an event of the app.
+func newEvent(msg tea.Msg) (Event, bool) {
+	switch e := msg.(type) {
+	case pubsub.Event[session.Session]:
+		return Event{Name: EventSessions, Type: string(e.Type), Payload: newSession(e.Payload)}, true
+	case pubsub.Event[message.Message]:
+		view, err := newMessage(e.Payload)
+		if err != nil {
+			slog.Error("Failed to convert message", "id", e.Payload.ID, "error", err)
+			return Event{}, false
+		}
+		return Event{Name: EventMessages, Type: string(e.Type), Payload: view}, true
+	case pubsub.Event[message.RetryEvent]:
+		return Event{Name: EventRetries, Type: string(e.Type), Payload: e.Payload}, true
+	case pubsub.Event[session.BudgetEvent]:
+		return Event{Name: EventBudgets, Type: string(e.Type), Payload: e.Payload}, true
+	case pubsub.Event[permission.PermissionRequest]:
+		return Event{Name: EventPermissions, Type: string(e.Type), Payload: e.Payload}, true
+	case pubsub.Event[permission.PermissionNotification]:
+		return Event{Name: EventPermissionNotifications, Type: string(e.Type), Payload: e.Payload}, true
+	case pubsub.Event[history.File]:
+		return Event{Name: EventHistory, Type: string(e.Type), Payload: File{
+			ID:        e.Payload.ID,
+			SessionID: e.Payload.SessionID,
+			Path:      e.Payload.Path,
+			Version:   e.Payload.Version,
+			CreatedAt: e.Payload.CreatedAt,
+		}}, true
+	case pubsub.Event[tools.MCPEvent]:
+		return Event{Name: EventMCP, Type: string(e.Type), Payload: Client{
+			Type:      string(e.Payload.Type),
+			Name:      e.Payload.Name,
+			State:     e.Payload.State.String(),
+			Error:     errorString(e.Payload.Error),
+			ToolCount: e.Payload.ToolCount,
+		}}, true
+	case pubsub.Event[app.LSPEvent]:
+		return Event{Name: EventLSP, Type: string(e.Type), Payload: Client{
+			Type:            string(e.Payload.Type),
+			Name:            e.Payload.Name,
+			State:           lspStates[e.Payload.State],
+			Error:           errorString(e.Payload.Error),
+			DiagnosticCount: e.Payload.DiagnosticCount,
+		}}, true
+	default:
+		return Event{}, false
+	}
+}
+
+// Forward forwards the events of the app to the clients of the event stream
+// until ctx is done, keeping track of the pending permission requests on
+// the way.
+func (s *Server) Forward(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-s.app.Events():
+			if !ok {
+				return
+			}
+			switch e := msg.(type) {
+			case pubsub.Event[permission.PermissionRequest]:
+				s.pending.Set(e.Payload.ID, e.Payload)
+			case pubsub.Event[permission.PermissionNotification]:
+				// answered by another client, or without asking
+				if e.Payload.Granted || e.Payload.Denied {
+					for id, request := range s.pending.Seq2() {
+						if request.ToolCallID == e.Payload.ToolCallID {
+							s.pending.Del(id)
+						}
+					}
+				}
+			}
+			if event, ok := newEvent(msg); ok {
+				s.events.Publish(pubsub.UpdatedEvent, event)
+			}
+		}
+	}
+}
+
+// streamEvents streams the events as server-sent events until the client
+// goes away.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+	// subscribe before the client sees the headers, for it not to miss the
+	// events published as soon as it does
+	events := s.events.Subscribe(r.Context())
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e.Payload)
+			if err != nil {
+				slog.Error("Failed to marshal event", "name", e.Payload.Name, "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Payload.Name, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
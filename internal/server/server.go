@@ -0,0 +1,276 @@
+// Package server exposes the app over HTTP, for frontends other than the TUI
+// such as dashboards and editor plugins to drive it.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// Server serves the REST API and the event stream of the app.
+type Server struct {
+	// ctx outlives the requests, the prompts run with it.
+	ctx     context.Context
+	app     *app.App
+	token   string
+	mux     *http.ServeMux
+	events  *pubsub.Broker[Event]
+	pending *csync.Map[string, permission.PermissionRequest]
+}
+
+// New returns a server for the app, the clients must send the token. The
+// prompts sent to the server run until ctx is done.
+func New(ctx context.Context, app *app.App, token string) *Server {
+	s := &Server{
+		ctx:     ctx,
+		app:     app,
+		token:   token,
+		mux:     http.NewServeMux(),
+		events:  pubsub.NewBroker[Event](),
+		pending: csync.NewMap[string, permission.PermissionRequest](),
+	}
+	s.mux.HandleFunc("GET /v1/sessions", s.listSessions)
+	s.mux.HandleFunc("POST /v1/sessions", s.createSession)
+	s.mux.HandleFunc("GET /v1/sessions/{id}", s.getSession)
+	s.mux.HandleFunc("DELETE /v1/sessions/{id}", s.deleteSession)
+	s.mux.HandleFunc("GET /v1/sessions/{id}/messages", s.listMessages)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/prompt", s.sendPrompt)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/cancel", s.cancel)
+	s.mux.HandleFunc("GET /v1/permissions", s.listPermissions)
+	s.mux.HandleFunc("POST /v1/permissions/{id}/grant", s.grantPermission)
+	s.mux.HandleFunc("POST /v1/permissions/{id}/deny", s.denyPermission)
+	s.mux.HandleFunc("GET /v1/events", s.streamEvents)
+	return s
+}
+
+// ServeHTTP implements http.Handler, the requests without the token are
+// rejected.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized reports whether the request has the token, in the Authorization
+// header or, for the browsers' EventSource which can't set headers, in the
+// token query parameter.
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.app.Sessions.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]Session, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, newSession(session))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) createSession(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Title string `json:"title"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.Title == "" {
+		body.Title = "New Session"
+	}
+	session, err := s.app.Sessions.Create(r.Context(), body.Title)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newSession(session))
+}
+
+func (s *Server) getSession(w http.ResponseWriter, r *http.Request) {
+	session, err := s.app.Sessions.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newSession(session))
+}
+
+func (s *Server) deleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if s.app.AgentCoordinator != nil {
+		s.app.AgentCoordinator.Cancel(id)
+	}
+	if err := s.app.Sessions.Delete(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	msgs, err := s.app.Messages.List(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]Message, 0, len(msgs))
+	for _, msg := range msgs {
+		view, err := newMessage(msg)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		views = append(views, view)
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// sendPrompt starts the agent on the prompt and returns right away, the
+// clients follow the run on the event stream.
+func (s *Server) sendPrompt(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Prompt string `json:"prompt"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("prompt is required"))
+		return
+	}
+	if s.app.AgentCoordinator == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("no providers configured"))
+		return
+	}
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+
+	queued := s.app.AgentCoordinator.IsSessionBusy(id)
+	go func() {
+		_, err := s.app.AgentCoordinator.Run(s.ctx, id, body.Prompt)
+		run := Run{SessionID: id}
+		if err != nil {
+			slog.Error("Prompt failed", "session_id", id, "error", err)
+			run.Error = err.Error()
+		}
+		s.events.Publish(pubsub.UpdatedEvent, Event{Name: EventRuns, Type: RunFinished, Payload: run})
+	}()
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"session_id": id,
+		"queued":     queued,
+	})
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	if s.app.AgentCoordinator != nil {
+		s.app.AgentCoordinator.Cancel(r.PathValue("id"))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listPermissions(w http.ResponseWriter, r *http.Request) {
+	requests := []permission.PermissionRequest{}
+	for request := range s.pending.Seq() {
+		requests = append(requests, request)
+	}
+	writeJSON(w, http.StatusOK, requests)
+}
+
+func (s *Server) grantPermission(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		// Persistent grants the permission for the rest of the session.
+		Persistent bool `json:"persistent"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	request, ok := s.takePending(w, r)
+	if !ok {
+		return
+	}
+	if body.Persistent {
+		s.app.Permissions.GrantPersistent(request)
+	} else {
+		s.app.Permissions.Grant(request)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) denyPermission(w http.ResponseWriter, r *http.Request) {
+	request, ok := s.takePending(w, r)
+	if !ok {
+		return
+	}
+	s.app.Permissions.Deny(request)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// takePending removes the pending permission request of the path from the
+// pending ones and returns it.
+func (s *Server) takePending(w http.ResponseWriter, r *http.Request) (permission.PermissionRequest, bool) {
+	id := r.PathValue("id")
+	request, ok := s.pending.Take(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no pending permission request with this ID"))
+	}
+	return request, ok
+}
+
+// readJSON decodes the body of the request, an empty body is fine.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil || errors.Is(err, io.EOF) {
+		return true
+	}
+	writeError(w, http.StatusBadRequest, err)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, errors.New("session not found"))
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func testServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	srv := New(t.Context(), &app.App{
+		Sessions:    session.NewService(q),
+		Messages:    message.NewService(q),
+		Permissions: permission.NewPermissionService(t.TempDir(), false, nil),
+	}, "secret")
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+func do(t *testing.T, ts *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), method, ts.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestAuth(t *testing.T) {
+	_, ts := testServer(t)
+
+	resp, err := http.Get(ts.URL + "/v1/sessions")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = http.Get(ts.URL + "/v1/sessions?token=secret")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestSessions(t *testing.T) {
+	srv, ts := testServer(t)
+
+	var created Session
+	require.Equal(t, http.StatusCreated, do(t, ts, http.MethodPost, "/v1/sessions", `{"title":"Dashboard"}`, &created))
+	require.Equal(t, "Dashboard", created.Title)
+
+	var sessions []Session
+	require.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/v1/sessions", "", &sessions))
+	require.Len(t, sessions, 1)
+	require.Equal(t, created.ID, sessions[0].ID)
+
+	_, err := srv.app.Messages.Create(t.Context(), created.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Hello"}},
+	})
+	require.NoError(t, err)
+	var msgs []Message
+	require.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/v1/sessions/"+created.ID+"/messages", "", &msgs))
+	require.Len(t, msgs, 1)
+	require.JSONEq(t, `[{"type":"text","data":{"text":"Hello"}},{"type":"finish","data":{"reason":"stop","time":0}}]`, string(msgs[0].Parts))
+
+	// no provider is configured
+	require.Equal(t, http.StatusServiceUnavailable, do(t, ts, http.MethodPost, "/v1/sessions/"+created.ID+"/prompt", `{"prompt":"Hi"}`, nil))
+	require.Equal(t, http.StatusBadRequest, do(t, ts, http.MethodPost, "/v1/sessions/"+created.ID+"/prompt", `{}`, nil))
+
+	require.Equal(t, http.StatusNoContent, do(t, ts, http.MethodDelete, "/v1/sessions/"+created.ID, "", nil))
+	require.Equal(t, http.StatusNotFound, do(t, ts, http.MethodGet, "/v1/sessions/"+created.ID, "", nil))
+}
+
+func TestPermissions(t *testing.T) {
+	srv, ts := testServer(t)
+	srv.pending.Set("request", permission.PermissionRequest{ID: "request", ToolName: "bash"})
+
+	var pending []permission.PermissionRequest
+	require.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/v1/permissions", "", &pending))
+	require.Len(t, pending, 1)
+
+	require.Equal(t, http.StatusNoContent, do(t, ts, http.MethodPost, "/v1/permissions/request/grant", `{"persistent":true}`, nil))
+	require.Equal(t, http.StatusNotFound, do(t, ts, http.MethodPost, "/v1/permissions/request/deny", "", nil))
+}
+
+func TestEvents(t *testing.T) {
+	srv, ts := testServer(t)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL+"/v1/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	event, ok := newEvent(pubsub.Event[session.Session]{
+		Type:    pubsub.CreatedEvent,
+		Payload: session.Session{ID: "session", Title: "Dashboard"},
+	})
+	require.True(t, ok)
+	srv.events.Publish(pubsub.UpdatedEvent, event)
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "event: sessions\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+	require.True(t, ok)
+	require.JSONEq(t, `{"type":"created","payload":{"id":"session","title":"Dashboard","message_count":0,"prompt_tokens":0,"completion_tokens":0,"total_tokens":0,"cost":0,"created_at":0,"updated_at":0}}`, data)
+}
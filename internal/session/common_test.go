@@ -0,0 +1,30 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+type env struct {
+	sessions Service
+	messages message.Service
+	history  history.Service
+}
+
+// testEnv returns the services of a temporary database.
+func testEnv(t *testing.T) env {
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	return env{
+		sessions: NewService(q),
+		messages: message.NewService(q),
+		history:  history.NewService(q, conn),
+	}
+}
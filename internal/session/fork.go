@@ -0,0 +1,174 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+// ErrMessageNotInSession is returned when forking a session from a message
+// that doesn't belong to it.
+var ErrMessageNotInSession = errors.New("message not found in session")
+
+func (s *service) Fork(ctx context.Context, sessionID, messageID string) (Session, error) {
+	parent, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	messages, err := s.q.ListMessagesBySession(ctx, sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	last := -1
+	for i, msg := range messages {
+		if msg.ID == messageID {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return Session{}, ErrMessageNotInSession
+	}
+	// keep the results of the tool calls of the message, the providers reject
+	// the tool calls without them
+	for last+1 < len(messages) && messages[last+1].Role == string(message.Tool) {
+		last++
+	}
+
+	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
+		ID:                  uuid.New().String(),
+		ParentSessionID:     sql.NullString{String: parent.ID, Valid: true},
+		Title:               "Fork of " + parent.Title,
+		PromptTokens:        parent.PromptTokens,
+		CompletionTokens:    parent.CompletionTokens,
+		ForkedFromMessageID: sql.NullString{String: messages[last].ID, Valid: true},
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	session := s.fromDBItem(dbSession)
+	if err := s.copyToFork(ctx, parent, &session, messages[:last+1]); err != nil {
+		if err := s.q.DeleteSession(ctx, session.ID); err != nil {
+			slog.Error("Failed to delete the incomplete fork", "session_id", session.ID, "error", err)
+		}
+		return Session{}, fmt.Errorf("failed to fork session: %w", err)
+	}
+	s.Publish(pubsub.CreatedEvent, session)
+	return session, nil
+}
+
+// copyToFork copies the given messages, the versions of the files of the
+// parent session and the checkpoints of the messages to the fork.
+func (s *service) copyToFork(ctx context.Context, parent Session, fork *Session, messages []db.Message) error {
+	ids := make(map[string]string, len(messages))
+	for _, msg := range messages {
+		copied, err := s.q.CreateMessage(ctx, db.CreateMessageParams{
+			ID:               uuid.New().String(),
+			SessionID:        fork.ID,
+			Role:             msg.Role,
+			Parts:            msg.Parts,
+			Model:            msg.Model,
+			Provider:         msg.Provider,
+			IsSummaryMessage: msg.IsSummaryMessage,
+		})
+		if err != nil {
+			return err
+		}
+		ids[msg.ID] = copied.ID
+		if err := s.q.CopySearchDocument(ctx, db.CopySearchDocumentParams{
+			SessionID:     fork.ID,
+			MessageID:     sql.NullString{String: copied.ID, Valid: true},
+			FromMessageID: sql.NullString{String: msg.ID, Valid: true},
+		}); err != nil {
+			return err
+		}
+		if msg.FinishedAt.Valid {
+			if err := s.q.UpdateMessage(ctx, db.UpdateMessageParams{
+				ID:         copied.ID,
+				Parts:      msg.Parts,
+				Model:      msg.Model,
+				Provider:   msg.Provider,
+				FinishedAt: msg.FinishedAt,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	files, err := s.q.ListFilesBySession(ctx, parent.ID)
+	if err != nil {
+		return err
+	}
+	// all the versions, the checkpoints refer to the older ones and rewinding
+	// restores the initial ones
+	for _, file := range files {
+		if _, err := s.q.CreateFile(ctx, db.CreateFileParams{
+			ID:        uuid.New().String(),
+			SessionID: fork.ID,
+			Path:      file.Path,
+			Content:   file.Content,
+			Version:   file.Version,
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, msg := range messages {
+		checkpoint, err := s.q.GetCheckpointByMessage(ctx, msg.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		copied := db.CopyCheckpointParams{
+			ID:        uuid.New().String(),
+			SessionID: fork.ID,
+			MessageID: ids[msg.ID],
+			CreatedAt: checkpoint.CreatedAt,
+		}
+		if err := s.q.CopyCheckpoint(ctx, copied); err != nil {
+			return err
+		}
+		if err := s.q.CopyCheckpointFiles(ctx, db.CopyCheckpointFilesParams{
+			CheckpointID:     copied.ID,
+			FromCheckpointID: checkpoint.ID,
+			SessionID:        fork.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// keep the summary when it was copied, so the fork doesn't send the
+	// messages before it either
+	fork.SummaryMessageID = ids[parent.SummaryMessageID]
+	if fork.SummaryMessageID != "" {
+		fork.SummaryKeptMessageID = ids[parent.SummaryKeptMessageID]
+	}
+	updated, err := s.q.UpdateSession(ctx, db.UpdateSessionParams{
+		ID:               fork.ID,
+		Title:            fork.Title,
+		PromptTokens:     fork.PromptTokens,
+		CompletionTokens: fork.CompletionTokens,
+		SummaryMessageID: sql.NullString{
+			String: fork.SummaryMessageID,
+			Valid:  fork.SummaryMessageID != "",
+		},
+		SummaryKeptMessageID: sql.NullString{
+			String: fork.SummaryKeptMessageID,
+			Valid:  fork.SummaryKeptMessageID != "",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	*fork = s.fromDBItem(updated)
+	return nil
+}
@@ -0,0 +1,59 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFork(t *testing.T) {
+	ctx := t.Context()
+	env := testEnv(t)
+	sessions, messages, files := env.sessions, env.messages, env.history
+
+	parent, err := sessions.Create(ctx, "Refactor")
+	require.NoError(t, err)
+	var created []message.Message
+	for _, prompt := range []string{"first", "second", "third"} {
+		msg, err := messages.Create(ctx, parent.ID, message.CreateMessageParams{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: prompt}},
+		})
+		require.NoError(t, err)
+		created = append(created, msg)
+	}
+	_, err = files.Create(ctx, parent.ID, "/tmp/main.go", "v1")
+	require.NoError(t, err)
+	_, err = files.CreateVersion(ctx, parent.ID, "/tmp/main.go", "v2")
+	require.NoError(t, err)
+
+	fork, err := sessions.Fork(ctx, parent.ID, created[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, parent.ID, fork.ParentSessionID)
+	require.Equal(t, created[1].ID, fork.ForkedFromMessageID)
+	require.True(t, fork.IsFork())
+	require.Equal(t, "Fork of Refactor", fork.Title)
+
+	copied, err := messages.List(ctx, fork.ID)
+	require.NoError(t, err)
+	require.Len(t, copied, 2)
+	for i, msg := range copied {
+		require.NotEqual(t, created[i].ID, msg.ID)
+		require.Equal(t, created[i].Content().Text, msg.Content().Text)
+	}
+
+	forkFiles, err := files.ListBySession(ctx, fork.ID)
+	require.NoError(t, err)
+	require.Len(t, forkFiles, 2)
+	require.Equal(t, "v1", forkFiles[0].Content)
+	require.Equal(t, "v2", forkFiles[1].Content)
+
+	// forks are listed with the top level sessions
+	listed, err := sessions.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+
+	_, err = sessions.Fork(ctx, parent.ID, "missing")
+	require.ErrorIs(t, err, ErrMessageNotInSession)
+}
@@ -0,0 +1,93 @@
+package session
+
+import (
+	"context"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/db"
+)
+
+// The markers of the matching terms in the snippets returned by the
+// database.
+const (
+	matchStart = '\x02'
+	matchEnd   = '\x03'
+)
+
+// SearchResult is a message or a title of a session matching a full-text
+// search.
+type SearchResult struct {
+	SessionID string
+	// MessageID is the matching message, empty when the title of the session
+	// matched.
+	MessageID string
+	Title     string
+	// Snippet is a single line of text around the match.
+	Snippet string
+	// Matches are the byte ranges of the matching terms in Snippet.
+	Matches [][2]int
+}
+
+func (s *service) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	match := searchQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	rows, err := s.q.SearchSessions(ctx, db.SearchSessionsParams{
+		Query: match,
+		Limit: int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, len(rows))
+	for i, row := range rows {
+		snippet, matches := parseSnippet(row.Snippet)
+		results[i] = SearchResult{
+			SessionID: row.SessionID,
+			MessageID: row.MessageID,
+			Title:     row.Title,
+			Snippet:   snippet,
+			Matches:   matches,
+		}
+	}
+	return results, nil
+}
+
+// searchQuery returns the full-text query matching all the words of query,
+// the last one being a prefix so results show up while typing. The words are
+// quoted so the query syntax isn't interpreted.
+func searchQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
+
+// parseSnippet returns the snippet without the markers of the matching terms
+// and on a single line, and the ranges of the terms.
+func parseSnippet(snippet string) (string, [][2]int) {
+	var b strings.Builder
+	var matches [][2]int
+	start := -1
+	for _, r := range snippet {
+		switch r {
+		case matchStart:
+			start = b.Len()
+		case matchEnd:
+			if start >= 0 {
+				matches = append(matches, [2]int{start, b.Len()})
+				start = -1
+			}
+		case '\n', '\r', '\t':
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), matches
+}
@@ -0,0 +1,89 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := t.Context()
+	env := testEnv(t)
+	sessions, messages := env.sessions, env.messages
+
+	migration, err := sessions.Create(ctx, "Database work")
+	require.NoError(t, err)
+	_, err = messages.Create(ctx, migration.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "The migration fails on a fresh database"}},
+	})
+	require.NoError(t, err)
+	reply, err := messages.Create(ctx, migration.ID, message.CreateMessageParams{Role: message.Assistant})
+	require.NoError(t, err)
+
+	other, err := sessions.Create(ctx, "Login form")
+	require.NoError(t, err)
+	prompt, err := messages.Create(ctx, other.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Fix the validation of the login form"}},
+	})
+	require.NoError(t, err)
+
+	search := func(query string) []SearchResult {
+		t.Helper()
+		results, err := sessions.Search(ctx, query, 10)
+		require.NoError(t, err)
+		return results
+	}
+
+	results := search("migration fail")
+	require.Len(t, results, 1)
+	require.Equal(t, migration.ID, results[0].SessionID)
+	require.Equal(t, "Database work", results[0].Title)
+	require.Equal(t, "The migration fails on a fresh database", results[0].Snippet)
+	require.Equal(t, [][2]int{{4, 13}, {14, 19}}, results[0].Matches)
+
+	// the assistant message is indexed once finished, with its tool calls
+	reply.AppendContent("Running the migrations")
+	reply.AddToolCall(message.ToolCall{ID: "call", Name: "bash", Input: `{"command":"goose up"}`, Finished: true})
+	require.NoError(t, messages.Update(ctx, reply))
+	require.Empty(t, search("goose"))
+	reply.AddFinish(message.FinishReasonToolUse, "", "")
+	require.NoError(t, messages.Update(ctx, reply))
+	results = search("goose")
+	require.Len(t, results, 1)
+	require.Equal(t, reply.ID, results[0].MessageID)
+
+	// titles, renamed or not
+	results = search("login")
+	require.Len(t, results, 2)
+	other.Title = "Sign in page"
+	_, err = sessions.Save(ctx, other)
+	require.NoError(t, err)
+	results = search("sign")
+	require.Len(t, results, 1)
+	require.Empty(t, results[0].MessageID)
+
+	// the query syntax isn't interpreted
+	require.Len(t, search(`validation"`), 1)
+	require.Empty(t, search("login OR nothing"))
+
+	fork, err := sessions.Fork(ctx, other.ID, prompt.ID)
+	require.NoError(t, err)
+	results = search("validation")
+	require.Len(t, results, 2)
+	require.ElementsMatch(t, []string{other.ID, fork.ID}, []string{results[0].SessionID, results[1].SessionID})
+
+	require.NoError(t, sessions.Delete(ctx, migration.ID))
+	require.Empty(t, search("migration"))
+	require.Empty(t, search(" "))
+}
+
+func TestParseSnippet(t *testing.T) {
+	t.Parallel()
+
+	snippet, matches := parseSnippet("…the \x02migration\x03\nfails")
+	require.Equal(t, "…the migration fails", snippet)
+	require.Equal(t, [][2]int{{7, 16}}, matches)
+}
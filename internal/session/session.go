@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/event"
@@ -20,9 +21,58 @@ type Session struct {
 	PromptTokens     int64
 	CompletionTokens int64
 	SummaryMessageID string
-	Cost             float64
-	CreatedAt        int64
-	UpdatedAt        int64
+	// SummaryKeptMessageID is the first message kept verbatim when only the
+	// older messages were summarized.
+	SummaryKeptMessageID string
+	Cost                 float64
+	RetryCount           int64
+	// TotalTokens is the number of tokens used by the session so far, unlike
+	// PromptTokens and CompletionTokens which only count the last request.
+	TotalTokens int64
+	// ForkedFromMessageID is the last message copied from the parent session
+	// when the session is a fork of it.
+	ForkedFromMessageID string
+	CreatedAt           int64
+	UpdatedAt           int64
+}
+
+// IsFork reports whether the session was forked from another one.
+func (s Session) IsFork() bool {
+	return s.ForkedFromMessageID != ""
+}
+
+// Usage is the cost, in USD, and the tokens used by the requests to the
+// models.
+type Usage struct {
+	Cost   float64
+	Tokens int64
+}
+
+// BudgetEvent is published when a session gets close to one of the budgets,
+// or reaches it and the agent is stopped.
+type BudgetEvent struct {
+	SessionID string  `json:"session_id"`
+	Budget    string  `json:"budget"` // e.g. "session cost"
+	Used      float64 `json:"used"`
+	Limit     float64 `json:"limit"`
+	Exceeded  bool    `json:"exceeded"`
+}
+
+// Status returns a short description of the event, e.g. "80% of the session
+// cost budget used ($4.02 of $5.00)".
+func (e BudgetEvent) Status() string {
+	amounts := fmt.Sprintf("%s of %s", e.format(e.Used), e.format(e.Limit))
+	if e.Exceeded {
+		return fmt.Sprintf("%s budget reached (%s)", e.Budget, amounts)
+	}
+	return fmt.Sprintf("%.0f%% of the %s budget used (%s)", e.Used/e.Limit*100, e.Budget, amounts)
+}
+
+func (e BudgetEvent) format(v float64) string {
+	if strings.HasSuffix(e.Budget, "cost") {
+		return fmt.Sprintf("$%.2f", v)
+	}
+	return fmt.Sprintf("%.0f tokens", v)
 }
 
 type Service interface {
@@ -30,10 +80,27 @@ type Service interface {
 	Create(ctx context.Context, title string) (Session, error)
 	CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error)
 	CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (Session, error)
+	// Fork creates a new session with the messages of the given session up
+	// to and including the given message and the results of its tool calls,
+	// along with the versions of its files and the checkpoints of the copied
+	// prompts.
+	Fork(ctx context.Context, sessionID, messageID string) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
 	Save(ctx context.Context, session Session) (Session, error)
 	Delete(ctx context.Context, id string) error
+	// Search returns up to limit messages and titles of sessions matching
+	// all the words of query, the best matches first.
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+	// UsageSince returns the usage of the requests made since the given time.
+	UsageSince(ctx context.Context, since time.Time) (Usage, error)
+	// RecordUsage stores the usage of a request to a model.
+	RecordUsage(ctx context.Context, usage StepUsage) error
+	// ListUsage returns the usage per day, session and model of the requests
+	// made between since and until, zero times meaning no bound.
+	ListUsage(ctx context.Context, since, until time.Time) ([]UsageRow, error)
+	PublishBudget(event BudgetEvent)
+	SubscribeBudgets(ctx context.Context) <-chan pubsub.Event[BudgetEvent]
 
 	// Agent tool session management
 	CreateAgentToolSessionID(messageID, toolCallID string) string
@@ -43,7 +110,8 @@ type Service interface {
 
 type service struct {
 	*pubsub.Broker[Session]
-	q db.Querier
+	budgetBroker *pubsub.Broker[BudgetEvent]
+	q            db.Querier
 }
 
 func (s *service) Create(ctx context.Context, title string) (Session, error) {
@@ -120,7 +188,13 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 			String: session.SummaryMessageID,
 			Valid:  session.SummaryMessageID != "",
 		},
-		Cost: session.Cost,
+		Cost:        session.Cost,
+		RetryCount:  session.RetryCount,
+		TotalTokens: session.TotalTokens,
+		SummaryKeptMessageID: sql.NullString{
+			String: session.SummaryKeptMessageID,
+			Valid:  session.SummaryKeptMessageID != "",
+		},
 	})
 	if err != nil {
 		return Session{}, err
@@ -130,6 +204,22 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 	return session, nil
 }
 
+func (s *service) UsageSince(ctx context.Context, since time.Time) (Usage, error) {
+	usage, err := s.q.GetUsageSince(ctx, since.Unix())
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{Cost: usage.Cost, Tokens: usage.TotalTokens}, nil
+}
+
+func (s *service) PublishBudget(event BudgetEvent) {
+	s.budgetBroker.Publish(pubsub.CreatedEvent, event)
+}
+
+func (s *service) SubscribeBudgets(ctx context.Context) <-chan pubsub.Event[BudgetEvent] {
+	return s.budgetBroker.Subscribe(ctx)
+}
+
 func (s *service) List(ctx context.Context) ([]Session, error) {
 	dbSessions, err := s.q.ListSessions(ctx)
 	if err != nil {
@@ -144,24 +234,28 @@ func (s *service) List(ctx context.Context) ([]Session, error) {
 
 func (s service) fromDBItem(item db.Session) Session {
 	return Session{
-		ID:               item.ID,
-		ParentSessionID:  item.ParentSessionID.String,
-		Title:            item.Title,
-		MessageCount:     item.MessageCount,
-		PromptTokens:     item.PromptTokens,
-		CompletionTokens: item.CompletionTokens,
-		SummaryMessageID: item.SummaryMessageID.String,
-		Cost:             item.Cost,
-		CreatedAt:        item.CreatedAt,
-		UpdatedAt:        item.UpdatedAt,
+		ID:                   item.ID,
+		ParentSessionID:      item.ParentSessionID.String,
+		Title:                item.Title,
+		MessageCount:         item.MessageCount,
+		PromptTokens:         item.PromptTokens,
+		CompletionTokens:     item.CompletionTokens,
+		SummaryMessageID:     item.SummaryMessageID.String,
+		SummaryKeptMessageID: item.SummaryKeptMessageID.String,
+		Cost:                 item.Cost,
+		RetryCount:           item.RetryCount,
+		TotalTokens:          item.TotalTokens,
+		ForkedFromMessageID:  item.ForkedFromMessageID.String,
+		CreatedAt:            item.CreatedAt,
+		UpdatedAt:            item.UpdatedAt,
 	}
 }
 
 func NewService(q db.Querier) Service {
-	broker := pubsub.NewBroker[Session]()
 	return &service{
-		broker,
-		q,
+		Broker:       pubsub.NewBroker[Session](),
+		budgetBroker: pubsub.NewBroker[BudgetEvent](),
+		q:            q,
 	}
 }
 
@@ -0,0 +1,95 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/google/uuid"
+)
+
+// StepUsage is the usage of a single request to a model.
+type StepUsage struct {
+	SessionID string
+	// MessageID is the assistant message of the request, if any.
+	MessageID           string
+	Provider            string
+	Model               string
+	InputTokens         int64
+	OutputTokens        int64
+	CacheReadTokens     int64
+	CacheCreationTokens int64
+	Cost                float64
+	Latency             time.Duration
+}
+
+// UsageRow is the usage of a session with a model on a day. The usage of the
+// sub-agents and of the title counts for the session they were run from.
+type UsageRow struct {
+	Day                 string // e.g. "2025-10-28", in local time
+	SessionID           string
+	Title               string
+	Provider            string
+	Model               string
+	Requests            int64
+	InputTokens         int64
+	OutputTokens        int64
+	CacheReadTokens     int64
+	CacheCreationTokens int64
+	Cost                float64
+	// Latency is the time spent in all the requests.
+	Latency time.Duration
+}
+
+func (s *service) RecordUsage(ctx context.Context, usage StepUsage) error {
+	return s.q.CreateUsage(ctx, db.CreateUsageParams{
+		ID:        uuid.New().String(),
+		SessionID: usage.SessionID,
+		MessageID: sql.NullString{
+			String: usage.MessageID,
+			Valid:  usage.MessageID != "",
+		},
+		Provider:            usage.Provider,
+		Model:               usage.Model,
+		InputTokens:         usage.InputTokens,
+		OutputTokens:        usage.OutputTokens,
+		CacheReadTokens:     usage.CacheReadTokens,
+		CacheCreationTokens: usage.CacheCreationTokens,
+		Cost:                usage.Cost,
+		LatencyMs:           usage.Latency.Milliseconds(),
+	})
+}
+
+func (s *service) ListUsage(ctx context.Context, since, until time.Time) ([]UsageRow, error) {
+	params := db.ListUsageParams{Until: math.MaxInt64}
+	if !since.IsZero() {
+		params.Since = since.Unix()
+	}
+	if !until.IsZero() {
+		params.Until = until.Unix()
+	}
+	rows, err := s.q.ListUsage(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	usage := make([]UsageRow, len(rows))
+	for i, row := range rows {
+		usage[i] = UsageRow{
+			Day:                 row.Day,
+			SessionID:           row.SessionID,
+			Title:               row.Title,
+			Provider:            row.Provider,
+			Model:               row.Model,
+			Requests:            row.Requests,
+			InputTokens:         row.InputTokens,
+			OutputTokens:        row.OutputTokens,
+			CacheReadTokens:     row.CacheReadTokens,
+			CacheCreationTokens: row.CacheCreationTokens,
+			Cost:                row.Cost,
+			Latency:             time.Duration(row.LatencyMs) * time.Millisecond,
+		}
+	}
+	return usage, nil
+}
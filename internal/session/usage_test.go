@@ -0,0 +1,81 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListUsage(t *testing.T) {
+	ctx := t.Context()
+	sessions := testEnv(t).sessions
+
+	parent, err := sessions.Create(ctx, "Parent")
+	require.NoError(t, err)
+	task, err := sessions.CreateTaskSession(ctx, "call-1", parent.ID, "Task")
+	require.NoError(t, err)
+	deleted, err := sessions.Create(ctx, "Deleted")
+	require.NoError(t, err)
+
+	record := func(sessionID, model string, cost float64) {
+		t.Helper()
+		require.NoError(t, sessions.RecordUsage(ctx, StepUsage{
+			SessionID:    sessionID,
+			Provider:     "anthropic",
+			Model:        model,
+			InputTokens:  100,
+			OutputTokens: 10,
+			Cost:         cost,
+			Latency:      time.Second,
+		}))
+	}
+	record(parent.ID, "claude", 1)
+	record(task.ID, "claude", 2)
+	record(task.ID, "haiku", 0.5)
+	record(deleted.ID, "claude", 4)
+	require.NoError(t, sessions.Delete(ctx, deleted.ID))
+
+	rows, err := sessions.ListUsage(ctx, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	// the sub-agent counts for its parent
+	byModel := map[string]UsageRow{}
+	for _, row := range rows {
+		require.Equal(t, time.Now().Format(time.DateOnly), row.Day)
+		if row.SessionID == parent.ID {
+			byModel[row.Model] = row
+		}
+	}
+	require.Equal(t, "Parent", byModel["claude"].Title)
+	require.Equal(t, int64(2), byModel["claude"].Requests)
+	require.Equal(t, int64(200), byModel["claude"].InputTokens)
+	require.Equal(t, 3.0, byModel["claude"].Cost)
+	require.Equal(t, 2*time.Second, byModel["claude"].Latency)
+	require.Equal(t, 0.5, byModel["haiku"].Cost)
+
+	// deleting a session keeps its usage
+	usage, err := sessions.UsageSince(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 7.5, usage.Cost)
+	require.Equal(t, int64(440), usage.Tokens)
+
+	// the totals of the sessions updated since don't count, only the
+	// requests made since
+	parent.Cost = 100
+	parent.TotalTokens = 10_000
+	_, err = sessions.Save(ctx, parent)
+	require.NoError(t, err)
+	usage, err = sessions.UsageSince(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 7.5, usage.Cost)
+	require.Equal(t, int64(440), usage.Tokens)
+
+	rows, err = sessions.ListUsage(ctx, time.Now().Add(time.Hour), time.Time{})
+	require.NoError(t, err)
+	require.Empty(t, rows)
+	rows, err = sessions.ListUsage(ctx, time.Time{}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, rows)
+}
@@ -30,6 +30,12 @@ type SessionSelectedMsg = session.Session
 
 type SessionClearedMsg struct{}
 
+// SelectMessageMsg focuses the chat on the given message of the current
+// session.
+type SelectMessageMsg struct {
+	MessageID string
+}
+
 type SelectionCopyMsg struct {
 	clickCount   int
 	endSelection bool
@@ -49,6 +55,7 @@ type MessageListCmp interface {
 	layout.Help
 
 	SetSession(session.Session) tea.Cmd
+	SelectMessage(messageID string) tea.Cmd
 	GoToBottom() tea.Cmd
 	GetSelectedText() string
 	CopySelectedText(bool) tea.Cmd
@@ -468,10 +475,13 @@ func (m *messageListCmp) findAssistantMessageAndToolCalls(items []list.Item, mes
 // updateAssistantMessageContent updates or removes the assistant message based on content.
 func (m *messageListCmp) updateAssistantMessageContent(msg message.Message, assistantIndex int) tea.Cmd {
 	if assistantIndex == NotFound {
+		if m.shouldShowBudgetExceeded(msg) {
+			return m.listCmp.AppendItem(messages.NewMessageCmp(msg))
+		}
 		return nil
 	}
 
-	shouldShowMessage := m.shouldShowAssistantMessage(msg)
+	shouldShowMessage := m.shouldShowAssistantMessage(msg) || m.shouldShowBudgetExceeded(msg)
 	hasToolCallsOnly := len(msg.ToolCalls()) > 0 && msg.Content().Text == ""
 
 	var cmd tea.Cmd
@@ -504,6 +514,12 @@ func (m *messageListCmp) shouldShowAssistantMessage(msg message.Message) bool {
 	return len(msg.ToolCalls()) == 0 || msg.Content().Text != "" || msg.ReasoningContent().Thinking != "" || msg.IsThinking()
 }
 
+// shouldShowBudgetExceeded determines if the budget exceeded notice of a
+// message only holding tool calls should be displayed after them.
+func (m *messageListCmp) shouldShowBudgetExceeded(msg message.Message) bool {
+	return !m.shouldShowAssistantMessage(msg) && msg.FinishReason() == message.FinishReasonBudgetExceeded
+}
+
 // updateToolCalls handles updates to tool calls, updating existing ones and adding new ones.
 func (m *messageListCmp) updateToolCalls(msg message.Message, existingToolCalls map[int]messages.ToolCallCmp) tea.Cmd {
 	var cmds []tea.Cmd
@@ -586,6 +602,24 @@ func (m *messageListCmp) SetSession(session session.Session) tea.Cmd {
 	return m.listCmp.SetItems(uiMessages)
 }
 
+// SelectMessage selects the given message, or its first tool call when the
+// message isn't shown on its own, such as the results of the tool calls.
+func (m *messageListCmp) SelectMessage(messageID string) tea.Cmd {
+	id := messageID
+	if !m.messageExists(messageID) {
+		msg, err := m.app.Messages.Get(context.Background(), messageID)
+		if err != nil {
+			return util.ReportError(err)
+		}
+		if calls := msg.ToolCalls(); len(calls) > 0 {
+			id = calls[0].ID
+		} else if results := msg.ToolResults(); len(results) > 0 {
+			id = results[0].ToolCallID
+		}
+	}
+	return m.listCmp.SetSelected(id)
+}
+
 // buildToolResultMap creates a map of tool call ID to tool result for efficient lookup.
 func (m *messageListCmp) buildToolResultMap(messages []message.Message) map[string]message.ToolResult {
 	toolResultMap := make(map[string]message.ToolResult)
@@ -652,6 +686,10 @@ func (m *messageListCmp) convertAssistantMessage(msg message.Message, toolResult
 		}
 	}
 
+	if m.shouldShowBudgetExceeded(msg) {
+		uiMessages = append(uiMessages, messages.NewMessageCmp(msg))
+	}
+
 	return uiMessages
 }
 
@@ -41,6 +41,11 @@ type Editor interface {
 	layout.Positional
 
 	SetSession(session session.Session) tea.Cmd
+	// SetPlanMode shows whether the prompts are sent in plan mode.
+	SetPlanMode(enabled bool)
+	// SetEditing shows whether the prompt being written replaces a previous
+	// one.
+	SetEditing(editing bool)
 	IsCompletionsOpen() bool
 	HasAttachments() bool
 	Cursor() *tea.Cursor
@@ -61,6 +66,8 @@ type editorCmp struct {
 	deleteMode         bool
 	readyPlaceholder   string
 	workingPlaceholder string
+	planMode           bool
+	editing            bool
 
 	keyMap EditorKeyMap
 
@@ -580,15 +587,25 @@ func (m *editorCmp) View() string {
 	if m.app.Permissions.SkipRequests() {
 		m.textarea.Placeholder = "Yolo mode!"
 	}
-	if len(m.attachments) == 0 {
+	if m.planMode {
+		m.textarea.Placeholder = "Plan mode: describe the change to plan"
+	}
+	if len(m.attachments) == 0 && !m.editing {
 		content := t.S().Base.Padding(1).Render(
 			m.textarea.View(),
 		)
 		return content
 	}
+	header := m.attachmentsContent()
+	if m.editing {
+		header = lipgloss.JoinHorizontal(lipgloss.Left,
+			t.S().Base.Foreground(t.Warning).Render("Editing a previous prompt, esc to cancel"),
+			header,
+		)
+	}
 	content := t.S().Base.Padding(0, 1, 1, 1).Render(
 		lipgloss.JoinVertical(lipgloss.Top,
-			m.attachmentsContent(),
+			header,
 			m.textarea.View(),
 		),
 	)
@@ -800,6 +817,14 @@ func (c *editorCmp) SetSession(session session.Session) tea.Cmd {
 	return nil
 }
 
+func (c *editorCmp) SetPlanMode(enabled bool) {
+	c.planMode = enabled
+}
+
+func (c *editorCmp) SetEditing(editing bool) {
+	c.editing = editing
+}
+
 func (c *editorCmp) IsCompletionsOpen() bool {
 	return c.isCompletionsOpen
 }
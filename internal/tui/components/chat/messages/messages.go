@@ -29,6 +29,32 @@ import (
 // CopyKey is the key binding for copying message content to the clipboard.
 var CopyKey = key.NewBinding(key.WithKeys("c", "y", "C", "Y"), key.WithHelp("c/y", "copy"))
 
+// RewindKey is the key binding for rewinding the session to the focused user
+// message.
+var RewindKey = key.NewBinding(key.WithKeys("r", "R"), key.WithHelp("r", "rewind to here"))
+
+// RewindMsg is sent to rewind the session to the given user message.
+type RewindMsg struct {
+	Message message.Message
+}
+
+// EditKey is the key binding for editing the focused user message and
+// sending it again.
+var EditKey = key.NewBinding(key.WithKeys("e", "E"), key.WithHelp("e", "edit"))
+
+// EditMsg is sent to edit the given user message and send it again.
+type EditMsg struct {
+	Message message.Message
+}
+
+// ForkKey is the key binding for forking the session at the focused message.
+var ForkKey = key.NewBinding(key.WithKeys("F"), key.WithHelp("F", "fork from here"))
+
+// ForkMsg is sent to fork the session at the given message.
+type ForkMsg struct {
+	Message message.Message
+}
+
 // ClearSelectionKey is the key binding for clearing the current selection in the chat interface.
 var ClearSelectionKey = key.NewBinding(key.WithKeys("esc", "alt+esc"), key.WithHelp("esc", "clear selection"))
 
@@ -114,6 +140,15 @@ func (m *messageCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 				util.ReportInfo("Message copied to clipboard"),
 			)
 		}
+		if key.Matches(msg, RewindKey) && m.message.Role == message.User {
+			return m, util.CmdHandler(RewindMsg{Message: m.message})
+		}
+		if key.Matches(msg, EditKey) && m.message.Role == message.User {
+			return m, util.CmdHandler(EditMsg{Message: m.message})
+		}
+		if key.Matches(msg, ForkKey) {
+			return m, util.CmdHandler(ForkMsg{Message: m.message})
+		}
 	}
 	return m, nil
 }
@@ -214,6 +249,15 @@ func (m *messageCmp) renderAssistantMessage() string {
 		parts = append(parts, m.toMarkdown(content))
 	}
 
+	if finished && finishedData.Reason == message.FinishReasonBudgetExceeded {
+		if len(parts) > 0 {
+			parts = append(parts, "")
+		}
+		budgetTag := t.S().Base.Padding(0, 1).Background(t.Warning).Foreground(t.White).Render("BUDGET")
+		truncated := ansi.Truncate(finishedData.Details, m.textWidth()-2-lipgloss.Width(budgetTag), "...")
+		parts = append(parts, fmt.Sprintf("%s %s", budgetTag, t.S().Base.Foreground(t.FgHalfMuted).Render(truncated)))
+	}
+
 	joined := lipgloss.JoinVertical(lipgloss.Left, parts...)
 	return m.style().Render(joined)
 }
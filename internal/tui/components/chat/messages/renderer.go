@@ -1,6 +1,7 @@
 package messages
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/charmbracelet/crush/internal/ansiext"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/image"
 	"github.com/charmbracelet/crush/internal/tui/highlight"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/lipgloss/v2"
@@ -21,6 +23,10 @@ import (
 // responseContextHeight limits the number of lines displayed in tool output
 const responseContextHeight = 10
 
+// mediaContextHeight limits the number of lines of the images displayed in
+// tool output
+const mediaContextHeight = 20
+
 // renderer defines the interface for tool-specific rendering implementations
 type renderer interface {
 	// Render returns the complete (already styled) tool‑call view, not
@@ -103,7 +109,12 @@ func (br baseRenderer) renderWithParams(v *toolCallCmp, toolName string, args []
 	if res, done := earlyState(header, v); done {
 		return res
 	}
-	body := contentRenderer()
+	var body string
+	if v.result.Data != "" {
+		body = renderMediaContent(v)
+	} else {
+		body = contentRenderer()
+	}
 	return joinHeaderBody(header, body)
 }
 
@@ -791,6 +802,30 @@ func renderPlainContent(v *toolCallCmp, content string) string {
 	return strings.Join(out, "\n")
 }
 
+// renderMediaContent renders the image returned by the tool followed by its
+// text, other media are only named.
+func renderMediaContent(v *toolCallCmp) string {
+	t := styles.CurrentTheme()
+	width := v.textWidth() - 2 // -2 for left padding
+	if v.media == "" || v.mediaWidth != width {
+		v.media = t.S().Muted.Render(fmt.Sprintf("[%s]", v.result.MIMEType))
+		v.mediaWidth = width
+		if strings.HasPrefix(v.result.MIMEType, "image/") {
+			data, err := base64.StdEncoding.DecodeString(v.result.Data)
+			if err == nil {
+				if img, err := image.Render(uint(max(width, 1)), mediaContextHeight, data, v.result.MIMEType); err == nil {
+					v.media = strings.TrimSuffix(img, "\n")
+				}
+			}
+		}
+	}
+
+	if strings.TrimSpace(v.result.Content) == "" {
+		return v.media
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, v.media, "", renderPlainContent(v, v.result.Content))
+}
+
 func getDigits(n int) int {
 	if n == 0 {
 		return 1
@@ -65,6 +65,10 @@ type toolCallCmp struct {
 	anim     util.Model // Animation component for pending states
 
 	nestedToolCalls []ToolCallCmp // Nested tool calls for hierarchical display
+
+	// Rendered media of the result, kept as it is expensive to render
+	media      string
+	mediaWidth int
 }
 
 // ToolCallOption provides functional options for configuring tool call components
@@ -666,6 +670,7 @@ func (m *toolCallCmp) ParentMessageID() string {
 func (m *toolCallCmp) SetToolResult(result message.ToolResult) {
 	m.result = result
 	m.spinning = false
+	m.media = ""
 }
 
 // GetToolCall returns the current tool call data
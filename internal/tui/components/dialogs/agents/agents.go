@@ -0,0 +1,242 @@
+package agents
+
+import (
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/exp/list"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+)
+
+const (
+	AgentsDialogID dialogs.DialogID = "agents"
+
+	defaultWidth int = 60
+)
+
+type listModel = list.FilterableList[list.CompletionItem[config.Agent]]
+
+// AgentsDialog lets the user pick the agent used for the current session.
+type AgentsDialog interface {
+	dialogs.DialogModel
+}
+
+type agentsDialogCmp struct {
+	width   int
+	wWidth  int // Width of the terminal window
+	wHeight int // Height of the terminal window
+
+	currentAgentID string
+
+	agentList listModel
+	keyMap    AgentsDialogKeyMap
+	help      help.Model
+}
+
+// AgentSelectedMsg is sent when an agent has been picked in the dialog.
+type AgentSelectedMsg struct {
+	AgentID string
+}
+
+type AgentsDialogKeyMap struct {
+	Next     key.Binding
+	Previous key.Binding
+	Select   key.Binding
+	Close    key.Binding
+}
+
+func DefaultAgentsDialogKeyMap() AgentsDialogKeyMap {
+	return AgentsDialogKeyMap{
+		Next: key.NewBinding(
+			key.WithKeys("down", "j", "ctrl+n"),
+			key.WithHelp("↓/j/ctrl+n", "next"),
+		),
+		Previous: key.NewBinding(
+			key.WithKeys("up", "k", "ctrl+p"),
+			key.WithHelp("↑/k/ctrl+p", "previous"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "select"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc", "ctrl+c"),
+			key.WithHelp("esc/ctrl+c", "close"),
+		),
+	}
+}
+
+func (k AgentsDialogKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Select, k.Close}
+}
+
+func (k AgentsDialogKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Next, k.Previous},
+		{k.Select, k.Close},
+	}
+}
+
+func NewAgentsDialog(currentAgentID string) AgentsDialog {
+	keyMap := DefaultAgentsDialogKeyMap()
+	listKeyMap := list.DefaultKeyMap()
+	listKeyMap.Down.SetEnabled(false)
+	listKeyMap.Up.SetEnabled(false)
+	listKeyMap.DownOneItem = keyMap.Next
+	listKeyMap.UpOneItem = keyMap.Previous
+
+	t := styles.CurrentTheme()
+	inputStyle := t.S().Base.PaddingLeft(1).PaddingBottom(1)
+	agentList := list.NewFilterableList(
+		[]list.CompletionItem[config.Agent]{},
+		list.WithFilterInputStyle(inputStyle),
+		list.WithFilterListOptions(
+			list.WithKeyMap(listKeyMap),
+			list.WithWrapNavigation(),
+			list.WithResizeByList(),
+		),
+	)
+	help := help.New()
+	help.Styles = t.S().Help
+
+	return &agentsDialogCmp{
+		currentAgentID: currentAgentID,
+		agentList:      agentList,
+		width:          defaultWidth,
+		keyMap:         keyMap,
+		help:           help,
+	}
+}
+
+func (a *agentsDialogCmp) Init() tea.Cmd {
+	return a.populateAgents()
+}
+
+func (a *agentsDialogCmp) populateAgents() tea.Cmd {
+	agentItems := []list.CompletionItem[config.Agent]{}
+	selectedID := ""
+	for _, agent := range config.Get().EnabledAgents() {
+		opts := []list.CompletionItemOption{
+			list.WithCompletionID(agent.ID),
+		}
+		if agent.ID == a.currentAgentID {
+			opts = append(opts, list.WithCompletionShortcut("current"))
+			selectedID = agent.ID
+		}
+		title := agent.Name
+		if agent.Description != "" {
+			title += " - " + agent.Description
+		}
+		agentItems = append(agentItems, list.NewCompletionItem(
+			title,
+			agent,
+			opts...,
+		))
+	}
+
+	cmd := a.agentList.SetItems(agentItems)
+	// Set the current agent as the selected item
+	if selectedID != "" {
+		return tea.Sequence(cmd, a.agentList.SetSelected(selectedID))
+	}
+	return cmd
+}
+
+func (a *agentsDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		a.wWidth = msg.Width
+		a.wHeight = msg.Height
+		return a, a.agentList.SetSize(a.listWidth(), a.listHeight())
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, a.keyMap.Select):
+			selectedItem := a.agentList.SelectedItem()
+			if selectedItem == nil {
+				return a, nil // No item selected, do nothing
+			}
+			agent := (*selectedItem).Value()
+			return a, tea.Sequence(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(AgentSelectedMsg{
+					AgentID: agent.ID,
+				}),
+			)
+		case key.Matches(msg, a.keyMap.Close):
+			return a, util.CmdHandler(dialogs.CloseDialogMsg{})
+		default:
+			u, cmd := a.agentList.Update(msg)
+			a.agentList = u.(listModel)
+			return a, cmd
+		}
+	}
+	return a, nil
+}
+
+func (a *agentsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	listView := a.agentList
+
+	header := t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Switch Agent", a.width-4))
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		header,
+		listView.View(),
+		"",
+		t.S().Base.Width(a.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(a.help.View(a.keyMap)),
+	)
+	return a.style().Render(content)
+}
+
+func (a *agentsDialogCmp) Cursor() *tea.Cursor {
+	if cursor, ok := a.agentList.(util.Cursor); ok {
+		cursor := cursor.Cursor()
+		if cursor != nil {
+			cursor = a.moveCursor(cursor)
+		}
+		return cursor
+	}
+	return nil
+}
+
+func (a *agentsDialogCmp) listWidth() int {
+	return a.width - 2 // 4 for padding
+}
+
+func (a *agentsDialogCmp) listHeight() int {
+	listHeight := len(a.agentList.Items()) + 2 + 4 // height based on items + 2 for the input + 4 for the sections
+	return min(listHeight, a.wHeight/2)
+}
+
+func (a *agentsDialogCmp) moveCursor(cursor *tea.Cursor) *tea.Cursor {
+	row, col := a.Position()
+	offset := row + 3
+	cursor.Y += offset
+	cursor.X = cursor.X + col + 2
+	return cursor
+}
+
+func (a *agentsDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(a.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+func (a *agentsDialogCmp) Position() (int, int) {
+	row := a.wHeight/4 - 2 // just a bit above the center
+	col := a.wWidth / 2
+	col -= a.width / 2
+	return row, col
+}
+
+func (a *agentsDialogCmp) ID() dialogs.DialogID {
+	return AgentsDialogID
+}
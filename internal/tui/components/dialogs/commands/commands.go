@@ -61,6 +61,7 @@ type commandDialogCmp struct {
 
 type (
 	SwitchSessionsMsg      struct{}
+	SearchSessionsMsg      struct{}
 	NewSessionsMsg         struct{}
 	SwitchModelMsg         struct{}
 	QuitMsg                struct{}
@@ -69,8 +70,10 @@ type (
 	ToggleCompactModeMsg   struct{}
 	ToggleThinkingMsg      struct{}
 	OpenReasoningDialogMsg struct{}
+	OpenAgentsDialogMsg    struct{}
 	OpenExternalEditorMsg  struct{}
 	ToggleYoloModeMsg      struct{}
+	TogglePlanModeMsg      struct{}
 	ReloadCommandsMsg      struct{}
 	CompactMsg             struct {
 		SessionID string
@@ -278,6 +281,14 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				return util.CmdHandler(SwitchSessionsMsg{})
 			},
 		},
+		{
+			ID:          "search_sessions",
+			Title:       "Search Sessions",
+			Description: "Search the messages of all the sessions",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(SearchSessionsMsg{})
+			},
+		},
 		{
 			ID:          "switch_model",
 			Title:       "Switch Model",
@@ -302,8 +313,20 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 		})
 	}
 
-	// Add reasoning toggle for models that support it
+	// Only show the agent switcher if there's more than one agent to pick
 	cfg := config.Get()
+	if len(cfg.EnabledAgents()) > 1 {
+		commands = append(commands, Command{
+			ID:          "switch_agent",
+			Title:       "Switch Agent",
+			Description: "Switch the agent used for the current session",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(OpenAgentsDialogMsg{})
+			},
+		})
+	}
+
+	// Add reasoning toggle for models that support it
 	if agentCfg, ok := cfg.Agents[config.AgentCoder]; ok {
 		providerCfg := cfg.GetProviderForModel(agentCfg.Model)
 		model := cfg.GetModelByType(agentCfg.Model)
@@ -380,6 +403,14 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 	}
 
 	return append(commands, []Command{
+		{
+			ID:          "toggle_plan_mode",
+			Title:       "Toggle Plan Mode",
+			Description: "Plan the changes with read-only tools before making them",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(TogglePlanModeMsg{})
+			},
+		},
 		{
 			ID:          "toggle_yolo",
 			Title:       "Toggle Yolo Mode",
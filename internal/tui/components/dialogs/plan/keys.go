@@ -0,0 +1,117 @@
+package plan
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+type KeyMap struct {
+	Left,
+	Right,
+	Tab,
+	Select,
+	Approve,
+	Edit,
+	Reject,
+	DoneEditing,
+	ScrollDown,
+	ScrollUp key.Binding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Left: key.NewBinding(
+			key.WithKeys("left", "h"),
+			key.WithHelp("←", "previous"),
+		),
+		Right: key.NewBinding(
+			key.WithKeys("right", "l"),
+			key.WithHelp("→", "next"),
+		),
+		Tab: key.NewBinding(
+			key.WithKeys("tab"),
+			key.WithHelp("tab", "switch"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("enter", "ctrl+y"),
+			key.WithHelp("enter", "confirm"),
+		),
+		Approve: key.NewBinding(
+			key.WithKeys("a", "A"),
+			key.WithHelp("a", "approve"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("e", "E"),
+			key.WithHelp("e", "edit"),
+		),
+		Reject: key.NewBinding(
+			key.WithKeys("k", "K", "esc"),
+			key.WithHelp("k", "keep planning"),
+		),
+		DoneEditing: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "done editing"),
+		),
+		ScrollDown: key.NewBinding(
+			key.WithKeys("down"),
+			key.WithHelp("↓", "scroll down"),
+		),
+		ScrollUp: key.NewBinding(
+			key.WithKeys("up"),
+			key.WithHelp("↑", "scroll up"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Left,
+		k.Right,
+		k.Tab,
+		k.Select,
+		k.Approve,
+		k.Edit,
+		k.Reject,
+		k.ScrollDown,
+		k.ScrollUp,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	m := [][]key.Binding{}
+	slice := k.KeyBindings()
+	for i := 0; i < len(slice); i += 4 {
+		end := min(i+4, len(slice))
+		m = append(m, slice[i:end])
+	}
+	return m
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Approve,
+		k.Edit,
+		k.Reject,
+		key.NewBinding(
+			key.WithKeys("up", "down"),
+			key.WithHelp("↑↓", "scroll"),
+		),
+	}
+}
+
+// editingKeyMap is the help shown while the plan is edited.
+type editingKeyMap struct {
+	DoneEditing key.Binding
+}
+
+// FullHelp implements help.KeyMap.
+func (k editingKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.DoneEditing}}
+}
+
+// ShortHelp implements help.KeyMap.
+func (k editingKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.DoneEditing}
+}
@@ -0,0 +1,251 @@
+package plan
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/bubbles/v2/textarea"
+	"github.com/charmbracelet/bubbles/v2/viewport"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const PlanDialogID dialogs.DialogID = "plan"
+
+// PlanResponseMsg is sent when the user approves or rejects the plan, Plan
+// holds the plan as edited by the user.
+type PlanResponseMsg struct {
+	SessionID string
+	Plan      string
+	Approved  bool
+}
+
+// PlanDialog shows the plan submitted by the agent in plan mode so the user
+// can approve, edit or reject it.
+type PlanDialog interface {
+	dialogs.DialogModel
+}
+
+type planDialogCmp struct {
+	wWidth  int
+	wHeight int
+	width   int
+	height  int
+
+	sessionID string
+	plan      string
+	rendered  string // cached markdown of the plan
+
+	viewport       viewport.Model
+	textarea       *textarea.Model
+	editing        bool
+	selectedOption int // 0: Approve, 1: Edit, 2: Keep Planning
+
+	keyMap KeyMap
+	help   help.Model
+}
+
+func NewPlanDialog(sessionID, plan string) PlanDialog {
+	t := styles.CurrentTheme()
+	ta := textarea.New()
+	ta.SetStyles(t.S().TextArea)
+	ta.ShowLineNumbers = false
+	ta.CharLimit = -1
+	ta.MaxHeight = 0
+	ta.Prompt = ""
+	ta.SetValue(plan)
+
+	return &planDialogCmp{
+		sessionID: sessionID,
+		plan:      plan,
+		viewport:  viewport.New(),
+		textarea:  ta,
+		keyMap:    DefaultKeyMap(),
+		help:      help.New(),
+	}
+}
+
+func (p *planDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+func (p *planDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.wWidth = msg.Width
+		p.wHeight = msg.Height
+		p.setSize()
+	case tea.KeyPressMsg:
+		if p.editing {
+			if key.Matches(msg, p.keyMap.DoneEditing) {
+				p.stopEditing()
+				return p, nil
+			}
+			var cmd tea.Cmd
+			p.textarea, cmd = p.textarea.Update(msg)
+			return p, cmd
+		}
+		switch {
+		case key.Matches(msg, p.keyMap.Right) || key.Matches(msg, p.keyMap.Tab):
+			p.selectedOption = (p.selectedOption + 1) % 3
+		case key.Matches(msg, p.keyMap.Left):
+			p.selectedOption = (p.selectedOption + 2) % 3
+		case key.Matches(msg, p.keyMap.Select):
+			return p, p.selectOption(p.selectedOption)
+		case key.Matches(msg, p.keyMap.Approve):
+			return p, p.selectOption(0)
+		case key.Matches(msg, p.keyMap.Edit):
+			return p, p.selectOption(1)
+		case key.Matches(msg, p.keyMap.Reject):
+			return p, p.selectOption(2)
+		case key.Matches(msg, p.keyMap.ScrollDown):
+			p.viewport.ScrollDown(1)
+		case key.Matches(msg, p.keyMap.ScrollUp):
+			p.viewport.ScrollUp(1)
+		}
+	case tea.PasteMsg:
+		if p.editing {
+			var cmd tea.Cmd
+			p.textarea, cmd = p.textarea.Update(msg)
+			return p, cmd
+		}
+	case tea.MouseWheelMsg:
+		if !p.editing {
+			switch msg.Button {
+			case tea.MouseWheelDown:
+				p.viewport.ScrollDown(1)
+			case tea.MouseWheelUp:
+				p.viewport.ScrollUp(1)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *planDialogCmp) selectOption(option int) tea.Cmd {
+	switch option {
+	case 0:
+		return tea.Batch(
+			util.CmdHandler(dialogs.CloseDialogMsg{}),
+			util.CmdHandler(PlanResponseMsg{SessionID: p.sessionID, Plan: p.plan, Approved: true}),
+		)
+	case 1:
+		p.editing = true
+		return p.textarea.Focus()
+	default:
+		return tea.Batch(
+			util.CmdHandler(dialogs.CloseDialogMsg{}),
+			util.CmdHandler(PlanResponseMsg{SessionID: p.sessionID, Plan: p.plan}),
+		)
+	}
+}
+
+func (p *planDialogCmp) stopEditing() {
+	p.editing = false
+	p.textarea.Blur()
+	if plan := strings.TrimSpace(p.textarea.Value()); plan != "" {
+		p.plan = plan
+	}
+	p.rendered = ""
+	p.selectedOption = 0
+}
+
+func (p *planDialogCmp) setSize() {
+	p.width = min(int(float64(p.wWidth)*0.8), 120)
+	p.height = int(float64(p.wHeight) * 0.8)
+	p.textarea.SetWidth(p.width - 4)
+	p.textarea.SetHeight(p.contentHeight())
+	p.viewport.SetWidth(p.width - 4)
+	p.viewport.SetHeight(p.contentHeight())
+	p.rendered = ""
+}
+
+// contentHeight is the height left for the plan once the title, the buttons
+// and the help are rendered.
+func (p *planDialogCmp) contentHeight() int {
+	return max(p.height-8, 3)
+}
+
+func (p *planDialogCmp) renderPlan() string {
+	r := styles.GetMarkdownRenderer(p.width - 4)
+	rendered, err := r.Render(p.plan)
+	if err != nil {
+		return p.plan
+	}
+	return strings.TrimSpace(rendered)
+}
+
+func (p *planDialogCmp) renderButtons() string {
+	t := styles.CurrentTheme()
+	buttons := []core.ButtonOpts{
+		{
+			Text:           "Approve",
+			UnderlineIndex: 0, // "A"
+			Selected:       p.selectedOption == 0,
+		},
+		{
+			Text:           "Edit",
+			UnderlineIndex: 0, // "E"
+			Selected:       p.selectedOption == 1,
+		},
+		{
+			Text:           "Keep Planning",
+			UnderlineIndex: 0, // "K"
+			Selected:       p.selectedOption == 2,
+		},
+	}
+	content := core.SelectableButtons(buttons, "  ")
+	return t.S().Base.AlignHorizontal(lipgloss.Right).Width(p.width - 4).Render(content)
+}
+
+func (p *planDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	var content string
+	var helpView string
+	if p.editing {
+		content = p.textarea.View()
+		helpView = p.help.View(editingKeyMap{DoneEditing: p.keyMap.DoneEditing})
+	} else {
+		if p.rendered == "" {
+			p.rendered = p.renderPlan()
+			p.viewport.SetContent(p.rendered)
+		}
+		content = p.viewport.View()
+		helpView = p.help.View(p.keyMap)
+	}
+
+	strs := []string{
+		core.Title("Review Plan", p.width-4),
+		"",
+		content,
+		"",
+	}
+	if !p.editing {
+		strs = append(strs, p.renderButtons())
+	}
+	strs = append(strs, "", helpView)
+
+	return baseStyle.
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(p.width).
+		Render(lipgloss.JoinVertical(lipgloss.Top, strs...))
+}
+
+func (p *planDialogCmp) Position() (int, int) {
+	row := p.wHeight/2 - p.height/2
+	col := p.wWidth/2 - p.width/2
+	return row, col
+}
+
+func (p *planDialogCmp) ID() dialogs.DialogID {
+	return PlanDialogID
+}
@@ -0,0 +1,85 @@
+package resend
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+type KeyMap struct {
+	Next,
+	Previous,
+	Select,
+	Replace,
+	Fork,
+	RestoreFiles,
+	Close key.Binding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Next: key.NewBinding(
+			key.WithKeys("right", "tab"),
+			key.WithHelp("→/tab", "next option"),
+		),
+		Previous: key.NewBinding(
+			key.WithKeys("left", "shift+tab"),
+			key.WithHelp("←", "previous option"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "confirm"),
+		),
+		Replace: key.NewBinding(
+			key.WithKeys("r", "R"),
+			key.WithHelp("r", "replace"),
+		),
+		Fork: key.NewBinding(
+			key.WithKeys("f", "F"),
+			key.WithHelp("f", "fork"),
+		),
+		RestoreFiles: key.NewBinding(
+			key.WithKeys("space"),
+			key.WithHelp("space", "restore files"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc", "alt+esc", "c", "C"),
+			key.WithHelp("esc", "cancel"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Next,
+		k.Previous,
+		k.Select,
+		k.Replace,
+		k.Fork,
+		k.RestoreFiles,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	m := [][]key.Binding{}
+	slice := k.KeyBindings()
+	for i := 0; i < len(slice); i += 4 {
+		end := min(i+4, len(slice))
+		m = append(m, slice[i:end])
+	}
+	return m
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(
+			key.WithKeys("left", "right"),
+			key.WithHelp("←/→", "switch options"),
+		),
+		k.Select,
+		k.RestoreFiles,
+		k.Close,
+	}
+}
@@ -0,0 +1,227 @@
+package resend
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/tui/components/chat/editor"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const (
+	ResendDialogID dialogs.DialogID = "resend"
+
+	maxListedFiles = 10
+)
+
+type option int
+
+const (
+	optionReplace option = iota
+	optionFork
+	optionCancel
+)
+
+// ResendConfirmedMsg is sent when the user confirms sending the edited
+// version of a previous prompt.
+type ResendConfirmedMsg struct {
+	Message     message.Message
+	Text        string
+	Attachments []message.Attachment
+	// Fork is set to keep the messages after the prompt in the current
+	// session and send the edited prompt in a fork of it.
+	Fork bool
+	// RestoreFiles is set to restore the files changed since the prompt was
+	// sent.
+	RestoreFiles bool
+}
+
+// ResendDialog asks the user how to send the edited version of a previous
+// prompt: replacing it and the messages after it, or in a fork of the
+// session, and whether to restore the files changed since.
+type ResendDialog interface {
+	dialogs.DialogModel
+}
+
+type resendDialogCmp struct {
+	wWidth  int
+	wHeight int
+	width   int
+
+	msg          ResendConfirmedMsg
+	changes      []history.FileChange
+	selected     option
+	restoreFiles bool
+
+	keyMap KeyMap
+	help   help.Model
+}
+
+// NewResendDialog creates the dialog to send text in place of the given user
+// message, with the changes restoring the files would make.
+func NewResendDialog(msg message.Message, text string, attachments []message.Attachment, changes []history.FileChange) ResendDialog {
+	return &resendDialogCmp{
+		msg: ResendConfirmedMsg{
+			Message:     msg,
+			Text:        text,
+			Attachments: attachments,
+		},
+		changes:      changes,
+		restoreFiles: len(changes) > 0,
+		keyMap:       DefaultKeyMap(),
+		help:         help.New(),
+	}
+}
+
+func (r *resendDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+func (r *resendDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.wWidth = msg.Width
+		r.wHeight = msg.Height
+		r.width = min(int(float64(r.wWidth)*0.8), 80)
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, r.keyMap.Next):
+			r.selected = (r.selected + 1) % 3
+		case key.Matches(msg, r.keyMap.Previous):
+			r.selected = (r.selected + 2) % 3
+		case key.Matches(msg, r.keyMap.RestoreFiles):
+			r.restoreFiles = !r.restoreFiles && len(r.changes) > 0
+		case key.Matches(msg, r.keyMap.Select):
+			if r.selected == optionCancel {
+				return r, r.cancel()
+			}
+			return r, r.confirm(r.selected == optionFork)
+		case key.Matches(msg, r.keyMap.Replace):
+			return r, r.confirm(false)
+		case key.Matches(msg, r.keyMap.Fork):
+			return r, r.confirm(true)
+		case key.Matches(msg, r.keyMap.Close):
+			return r, r.cancel()
+		}
+	}
+	return r, nil
+}
+
+func (r *resendDialogCmp) confirm(fork bool) tea.Cmd {
+	msg := r.msg
+	msg.Fork = fork
+	msg.RestoreFiles = r.restoreFiles
+	return tea.Batch(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(msg),
+	)
+}
+
+// cancel puts the edited prompt back in the editor.
+func (r *resendDialogCmp) cancel() tea.Cmd {
+	return tea.Batch(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(editor.OpenEditorMsg{Text: r.msg.Text}),
+	)
+}
+
+func (r *resendDialogCmp) renderFiles() string {
+	t := styles.CurrentTheme()
+	if len(r.changes) == 0 {
+		return t.S().Muted.Render("No files changed since this prompt.")
+	}
+	check := "[ ]"
+	if r.restoreFiles {
+		check = "[x]"
+	}
+	lines := []string{t.S().Text.Render(check + " Restore the files changed since:")}
+	for i, change := range r.changes {
+		if i == maxListedFiles {
+			lines = append(lines, t.S().Muted.Render(fmt.Sprintf("…and %d more", len(r.changes)-i)))
+			break
+		}
+		status := t.S().Base.Foreground(t.Warning).Render("M")
+		if change.Deleted {
+			status = t.S().Base.Foreground(t.Error).Render("D")
+		}
+		path := fsext.PrettyPath(change.Path)
+		lines = append(lines, status+" "+t.S().Muted.Width(r.width-8).MaxHeight(1).Render(path))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *resendDialogCmp) renderButtons() string {
+	t := styles.CurrentTheme()
+	buttons := []core.ButtonOpts{
+		{
+			Text:           "Replace",
+			UnderlineIndex: 0, // "R"
+			Selected:       r.selected == optionReplace,
+		},
+		{
+			Text:           "Fork",
+			UnderlineIndex: 0, // "F"
+			Selected:       r.selected == optionFork,
+		},
+		{
+			Text:           "Cancel",
+			UnderlineIndex: 0, // "C"
+			Selected:       r.selected == optionCancel,
+		},
+	}
+	content := core.SelectableButtons(buttons, "  ")
+	return t.S().Base.AlignHorizontal(lipgloss.Right).Width(r.width - 4).Render(content)
+}
+
+func (r *resendDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	prompt := strings.TrimSpace(r.msg.Text)
+	if line, _, found := strings.Cut(prompt, "\n"); found {
+		prompt = line + " …"
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		core.Title("Resend Prompt", r.width-4),
+		"",
+		t.S().Text.Width(r.width-4).Render("Replace the prompt and everything after it, or send the edited prompt in a fork of the session:"),
+		"",
+		t.S().Subtle.Width(r.width-4).MaxHeight(1).Render("> "+prompt),
+		"",
+		r.renderFiles(),
+		"",
+		r.renderButtons(),
+		"",
+		r.help.View(r.keyMap),
+	)
+
+	return baseStyle.
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(r.width).
+		Render(content)
+}
+
+func (r *resendDialogCmp) Position() (int, int) {
+	height := lipgloss.Height(r.View())
+	row := r.wHeight/2 - height/2
+	col := r.wWidth/2 - r.width/2
+	return row, col
+}
+
+func (r *resendDialogCmp) ID() dialogs.DialogID {
+	return ResendDialogID
+}
@@ -0,0 +1,175 @@
+package rewind
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const (
+	RewindDialogID dialogs.DialogID = "rewind"
+
+	maxListedFiles = 10
+)
+
+// RewindConfirmedMsg is sent when the user confirms the rewind to the given
+// user message.
+type RewindConfirmedMsg struct {
+	Message message.Message
+}
+
+// RewindDialog asks the user to confirm the rewind of the session to one of
+// its user messages, listing the files that will be restored.
+type RewindDialog interface {
+	dialogs.DialogModel
+}
+
+type rewindDialogCmp struct {
+	wWidth  int
+	wHeight int
+	width   int
+
+	message    message.Message
+	changes    []history.FileChange
+	selectedNo bool
+
+	keyMap KeyMap
+	help   help.Model
+}
+
+func NewRewindDialog(msg message.Message, changes []history.FileChange) RewindDialog {
+	return &rewindDialogCmp{
+		message: msg,
+		changes: changes,
+		keyMap:  DefaultKeyMap(),
+		help:    help.New(),
+	}
+}
+
+func (r *rewindDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+func (r *rewindDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.wWidth = msg.Width
+		r.wHeight = msg.Height
+		r.width = min(int(float64(r.wWidth)*0.8), 80)
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, r.keyMap.LeftRight, r.keyMap.Tab):
+			r.selectedNo = !r.selectedNo
+		case key.Matches(msg, r.keyMap.Select):
+			if r.selectedNo {
+				return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+			}
+			return r, r.confirm()
+		case key.Matches(msg, r.keyMap.Yes):
+			return r, r.confirm()
+		case key.Matches(msg, r.keyMap.No, r.keyMap.Close):
+			return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return r, nil
+}
+
+func (r *rewindDialogCmp) confirm() tea.Cmd {
+	return tea.Batch(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(RewindConfirmedMsg{Message: r.message}),
+	)
+}
+
+func (r *rewindDialogCmp) renderFiles() string {
+	t := styles.CurrentTheme()
+	if len(r.changes) == 0 {
+		return t.S().Muted.Render("No files to restore.")
+	}
+	lines := []string{t.S().Text.Render("Files restored:")}
+	for i, change := range r.changes {
+		if i == maxListedFiles {
+			lines = append(lines, t.S().Muted.Render(fmt.Sprintf("…and %d more", len(r.changes)-i)))
+			break
+		}
+		status := t.S().Base.Foreground(t.Warning).Render("M")
+		if change.Deleted {
+			status = t.S().Base.Foreground(t.Error).Render("D")
+		}
+		path := fsext.PrettyPath(change.Path)
+		lines = append(lines, status+" "+t.S().Muted.Width(r.width-8).MaxHeight(1).Render(path))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *rewindDialogCmp) renderButtons() string {
+	t := styles.CurrentTheme()
+	buttons := []core.ButtonOpts{
+		{
+			Text:           "Rewind",
+			UnderlineIndex: 0, // "R"
+			Selected:       !r.selectedNo,
+		},
+		{
+			Text:           "Cancel",
+			UnderlineIndex: 0, // "C"
+			Selected:       r.selectedNo,
+		},
+	}
+	content := core.SelectableButtons(buttons, "  ")
+	return t.S().Base.AlignHorizontal(lipgloss.Right).Width(r.width - 4).Render(content)
+}
+
+func (r *rewindDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	prompt := strings.TrimSpace(r.message.Content().Text)
+	if line, _, found := strings.Cut(prompt, "\n"); found {
+		prompt = line + " …"
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		core.Title("Rewind to Here", r.width-4),
+		"",
+		t.S().Text.Width(r.width-4).Render("The session goes back to before this prompt, which is put back in the editor:"),
+		"",
+		t.S().Subtle.Width(r.width-4).MaxHeight(1).Render("> "+prompt),
+		"",
+		r.renderFiles(),
+		"",
+		r.renderButtons(),
+		"",
+		r.help.View(r.keyMap),
+	)
+
+	return baseStyle.
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(r.width).
+		Render(content)
+}
+
+func (r *rewindDialogCmp) Position() (int, int) {
+	height := lipgloss.Height(r.View())
+	row := r.wHeight/2 - height/2
+	col := r.wWidth/2 - r.width/2
+	return row, col
+}
+
+func (r *rewindDialogCmp) ID() dialogs.DialogID {
+	return RewindDialogID
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/bubbles/v2/textinput"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/event"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/tui/components/chat"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/exp/list"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const SearchDialogID dialogs.DialogID = "search"
+
+// maxResults is the number of matches listed.
+const maxResults = 50
+
+// SearchDialog interface for the session search dialog
+type SearchDialog interface {
+	dialogs.DialogModel
+}
+
+type resultsMsg struct {
+	query   string
+	results []session.SearchResult
+	err     error
+}
+
+type searchDialogCmp struct {
+	wWidth   int
+	wHeight  int
+	width    int
+	sessions session.Service
+	keyMap   KeyMap
+	input    textinput.Model
+	query    string
+	results  list.List[list.CompletionItem[session.SearchResult]]
+	help     help.Model
+}
+
+// NewSearchDialogCmp creates a new dialog searching the sessions and their
+// messages.
+func NewSearchDialogCmp(sessions session.Service) SearchDialog {
+	t := styles.CurrentTheme()
+
+	input := textinput.New()
+	input.Placeholder = "Search the sessions"
+	input.SetVirtualCursor(false)
+	input.SetStyles(t.S().TextInput)
+	input.Focus()
+
+	help := help.New()
+	help.Styles = t.S().Help
+	return &searchDialogCmp{
+		sessions: sessions,
+		keyMap:   DefaultKeyMap(),
+		input:    input,
+		results:  list.New[list.CompletionItem[session.SearchResult]](nil, list.WithWrapNavigation()),
+		help:     help,
+	}
+}
+
+func (s *searchDialogCmp) Init() tea.Cmd {
+	return s.results.Init()
+}
+
+func (s *searchDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.wWidth = msg.Width
+		s.wHeight = msg.Height
+		s.width = min(120, s.wWidth-8)
+		s.input.SetWidth(s.listWidth() - 2)
+		return s, s.results.SetSize(s.listWidth(), s.listHeight())
+	case resultsMsg:
+		if msg.query != s.query {
+			return s, nil
+		}
+		if msg.err != nil {
+			return s, util.ReportError(msg.err)
+		}
+		return s, s.results.SetItems(resultItems(msg.results))
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, s.keyMap.Select):
+			selected := s.results.SelectedItem()
+			if selected == nil {
+				return s, nil
+			}
+			return s, s.open((*selected).Value())
+		case key.Matches(msg, s.keyMap.Next):
+			return s, s.results.SelectItemBelow()
+		case key.Matches(msg, s.keyMap.Previous):
+			return s, s.results.SelectItemAbove()
+		case key.Matches(msg, s.keyMap.Close):
+			return s, util.CmdHandler(dialogs.CloseDialogMsg{})
+		default:
+			var cmd tea.Cmd
+			s.input, cmd = s.input.Update(msg)
+			if s.input.Value() == s.query {
+				return s, cmd
+			}
+			s.query = s.input.Value()
+			return s, tea.Batch(cmd, s.search(s.query))
+		}
+	}
+	return s, nil
+}
+
+func (s *searchDialogCmp) search(query string) tea.Cmd {
+	return func() tea.Msg {
+		results, err := s.sessions.Search(context.Background(), query, maxResults)
+		return resultsMsg{query: query, results: results, err: err}
+	}
+}
+
+// open switches to the session of the result, on the matching message.
+func (s *searchDialogCmp) open(result session.SearchResult) tea.Cmd {
+	sess, err := s.sessions.Get(context.Background(), result.SessionID)
+	if err != nil {
+		return util.ReportError(fmt.Errorf("session not found: %w", err))
+	}
+	event.SessionSwitched()
+	cmds := []tea.Cmd{
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(chat.SessionSelectedMsg(sess)),
+	}
+	if result.MessageID != "" {
+		cmds = append(cmds, util.CmdHandler(chat.SelectMessageMsg{MessageID: result.MessageID}))
+	}
+	return tea.Sequence(cmds...)
+}
+
+// resultItems returns the items of the results: the title of the session
+// followed by the snippet, with the matching terms highlighted. The snippet
+// is the title itself when the title matched.
+func resultItems(results []session.SearchResult) []list.CompletionItem[session.SearchResult] {
+	items := make([]list.CompletionItem[session.SearchResult], len(results))
+	for i, result := range results {
+		var prefix string
+		if result.MessageID != "" {
+			prefix = result.Title + ": "
+		}
+		var indexes []int
+		for _, match := range result.Matches {
+			for j := match[0]; j < match[1]; j++ {
+				indexes = append(indexes, len(prefix)+j)
+			}
+		}
+		items[i] = list.NewCompletionItem(
+			prefix+result.Snippet,
+			result,
+			list.WithCompletionID(result.SessionID+"/"+result.MessageID),
+			list.WithCompletionMatchIndexes(indexes...),
+		)
+	}
+	return items
+}
+
+func (s *searchDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Search Sessions", s.width-4)),
+		t.S().Base.PaddingLeft(1).PaddingBottom(1).Render(s.input.View()),
+		s.resultsView(),
+		"",
+		t.S().Base.Width(s.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(s.help.View(s.keyMap)),
+	)
+	return s.style().Render(content)
+}
+
+func (s *searchDialogCmp) resultsView() string {
+	t := styles.CurrentTheme()
+	if s.query != "" && len(s.results.Items()) == 0 {
+		return t.S().Muted.PaddingLeft(1).Height(s.listHeight()).Render("No matches")
+	}
+	return s.results.View()
+}
+
+func (s *searchDialogCmp) Cursor() *tea.Cursor {
+	cursor := s.input.Cursor()
+	if cursor == nil {
+		return nil
+	}
+	row, col := s.Position()
+	cursor.Y += row + 3 // Border + title
+	cursor.X += col + 2
+	return cursor
+}
+
+func (s *searchDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(s.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+func (s *searchDialogCmp) listHeight() int {
+	return s.wHeight/2 - 8 // 8 for the border, title, input and help
+}
+
+func (s *searchDialogCmp) listWidth() int {
+	return s.width - 2 // 2 for the border
+}
+
+func (s *searchDialogCmp) Position() (int, int) {
+	row := s.wHeight/4 - 2 // just a bit above the center
+	col := s.wWidth / 2
+	col -= s.width / 2
+	return row, col
+}
+
+// ID implements SearchDialog.
+func (s *searchDialogCmp) ID() dialogs.DialogID {
+	return SearchDialogID
+}
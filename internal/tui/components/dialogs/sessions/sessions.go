@@ -1,6 +1,8 @@
 package sessions
 
 import (
+	"strings"
+
 	"github.com/charmbracelet/bubbles/v2/help"
 	"github.com/charmbracelet/bubbles/v2/key"
 	tea "github.com/charmbracelet/bubbletea/v2"
@@ -17,6 +19,11 @@ import (
 
 const SessionsDialogID dialogs.DialogID = "sessions"
 
+// ForkSessionMsg is sent to fork the given session from its last message.
+type ForkSessionMsg struct {
+	Session session.Session
+}
+
 // SessionDialog interface for the session switching dialog
 type SessionDialog interface {
 	dialogs.DialogModel
@@ -45,10 +52,15 @@ func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionD
 	listKeyMap.DownOneItem = keyMap.Next
 	listKeyMap.UpOneItem = keyMap.Previous
 
+	sessions, depths := sortByLineage(sessions)
 	items := make([]list.CompletionItem[session.Session], len(sessions))
 	if len(sessions) > 0 {
 		for i, session := range sessions {
-			items[i] = list.NewCompletionItem(session.Title, session, list.WithCompletionID(session.ID))
+			title := session.Title
+			if depths[i] > 0 {
+				title = strings.Repeat("  ", depths[i]-1) + "↳ " + title
+			}
+			items[i] = list.NewCompletionItem(title, session, list.WithCompletionID(session.ID))
 		}
 	}
 
@@ -74,6 +86,40 @@ func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionD
 	return s
 }
 
+// sortByLineage orders the forks right after the session they were forked
+// from, and returns the depth of each session in its lineage. Forks of
+// sessions that aren't listed are kept at the top level.
+func sortByLineage(sessions []session.Session) ([]session.Session, []int) {
+	listed := make(map[string]bool, len(sessions))
+	for _, s := range sessions {
+		listed[s.ID] = true
+	}
+	forks := make(map[string][]session.Session)
+	var roots []session.Session
+	for _, s := range sessions {
+		if s.IsFork() && listed[s.ParentSessionID] {
+			forks[s.ParentSessionID] = append(forks[s.ParentSessionID], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	sorted := make([]session.Session, 0, len(sessions))
+	depths := make([]int, 0, len(sessions))
+	var add func(s session.Session, depth int)
+	add = func(s session.Session, depth int) {
+		sorted = append(sorted, s)
+		depths = append(depths, depth)
+		for _, fork := range forks[s.ID] {
+			add(fork, depth+1)
+		}
+	}
+	for _, s := range roots {
+		add(s, 0)
+	}
+	return sorted, depths
+}
+
 func (s *sessionDialogCmp) Init() tea.Cmd {
 	var cmds []tea.Cmd
 	cmds = append(cmds, s.sessionsList.Init())
@@ -108,6 +154,15 @@ func (s *sessionDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 					),
 				)
 			}
+		case key.Matches(msg, s.keyMap.Fork):
+			selectedItem := s.sessionsList.SelectedItem()
+			if selectedItem != nil {
+				selected := *selectedItem
+				return s, tea.Sequence(
+					util.CmdHandler(dialogs.CloseDialogMsg{}),
+					util.CmdHandler(ForkSessionMsg{Session: selected.Value()}),
+				)
+			}
 		case key.Matches(msg, s.keyMap.Close):
 			return s, util.CmdHandler(dialogs.CloseDialogMsg{})
 		default:
@@ -4,6 +4,7 @@ package image
 
 import (
 	"fmt"
+	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 
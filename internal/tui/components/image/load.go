@@ -3,6 +3,7 @@
 package image
 
 import (
+	"bytes"
 	"context"
 	"image"
 	"image/png"
@@ -101,6 +102,20 @@ func readerToImage(width uint, height uint, url string, r io.Reader) (string, er
 	return imageToString(width, height, img)
 }
 
+// Render renders the encoded image to a string fitting the given size.
+func Render(width, height uint, data []byte, mimeType string) (string, error) {
+	if mimeType == "image/svg+xml" {
+		return svgToImage(width, height, bytes.NewReader(data))
+	}
+
+	img, _, err := imageorient.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	return imageToString(width, height, img)
+}
+
 func svgToImage(width uint, height uint, r io.Reader) (string, error) {
 	// Original author: https://stackoverflow.com/users/10826783/usual-human
 	// https://stackoverflow.com/questions/42993407/how-to-create-and-export-svg-to-png-jpeg-in-golang
@@ -1,7 +1,9 @@
 package chat
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -10,6 +12,8 @@ import (
 	"github.com/charmbracelet/bubbles/v2/key"
 	"github.com/charmbracelet/bubbles/v2/spinner"
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/agent/tools"
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/history"
@@ -28,10 +32,15 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/core"
 	"github.com/charmbracelet/crush/internal/tui/components/core/layout"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/agents"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/commands"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/plan"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/reasoning"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/resend"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/rewind"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/sessions"
 	"github.com/charmbracelet/crush/internal/tui/page"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
@@ -102,6 +111,13 @@ type chatPage struct {
 	session session.Session
 	keyMap  KeyMap
 
+	// Agent to use for the next session, empty means the default agent
+	agentID string
+	// Whether the prompts of the current session are sent in plan mode
+	planMode bool
+	// The user message being edited, the next prompt is sent in its place
+	editing *message.Message
+
 	// Components
 	header  header.Header
 	sidebar sidebar.Sidebar
@@ -237,9 +253,14 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 		p.editor = u.(editor.Editor)
 		return p, cmd
 	case chat.SendMsg:
+		if p.editing != nil {
+			return p, p.openResendDialog(msg.Text, msg.Attachments)
+		}
 		return p, p.sendMessage(msg.Text, msg.Attachments)
 	case chat.SessionSelectedMsg:
 		return p, p.setSession(msg)
+	case chat.SelectMessageMsg:
+		return p, p.selectMessage(msg.MessageID)
 	case splash.SubmitAPIKeyMsg:
 		u, cmd := p.splash.Update(msg)
 		p.splash = u.(splash.Splash)
@@ -262,6 +283,10 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 		return p, p.openReasoningDialog()
 	case reasoning.ReasoningEffortSelectedMsg:
 		return p, p.handleReasoningEffortSelected(msg.Effort)
+	case commands.OpenAgentsDialogMsg:
+		return p, p.openAgentsDialog()
+	case agents.AgentSelectedMsg:
+		return p, p.handleAgentSelected(msg.AgentID)
 	case commands.OpenExternalEditorMsg:
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
@@ -303,6 +328,9 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 	case pubsub.Event[message.Message],
 		anim.StepMsg,
 		spinner.TickMsg:
+		if event, ok := msg.(pubsub.Event[message.Message]); ok {
+			cmds = append(cmds, p.openSubmittedPlan(event))
+		}
 		if p.focusedPane == PanelTypeSplash {
 			u, cmd := p.splash.Update(msg)
 			p.splash = u.(splash.Splash)
@@ -319,6 +347,22 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
 		return p, cmd
+	case commands.TogglePlanModeMsg:
+		return p, p.togglePlanMode()
+	case plan.PlanResponseMsg:
+		return p, p.handlePlanResponse(msg)
+	case messages.RewindMsg:
+		return p, p.openRewindDialog(msg.Message)
+	case rewind.RewindConfirmedMsg:
+		return p, p.rewind(msg.Message)
+	case messages.EditMsg:
+		return p, p.editPrompt(msg.Message)
+	case resend.ResendConfirmedMsg:
+		return p, p.resend(msg)
+	case messages.ForkMsg:
+		return p, p.fork(msg.Message.SessionID, msg.Message.ID)
+	case sessions.ForkSessionMsg:
+		return p, p.forkSession(msg.Session)
 	case pubsub.Event[history.File], sidebar.SessionFilesMsg:
 		u, cmd := p.sidebar.Update(msg)
 		p.sidebar = u.(sidebar.Sidebar)
@@ -390,6 +434,10 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 			if p.session.ID != "" && p.app.AgentCoordinator.IsBusy() {
 				return p, p.cancel()
 			}
+			if p.editing != nil {
+				p.setEditing(nil)
+				return p, util.CmdHandler(editor.OpenEditorMsg{})
+			}
 		case key.Matches(msg, p.keyMap.Details):
 			p.toggleDetails()
 			return p, nil
@@ -603,6 +651,199 @@ func (p *chatPage) handleReasoningEffortSelected(effort string) tea.Cmd {
 	}
 }
 
+func (p *chatPage) currentAgentID() string {
+	if p.session.ID == "" || p.app.AgentCoordinator == nil {
+		return cmp.Or(p.agentID, config.AgentCoder)
+	}
+	return p.app.AgentCoordinator.SessionAgentID(p.session.ID)
+}
+
+func (p *chatPage) openAgentsDialog() tea.Cmd {
+	return util.CmdHandler(dialogs.OpenDialogMsg{
+		Model: agents.NewAgentsDialog(p.currentAgentID()),
+	})
+}
+
+func (p *chatPage) handleAgentSelected(agentID string) tea.Cmd {
+	agentCfg, ok := config.Get().Agents[agentID]
+	if !ok {
+		return util.ReportError(fmt.Errorf("agent %q not found", agentID))
+	}
+	if p.session.ID != "" && p.app.AgentCoordinator != nil {
+		if err := p.app.AgentCoordinator.SetSessionAgent(p.session.ID, agentID); err != nil {
+			return util.ReportError(err)
+		}
+	}
+	p.agentID = agentID
+	return util.ReportInfo("Switched to " + agentCfg.Name + " agent")
+}
+
+func (p *chatPage) togglePlanMode() tea.Cmd {
+	enabled := !p.planMode
+	if p.session.ID != "" && p.app.AgentCoordinator != nil {
+		if err := p.app.AgentCoordinator.SetPlanMode(p.session.ID, enabled); err != nil {
+			return util.ReportError(err)
+		}
+	}
+	p.planMode = enabled
+	p.editor.SetPlanMode(enabled)
+	if enabled {
+		return util.ReportInfo("Plan mode on, the agent will submit a plan before making changes")
+	}
+	return util.ReportInfo("Plan mode off")
+}
+
+// openSubmittedPlan opens the plan dialog when the agent submits a plan in
+// the current session.
+func (p *chatPage) openSubmittedPlan(event pubsub.Event[message.Message]) tea.Cmd {
+	if event.Type != pubsub.CreatedEvent || event.Payload.SessionID != p.session.ID || event.Payload.Role != message.Tool {
+		return nil
+	}
+	for _, result := range event.Payload.ToolResults() {
+		if result.Name != tools.PlanToolName || result.IsError {
+			continue
+		}
+		var meta tools.PlanResponseMetadata
+		if err := json.Unmarshal([]byte(result.Metadata), &meta); err != nil {
+			return util.ReportError(fmt.Errorf("failed to read the plan: %w", err))
+		}
+		return util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: plan.NewPlanDialog(event.Payload.SessionID, meta.Plan.Markdown()),
+		})
+	}
+	return nil
+}
+
+func (p *chatPage) handlePlanResponse(msg plan.PlanResponseMsg) tea.Cmd {
+	if !msg.Approved {
+		return util.ReportInfo("Plan not approved, tell the agent what to change")
+	}
+	if msg.SessionID != p.session.ID || p.app.AgentCoordinator == nil {
+		return nil
+	}
+	// the run that submitted the plan may not be done yet, the prompt is then
+	// queued and runs out of plan mode once it is
+	if err := p.app.AgentCoordinator.SetPlanMode(msg.SessionID, false); err != nil {
+		return util.ReportError(err)
+	}
+	p.planMode = false
+	p.editor.SetPlanMode(false)
+	return tea.Batch(
+		util.ReportInfo("Plan approved, implementing it"),
+		p.sendMessage(agent.ApprovedPlanPrompt(msg.Plan), nil),
+	)
+}
+
+func (p *chatPage) openRewindDialog(msg message.Message) tea.Cmd {
+	if p.app.AgentCoordinator != nil && p.app.AgentCoordinator.IsSessionBusy(msg.SessionID) {
+		return util.ReportWarn("Agent is working, please wait...")
+	}
+	changes, err := p.app.Rewind(context.Background(), msg.SessionID, msg.ID, true)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	return util.CmdHandler(dialogs.OpenDialogMsg{
+		Model: rewind.NewRewindDialog(msg, changes),
+	})
+}
+
+// rewind rewinds the session to the given user message and puts its prompt
+// back in the editor.
+func (p *chatPage) rewind(msg message.Message) tea.Cmd {
+	changes, err := p.app.Rewind(context.Background(), msg.SessionID, msg.ID, false)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	p.focusedPane = PanelTypeEditor
+	return tea.Batch(
+		p.chat.Blur(),
+		p.editor.Focus(),
+		util.CmdHandler(editor.OpenEditorMsg{Text: msg.Content().Text}),
+		util.ReportInfo(fmt.Sprintf("Rewound the session, %d file(s) restored", len(changes))),
+	)
+}
+
+// editPrompt puts the given user message in the editor, to send it again
+// once edited.
+func (p *chatPage) editPrompt(msg message.Message) tea.Cmd {
+	if p.app.AgentCoordinator != nil && p.app.AgentCoordinator.IsSessionBusy(msg.SessionID) {
+		return util.ReportWarn("Agent is working, please wait...")
+	}
+	p.setEditing(&msg)
+	p.focusedPane = PanelTypeEditor
+	return tea.Batch(
+		p.chat.Blur(),
+		p.editor.Focus(),
+		util.CmdHandler(editor.OpenEditorMsg{Text: msg.Content().Text}),
+	)
+}
+
+func (p *chatPage) setEditing(msg *message.Message) {
+	p.editing = msg
+	p.editor.SetEditing(msg != nil)
+}
+
+func (p *chatPage) openResendDialog(text string, attachments []message.Attachment) tea.Cmd {
+	msg := *p.editing
+	changes, err := p.app.History.RewindChanges(context.Background(), msg.SessionID, msg.ID)
+	if err != nil && !errors.Is(err, history.ErrNoCheckpoint) {
+		return util.ReportError(err)
+	}
+	return util.CmdHandler(dialogs.OpenDialogMsg{
+		Model: resend.NewResendDialog(msg, text, attachments, changes),
+	})
+}
+
+// resend sends the edited prompt in place of the original one, in the
+// current session or in a fork of it.
+func (p *chatPage) resend(msg resend.ResendConfirmedMsg) tea.Cmd {
+	s, changes, err := p.app.EditPrompt(context.Background(), msg.Message.SessionID, msg.Message.ID, msg.Fork, msg.RestoreFiles)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	p.setEditing(nil)
+	var cmds []tea.Cmd
+	if len(changes) > 0 {
+		cmds = append(cmds, util.ReportInfo(fmt.Sprintf("%d file(s) restored", len(changes))))
+	}
+	if msg.Fork {
+		cmds = append(cmds, tea.Sequence(
+			util.CmdHandler(chat.SessionSelectedMsg(s)),
+			util.CmdHandler(chat.SendMsg{Text: msg.Text, Attachments: msg.Attachments}),
+		))
+	} else {
+		cmds = append(cmds, p.sendMessage(msg.Text, msg.Attachments))
+	}
+	return tea.Batch(cmds...)
+}
+
+// fork forks the session at the given message and switches to the fork.
+func (p *chatPage) fork(sessionID, messageID string) tea.Cmd {
+	if p.app.AgentCoordinator != nil && p.app.AgentCoordinator.IsSessionBusy(sessionID) {
+		return util.ReportWarn("Agent is working, please wait...")
+	}
+	fork, err := p.app.Sessions.Fork(context.Background(), sessionID, messageID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	return tea.Batch(
+		util.CmdHandler(chat.SessionSelectedMsg(fork)),
+		util.ReportInfo("Forked the session"),
+	)
+}
+
+// forkSession forks the given session from its last message.
+func (p *chatPage) forkSession(s session.Session) tea.Cmd {
+	msgs, err := p.app.Messages.List(context.Background(), s.ID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	if len(msgs) == 0 {
+		return util.ReportWarn("Nothing to fork, the session has no messages")
+	}
+	return p.fork(s.ID, msgs[len(msgs)-1].ID)
+}
+
 func (p *chatPage) setCompactMode(compact bool) {
 	if p.compact == compact {
 		return
@@ -663,7 +904,10 @@ func (p *chatPage) newSession() tea.Cmd {
 		return nil
 	}
 
+	// keep using the same agent for the new session
+	p.agentID = p.currentAgentID()
 	p.session = session.Session{}
+	p.setEditing(nil)
 	p.focusedPane = PanelTypeEditor
 	p.editor.Focus()
 	p.chat.Blur()
@@ -681,6 +925,11 @@ func (p *chatPage) setSession(session session.Session) tea.Cmd {
 
 	var cmds []tea.Cmd
 	p.session = session
+	p.setEditing(nil)
+	if p.app.AgentCoordinator != nil {
+		p.planMode = p.app.AgentCoordinator.IsPlanMode(session.ID)
+		p.editor.SetPlanMode(p.planMode)
+	}
 
 	cmds = append(cmds, p.SetSize(p.width, p.height))
 	cmds = append(cmds, p.chat.SetSession(session))
@@ -691,6 +940,17 @@ func (p *chatPage) setSession(session session.Session) tea.Cmd {
 	return tea.Sequence(cmds...)
 }
 
+// selectMessage focuses the chat on the given message of the session.
+func (p *chatPage) selectMessage(messageID string) tea.Cmd {
+	if p.session.ID == "" {
+		return nil
+	}
+	if p.focusedPane != PanelTypeChat {
+		p.changeFocus()
+	}
+	return p.chat.SelectMessage(messageID)
+}
+
 func (p *chatPage) changeFocus() {
 	if p.session.ID == "" {
 		return
@@ -753,6 +1013,16 @@ func (p *chatPage) sendMessage(text string, attachments []message.Attachment) te
 	if p.app.AgentCoordinator == nil {
 		return util.ReportError(fmt.Errorf("coder agent is not initialized"))
 	}
+	if p.session.ID == "" && p.agentID != "" {
+		if err := p.app.AgentCoordinator.SetSessionAgent(session.ID, p.agentID); err != nil {
+			return util.ReportError(err)
+		}
+	}
+	if p.session.ID == "" && p.planMode {
+		if err := p.app.AgentCoordinator.SetPlanMode(session.ID, true); err != nil {
+			return util.ReportError(err)
+		}
+	}
 	cmds = append(cmds, p.chat.GoToBottom())
 	cmds = append(cmds, func() tea.Msg {
 		_, err := p.app.AgentCoordinator.Run(context.Background(), session.ID, text, attachments...)
@@ -974,6 +1244,9 @@ func (p *chatPage) Help() help.KeyMap {
 					key.WithHelp("↑↓", "scroll"),
 				),
 				messages.CopyKey,
+				messages.EditKey,
+				messages.RewindKey,
+				messages.ForkKey,
 			)
 			fullList = append(fullList,
 				[]key.Binding{
@@ -1014,6 +1287,9 @@ func (p *chatPage) Help() help.KeyMap {
 				},
 				[]key.Binding{
 					messages.CopyKey,
+					messages.EditKey,
+					messages.RewindKey,
+					messages.ForkKey,
 					messages.ClearSelectionKey,
 				},
 			)
@@ -15,8 +15,10 @@ import (
 	cmdregistry "github.com/charmbracelet/crush/internal/commands"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/event"
+	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
 	cmpChat "github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/splash"
 	"github.com/charmbracelet/crush/internal/tui/components/completions"
@@ -29,6 +31,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/permissions"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/quit"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/search"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/sessions"
 	"github.com/charmbracelet/crush/internal/tui/page"
 	"github.com/charmbracelet/crush/internal/tui/page/chat"
@@ -191,6 +194,10 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				Model: sessions.NewSessionDialogCmp(allSessions, a.selectedSessionID),
 			}
 		}
+	case commands.SearchSessionsMsg:
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: search.NewSearchDialogCmp(a.app.Sessions),
+		})
 
 	case commands.SwitchModelMsg:
 		return a, util.CmdHandler(
@@ -246,6 +253,28 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: filepicker.NewFilePickerCmp(a.app.Config().WorkingDir()),
 		})
+	// Provider retries
+	case pubsub.Event[message.RetryEvent]:
+		if msg.Payload.SessionID != a.selectedSessionID {
+			return a, nil
+		}
+		return a, util.CmdHandler(util.InfoMsg{
+			Type: util.InfoTypeWarn,
+			Msg:  fmt.Sprintf("%s: %s", msg.Payload.Model, msg.Payload.Status()),
+			TTL:  max(msg.Payload.Delay, time.Second),
+		})
+	// Budgets
+	case pubsub.Event[session.BudgetEvent]:
+		if msg.Payload.SessionID != a.selectedSessionID {
+			return a, nil
+		}
+		if msg.Payload.Exceeded {
+			return a, util.CmdHandler(util.InfoMsg{
+				Type: util.InfoTypeError,
+				Msg:  fmt.Sprintf("Stopped, %s", msg.Payload.Status()),
+			})
+		}
+		return a, util.ReportWarn(msg.Payload.Status())
 	// Permissions
 	case pubsub.Event[permission.PermissionNotification]:
 		item, ok := a.pages[a.currentPage]